@@ -0,0 +1,552 @@
+// Package cli is the titleparser command line: looking up titles, running
+// the local server or a stream, and inspecting handlers and the cache
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lepinkainen/titleparser/irc"
+	"github.com/lepinkainen/titleparser/lambda"
+	"github.com/lepinkainen/titleparser/rules"
+	"github.com/lepinkainen/titleparser/server"
+	"github.com/lepinkainen/titleparser/stream"
+	log "github.com/sirupsen/logrus"
+)
+
+// Exit codes
+const (
+	exitOK = 0
+	// exitFailed is for lookups and cache operations that didn't succeed
+	exitFailed = 1
+	// exitUsage is for invalid arguments and broken input or output
+	exitUsage = 2
+)
+
+const usageFormat = `Usage: titleparser <command> [flags] [arguments]
+
+Commands:
+  get <url>               print the title of the URL
+  serve                   run the HTTP server
+  stream                  read NDJSON queries from stdin, write results to stdout
+  irc                     run the IRC bot
+  handlers                list the handlers, and why disabled ones are
+  match <url>             show which handlers match the URL
+  cache get|purge <url>   show or remove the cached result for the URL
+  validate-rules <file>   check site rules against their fixtures
+
+Run "titleparser <command> -h" for the flags of a command.
+Inside AWS Lambda the Lambda handler is started automatically.
+`
+
+// command runs with the arguments after its name and returns the exit code
+type command func(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int
+
+var commands = map[string]command{
+	"get":            get,
+	"serve":          serve,
+	"stream":         runStream,
+	"irc":            runIRC,
+	"handlers":       handlers,
+	"match":          match,
+	"cache":          cache,
+	"validate-rules": validateRules,
+}
+
+// Run runs the command in args, the program name not included
+func Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usageFormat)
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usageFormat)
+		return exitOK
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usageFormat)
+		return exitUsage
+	}
+	return cmd(ctx, args[1:], stdin, stdout, stderr)
+}
+
+// parse parses flags anywhere among the arguments, so "get <url> --json"
+// works like "get --json <url>". Returns the positional arguments.
+func parse(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// newFlagSet returns a flag set printing its errors and usage to stderr
+func newFlagSet(name, usage string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: titleparser %s\n\nFlags:\n", usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// usageError prints the problem and the usage of the command
+func usageError(fs *flag.FlagSet, stderr io.Writer, format string, args ...interface{}) int {
+	fmt.Fprintf(stderr, format+"\n\n", args...)
+	fs.Usage()
+	return exitUsage
+}
+
+// quiet hides the info logs of a single lookup, only problems are shown
+func quiet() {
+	if log.GetLevel() > log.WarnLevel {
+		log.SetLevel(log.WarnLevel)
+	}
+}
+
+// queryFlags are the title query fields shared by get and cache
+type queryFlags struct {
+	channel   *string
+	user      *string
+	languages *string
+	locale    *string
+}
+
+func addQueryFlags(fs *flag.FlagSet) queryFlags {
+	return queryFlags{
+		channel:   fs.String("channel", "", "channel the URL was posted to, for channel settings"),
+		user:      fs.String("user", "", "user who posted the URL"),
+		languages: fs.String("languages", "", "preferred languages, comma separated"),
+		locale:    fs.String("locale", "", "locale for counts, dates and labels"),
+	}
+}
+
+func (f queryFlags) query(url string) lambda.TitleQuery {
+	query := lambda.TitleQuery{URL: url, Channel: *f.channel, User: *f.user, Locale: *f.locale}
+	for _, language := range strings.Split(*f.languages, ",") {
+		if language = strings.TrimSpace(language); language != "" {
+			query.Languages = append(query.Languages, language)
+		}
+	}
+	return query
+}
+
+func get(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("get", "get [flags] <url>", stderr)
+	asJSON := fs.Bool("json", false, "print the whole result as JSON")
+	handler := fs.String("handler", "", "use the named handler instead of the matching one, see \"titleparser handlers\"")
+	noCache := fs.Bool("no-cache", false, "don't read or write the cache")
+	explain := fs.Bool("explain", false, "show how the title was found, part of the result with --json")
+	queryFlags := addQueryFlags(fs)
+
+	positional, err := parse(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(positional) != 1 {
+		return usageError(fs, stderr, "get takes exactly one URL")
+	}
+	if *handler != "" && !lambda.HasHandler(*handler) {
+		return usageError(fs, stderr, "no handler named %q", *handler)
+	}
+
+	quiet()
+	query := queryFlags.query(positional[0])
+	query.Explain = *explain
+	ctx = lambda.WithOptions(ctx, lambda.Options{Handler: *handler, NoCache: *noCache})
+
+	res, err := lambda.HandleRequest(ctx, query)
+
+	if *asJSON {
+		item := lambda.BatchItem{TitleQuery: res}
+		if err != nil {
+			item.Error = err.Error()
+		}
+		writeJSON(stdout, item)
+	} else if err == nil {
+		fmt.Fprintln(stdout, res.Title)
+	}
+	if *explain && !*asJSON && res.Explanation != nil {
+		writeExplain(stdout, query.URL, res)
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitFailed
+	}
+	return exitOK
+}
+
+// writeExplain prints how the title was found
+func writeExplain(w io.Writer, url string, res lambda.TitleQuery) {
+	explanation := res.Explanation
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	defer tw.Flush()
+
+	fmt.Fprintln(tw, "url:\t"+url)
+	switch {
+	case explanation.Pattern != "":
+		fmt.Fprintf(tw, "handler:\t%s (pattern %s)\n", explanation.Handler, explanation.Pattern)
+	case explanation.Handler != "":
+		fmt.Fprintln(tw, "handler:\t"+explanation.Handler)
+	}
+	for _, decision := range explanation.Decisions {
+		fmt.Fprintln(tw, "decision:\t"+decision)
+	}
+
+	cache := explanation.Cache
+	if explanation.Cache == lambda.CacheHit {
+		cache += fmt.Sprintf(" (%s old)", time.Duration(explanation.CacheAge)*time.Second)
+	}
+	fmt.Fprintln(tw, "cache:\t"+cache)
+	if len(res.RedirectChain) > 0 {
+		fmt.Fprintln(tw, "redirects:\t"+strings.Join(res.RedirectChain, " -> "))
+	}
+	if res.FinalURL != "" {
+		fmt.Fprintln(tw, "final url:\t"+res.FinalURL)
+	}
+	if res.Canonical != "" {
+		fmt.Fprintln(tw, "canonical:\t"+res.Canonical)
+	}
+	if explanation.Status != 0 {
+		fmt.Fprintf(tw, "response:\t%d %s\n", explanation.Status, explanation.ContentType)
+	}
+	if explanation.Source != "" {
+		fmt.Fprintln(tw, "source:\t"+explanation.Source)
+	}
+
+	timings := make([]string, 0, len(explanation.Timings))
+	for _, timing := range explanation.Timings {
+		elapsed := time.Duration(timing.Millis * float64(time.Millisecond)).Round(time.Microsecond)
+		timings = append(timings, fmt.Sprintf("%s %s", timing.Stage, elapsed))
+	}
+	fmt.Fprintln(tw, "time:\t"+strings.Join(timings, ", "))
+}
+
+func serve(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	config, err := server.ConfigFromEnv()
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid server configuration: %v\n", err)
+		return exitUsage
+	}
+
+	fs := newFlagSet("serve", "serve [flags]", stderr)
+	fs.StringVar(&config.BindAddress, "bind", config.BindAddress, "interface to listen on, 0.0.0.0 for all (BIND_ADDRESS)")
+	fs.IntVar(&config.Port, "port", config.Port, "port to listen on (PORT)")
+	apiKeys := fs.String("api-key", strings.Join(config.APIKeys, ","), "required API keys, comma separated (API_KEY)")
+	fs.Int64Var(&config.MaxBodyBytes, "max-body-bytes", config.MaxBodyBytes, "largest accepted request body (MAX_BODY_BYTES)")
+	fs.IntVar(&config.MaxBatchURLs, "max-batch-urls", config.MaxBatchURLs, "most URLs in one batch (MAX_BATCH_URLS)")
+	useCache := fs.Bool("cache", false, "cache results in DynamoDB, needs AWS credentials")
+
+	positional, err := parse(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(positional) > 0 {
+		return usageError(fs, stderr, "serve takes no arguments")
+	}
+	if config.Port < 1 || config.Port > 65535 {
+		return usageError(fs, stderr, "invalid port %d", config.Port)
+	}
+	config.APIKeys = nil
+	for _, key := range strings.Split(*apiKeys, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			config.APIKeys = append(config.APIKeys, key)
+		}
+	}
+
+	options := lambda.Options{NoCache: !*useCache}
+	lookup := func(ctx context.Context, query lambda.TitleQuery) (lambda.TitleQuery, error) {
+		return lambda.HandleRequest(lambda.WithOptions(ctx, options), query)
+	}
+	batch := func(ctx context.Context, query lambda.BatchQuery) lambda.BatchResult {
+		return lambda.HandleBatch(lambda.WithOptions(ctx, options), query)
+	}
+
+	// SIGTERM from systemd or docker stops taking new requests and lets the running ones finish
+	if err := server.New(config, lookup, batch).Run(ctx); err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitFailed
+	}
+	return exitOK
+}
+
+func runStream(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	config, err := stream.ConfigFromEnv()
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid stream configuration: %v\n", err)
+		return exitUsage
+	}
+
+	fs := newFlagSet("stream", "stream [flags] < queries.ndjson", stderr)
+	fs.IntVar(&config.Concurrency, "concurrency", config.Concurrency, "lines handled at the same time (STREAM_CONCURRENCY)")
+	order := "input"
+	if !config.Ordered {
+		order = "completion"
+	}
+	fs.StringVar(&order, "order", order, "input or completion order, completion tags results with the line number (STREAM_ORDER)")
+	noCache := fs.Bool("no-cache", false, "don't read or write the cache")
+
+	positional, err := parse(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(positional) > 0 {
+		return usageError(fs, stderr, "stream takes no arguments, queries are read from stdin")
+	}
+	switch order {
+	case "input", "completion":
+		config.Ordered = order == "input"
+	default:
+		return usageError(fs, stderr, "invalid order %q, use input or completion", order)
+	}
+	if config.Concurrency < 1 {
+		return usageError(fs, stderr, "invalid concurrency %d", config.Concurrency)
+	}
+
+	options := lambda.Options{NoCache: *noCache}
+	lookup := func(ctx context.Context, query lambda.TitleQuery) (lambda.TitleQuery, error) {
+		return lambda.HandleRequest(lambda.WithOptions(ctx, options), query)
+	}
+	batch := func(ctx context.Context, query lambda.BatchQuery) lambda.BatchResult {
+		return lambda.HandleBatch(lambda.WithOptions(ctx, options), query)
+	}
+
+	summary, err := stream.Run(ctx, config, stdin, stdout, lookup, batch)
+	log.Infof("Processed %d lines, %d results, %d failed", summary.Lines, summary.Results, summary.Failed)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitUsage
+	}
+	if summary.Failed > 0 {
+		return exitFailed
+	}
+	return exitOK
+}
+
+func runIRC(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("irc", "irc [flags]", stderr)
+	configFile := fs.String("config", os.Getenv("IRC_CONFIG"), "bot configuration, YAML or JSON (IRC_CONFIG)")
+	noCache := fs.Bool("no-cache", false, "don't read or write the cache")
+
+	positional, err := parse(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(positional) > 0 {
+		return usageError(fs, stderr, "irc takes no arguments")
+	}
+	if *configFile == "" {
+		return usageError(fs, stderr, "no IRC configuration, use --config or IRC_CONFIG")
+	}
+
+	config, err := irc.LoadConfig(*configFile)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", *configFile, err)
+		return exitUsage
+	}
+
+	options := lambda.Options{NoCache: *noCache}
+	lookup := func(ctx context.Context, query lambda.TitleQuery) (lambda.TitleQuery, error) {
+		return lambda.HandleRequest(lambda.WithOptions(ctx, options), query)
+	}
+
+	// Runs until SIGINT or SIGTERM, reconnecting when connections fail
+	if err := irc.New(config, lookup).Run(ctx); err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitFailed
+	}
+	return exitOK
+}
+
+func handlers(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("handlers", "handlers [flags]", stderr)
+	asJSON := fs.Bool("json", false, "print the handlers as JSON")
+
+	positional, err := parse(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(positional) > 0 {
+		return usageError(fs, stderr, "handlers takes no arguments")
+	}
+
+	registered := lambda.Handlers()
+	if *asJSON {
+		writeJSON(stdout, registered)
+		return exitOK
+	}
+
+	for i, h := range registered {
+		if i > 0 {
+			fmt.Fprintln(stdout)
+		}
+		status := "enabled"
+		if !h.Enabled {
+			status = "disabled: " + h.Disabled
+		}
+		fmt.Fprintf(stdout, "%s (%s)\n", h.Name, status)
+		if h.Description != "" {
+			fmt.Fprintf(stdout, "  %s\n", h.Description)
+		}
+		if len(h.Patterns) > 0 {
+			fmt.Fprintf(stdout, "  patterns: %s\n", strings.Join(h.Patterns, "  "))
+		}
+		if len(h.Examples) > 0 {
+			fmt.Fprintf(stdout, "  examples: %s\n", strings.Join(h.Examples, "  "))
+		}
+		if len(h.Env) > 0 {
+			fmt.Fprintf(stdout, "  needs:    %s\n", strings.Join(h.Env, ", "))
+		}
+	}
+	return exitOK
+}
+
+func match(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("match", "match <url>", stderr)
+	positional, err := parse(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(positional) != 1 {
+		return usageError(fs, stderr, "match takes exactly one URL")
+	}
+
+	matched := lambda.Match(positional[0])
+	if len(matched) == 0 {
+		fmt.Fprintf(stdout, "No pattern matches, the %s handler is used\n", lambda.DefaultHandlerName)
+		return exitOK
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tPATTERN\t")
+	used := false
+	for _, h := range matched {
+		status := ""
+		switch {
+		case h.Disabled != "":
+			status = "disabled: " + h.Disabled
+		case !used:
+			status = "used"
+			used = true
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", h.Name, h.Pattern, status)
+	}
+	if !used {
+		fmt.Fprintf(tw, "%s\t\tused\n", lambda.DefaultHandlerName)
+	}
+	tw.Flush()
+	return exitOK
+}
+
+func cache(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("cache", "cache get|purge [flags] <url>", stderr)
+	asJSON := fs.Bool("json", false, "print the whole cached item as JSON")
+	queryFlags := addQueryFlags(fs)
+
+	positional, err := parse(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(positional) != 2 {
+		return usageError(fs, stderr, "cache takes an action and a URL")
+	}
+
+	quiet()
+	query := queryFlags.query(positional[1])
+	switch positional[0] {
+	case "get":
+		cached, err := lambda.CachedItem(query)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", query.URL, err)
+			return exitFailed
+		}
+		if *asJSON {
+			writeJSON(stdout, cached)
+			return exitOK
+		}
+		fmt.Fprintln(stdout, cached.Title)
+		fmt.Fprintf(stdout, "cached %s, expires %s\n",
+			time.Unix(cached.Added, 0).Format(time.RFC3339), time.Unix(cached.TTL, 0).Format(time.RFC3339))
+		return exitOK
+
+	case "purge":
+		keys, err := lambda.PurgeCache(ctx, query)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", query.URL, err)
+			return exitFailed
+		}
+		for _, key := range keys {
+			fmt.Fprintln(stdout, "purged "+key)
+		}
+		return exitOK
+
+	default:
+		return usageError(fs, stderr, "unknown cache action %q, use get or purge", positional[0])
+	}
+}
+
+// validateRules checks rule files against their saved HTML fixtures
+func validateRules(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("validate-rules", "validate-rules <rules file>...", stderr)
+	files, err := parse(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(files) == 0 {
+		return usageError(fs, stderr, "no rule files given")
+	}
+
+	failed := false
+	for _, file := range files {
+		ruleset, err := rules.Load(file)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", file, err)
+			failed = true
+			continue
+		}
+
+		problems := rules.Validate(ruleset, filepath.Dir(file))
+		for _, problem := range problems {
+			fmt.Fprintf(stderr, "%s: %v\n", file, problem)
+		}
+		if len(problems) > 0 {
+			failed = true
+			continue
+		}
+
+		fmt.Fprintf(stdout, "%s: %d rules OK\n", file, len(ruleset))
+	}
+
+	if failed {
+		return exitFailed
+	}
+	return exitOK
+}
+
+func writeJSON(w io.Writer, v interface{}) {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(v)
+}
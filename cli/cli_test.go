@@ -0,0 +1,217 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/lepinkainen/titleparser/lambda"
+)
+
+// cliHandler is registered for /cli/ paths, the default handler gets everything else
+func cliHandler(ctx context.Context, url string) (string, error) {
+	if strings.HasSuffix(url, "/fail") {
+		return "", fmt.Errorf("handler failed")
+	}
+	return "Handled " + url[strings.LastIndex(url, "/")+1:], nil
+}
+
+func init() {
+	lambda.RegisterNamedHandler("test.CLI", `^http://127\.0\.0\.1:[0-9]+/cli/`, cliHandler)
+	lambda.RegisterNamedHandler("test.Overlap", `/cli/overlap`, cliHandler)
+	lambda.Register(lambda.Handler{
+		Name:     "test.Disabled",
+		Patterns: []string{`/cli-disabled/`},
+		Env:      []string{"CLI_TEST_MISSING_KEY"},
+		Func:     cliHandler,
+	})
+}
+
+func run(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr strings.Builder
+	code := Run(context.Background(), args, strings.NewReader(""), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{"No command", nil, exitUsage, "", "Usage: titleparser <command>"},
+		{"Help", []string{"help"}, exitOK, "Commands:", ""},
+		{"Unknown command", []string{"fetch"}, exitUsage, "", `unknown command "fetch"`},
+		{"Get without URL", []string{"get"}, exitUsage, "", "get takes exactly one URL"},
+		{"Unknown flag", []string{"get", "--nope", "https://example.com/"}, exitUsage, "", "flag provided but not defined: -nope"},
+		{"Unknown handler", []string{"get", "--handler", "nope", "https://example.com/"}, exitUsage, "", `no handler named "nope"`},
+		{"Unknown cache action", []string{"cache", "drop", "https://example.com/"}, exitUsage, "", `unknown cache action "drop"`},
+		{"Serve with arguments", []string{"serve", "now"}, exitUsage, "", "serve takes no arguments"},
+		{"Stream with invalid order", []string{"stream", "--order", "random"}, exitUsage, "", `invalid order "random"`},
+		{"IRC without config", []string{"irc", "--config", ""}, exitUsage, "", "no IRC configuration"},
+		{"IRC with invalid config", []string{"irc", "--config", "testdata/missing.yaml"}, exitUsage, "", "Could not read IRC config file"},
+		{"Validate without files", []string{"validate-rules"}, exitUsage, "", "no rule files given"},
+		{"Validate bundled rules", []string{"validate-rules", "../rules/default.yaml"}, exitOK, "../rules/default.yaml: 1 rules OK", ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			code, stdout, stderr := run(t, tt.args...)
+			if code != tt.wantCode {
+				t.Errorf("Run() = %d, want %d", code, tt.wantCode)
+			}
+			if !strings.Contains(stdout, tt.wantStdout) {
+				t.Errorf("stdout = %q, want it to contain %q", stdout, tt.wantStdout)
+			}
+			if !strings.Contains(stderr, tt.wantStderr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr, tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantJSON bool
+		want     []string
+	}{
+		{"Flag first", []string{"--json", "a"}, true, []string{"a"}},
+		{"Flag last", []string{"a", "--json"}, true, []string{"a"}},
+		{"Flag between", []string{"get", "-json", "a"}, true, []string{"get", "a"}},
+		{"No flags", []string{"a", "b"}, false, []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			asJSON := fs.Bool("json", false, "")
+			got, err := parse(fs, tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *asJSON != tt.wantJSON || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parse() = %v, json %v, want %v, json %v", got, *asJSON, tt.want, tt.wantJSON)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, "<html><head><title>Default title</title></head></html>")
+	}))
+	defer srv.Close()
+	t.Setenv("RUNMODE", "local")
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout []string
+	}{
+		{"Matching handler", []string{"get", srv.URL + "/cli/page"}, exitOK, []string{"Handled page\n"}},
+		{"Default handler", []string{"get", srv.URL + "/other"}, exitOK, []string{"Default title\n"}},
+		{"Forced handler", []string{"get", srv.URL + "/cli/page", "--handler", "default"}, exitOK, []string{"Default title\n"}},
+		{"Failed lookup", []string{"get", srv.URL + "/cli/fail"}, exitFailed, nil},
+		{"Disabled handler", []string{"get", srv.URL + "/cli-disabled/page"}, exitOK, []string{"Default title\n"}},
+		{"Forced disabled handler", []string{"get", "--handler", "test.Disabled", srv.URL + "/cli-disabled/page"}, exitFailed, nil},
+		{"Explain", []string{"get", "--explain", "--no-cache", srv.URL + "/cli/overlap"}, exitOK, []string{
+			"Handled overlap\n",
+			"handler:   test.CLI (pattern ^http://127\\.0\\.0\\.1:[0-9]+/cli/)\n",
+			"decision:  test.Overlap also matched with /cli/overlap, the first match is used\n",
+			"cache:     disabled\n",
+			"source:    handler\n",
+			"time:      policy ",
+		}},
+		{"Explain default handler", []string{"get", "--explain", srv.URL + "/other"}, exitOK, []string{
+			"Default title\n",
+			"handler:    default\n",
+			"response:   200 text/html; charset=utf-8\n",
+			"source:     title\n",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, stdout, stderr := run(t, tt.args...)
+			if code != tt.wantCode {
+				t.Errorf("Run() = %d, want %d, stderr %q", code, tt.wantCode, stderr)
+			}
+			for _, want := range tt.wantStdout {
+				if !strings.Contains(stdout, want) {
+					t.Errorf("stdout = %q, want it to contain %q", stdout, want)
+				}
+			}
+		})
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		code, stdout, _ := run(t, "get", "--json", "--channel", "#test", srv.URL+"/cli/fail")
+		if code != exitFailed {
+			t.Errorf("Run() = %d, want %d", code, exitFailed)
+		}
+		var res lambda.BatchItem
+		if err := json.Unmarshal([]byte(stdout), &res); err != nil {
+			t.Fatalf("invalid JSON %q: %v", stdout, err)
+		}
+		if res.Channel != "#test" || res.Error != "handler failed" {
+			t.Errorf("get --json = %+v", res)
+		}
+	})
+}
+
+func TestHandlersAndMatch(t *testing.T) {
+	t.Parallel()
+
+	code, stdout, _ := run(t, "handlers")
+	if code != exitOK || !strings.Contains(stdout, "test.CLI (enabled)") || !strings.Contains(stdout, "default (enabled)") ||
+		!strings.Contains(stdout, "test.Disabled (disabled: CLI_TEST_MISSING_KEY not set)") {
+		t.Errorf("handlers = %d, %q", code, stdout)
+	}
+
+	code, stdout, _ = run(t, "handlers", "--json")
+	var infos []lambda.HandlerInfo
+	if err := json.Unmarshal([]byte(stdout), &infos); err != nil || code != exitOK {
+		t.Fatalf("handlers --json = %d, %q: %v", code, stdout, err)
+	}
+	i := slices.IndexFunc(infos, func(h lambda.HandlerInfo) bool { return h.Name == "test.Overlap" })
+	if i < 0 || !reflect.DeepEqual(infos[i], lambda.HandlerInfo{Name: "test.Overlap", Patterns: []string{"/cli/overlap"}, Enabled: true}) {
+		t.Errorf("handlers --json = %+v", infos)
+	}
+
+	code, stdout, _ = run(t, "match", "http://127.0.0.1:1/cli/overlap")
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if code != exitOK || len(lines) != 3 || !strings.HasPrefix(lines[1], "test.CLI") || !strings.HasSuffix(lines[1], "used") || !strings.HasPrefix(lines[2], "test.Overlap") {
+		t.Errorf("match = %d, %q", code, stdout)
+	}
+
+	code, stdout, _ = run(t, "match", "https://disabled.example.com/cli-disabled/")
+	lines = strings.Split(strings.TrimSpace(stdout), "\n")
+	if code != exitOK || len(lines) != 3 || !strings.HasSuffix(lines[1], "disabled: CLI_TEST_MISSING_KEY not set") || !strings.HasPrefix(lines[2], "default") {
+		t.Errorf("match = %d, %q", code, stdout)
+	}
+
+	code, stdout, _ = run(t, "match", "https://unmatched.example.com/")
+	if code != exitOK || !strings.Contains(stdout, "the default handler is used") {
+		t.Errorf("match = %d, %q", code, stdout)
+	}
+}
@@ -0,0 +1,82 @@
+package common
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInternalAddress is returned when connecting to an internal address isn't allowed
+var ErrInternalAddress = errors.New("Internal address")
+
+var (
+	// publicTransport only connects to public addresses. There's no proxy,
+	// the address checked would be the proxy's.
+	publicTransport = newTransport(false)
+	// internalTransport connects anywhere, for URLs that are internal to begin with
+	internalTransport = newTransport(true)
+)
+
+// Transport returns the HTTP transport for fetching from the host. Internal
+// addresses are only allowed if the host itself is internal, the address is
+// checked when connecting so host names resolving to internal addresses are
+// caught too.
+func Transport(host string) http.RoundTripper {
+	if IsInternalHost(host) {
+		return internalTransport
+	}
+	return publicTransport
+}
+
+func newTransport(allowInternal bool) *http.Transport {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	if !allowInternal {
+		dialer.Control = rejectInternal
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
+
+// rejectInternal is a dialer control function refusing internal addresses
+func rejectInternal(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || IsInternalIP(ip) {
+		return errors.Wrap(ErrInternalAddress, host)
+	}
+	return nil
+}
+
+// IsInternalHost is true for localhost and loopback, private, shared and
+// link-local addresses, cloud metadata endpoints included. Host names are not
+// resolved.
+func IsInternalHost(host string) bool {
+	host = strings.ToLower(host)
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && IsInternalIP(ip)
+}
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), some cloud
+// providers serve their metadata endpoints from it
+var _, sharedAddressSpace, _ = net.ParseCIDR("100.64.0.0/10")
+
+// IsInternalIP is true for loopback, private, shared (CGNAT), link-local and
+// unspecified addresses
+func IsInternalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || sharedAddressSpace.Contains(ip) ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+}
@@ -0,0 +1,61 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestIsInternalHost(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"localhost", true},
+		{"app.localhost", true},
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"169.254.169.254", true},
+		{"100.100.100.200", true},
+		{"100.64.0.1", true},
+		{"100.63.255.255", false},
+		{"100.128.0.1", false},
+		{"::1", true},
+		{"0.0.0.0", true},
+		{"93.184.216.34", false},
+		{"example.com", false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.host, func(t *testing.T) {
+			t.Parallel()
+			if got := IsInternalHost(tt.host); got != tt.want {
+				t.Errorf("IsInternalHost(%q) = %v, want %v", tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransport(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(srv.Close)
+
+	// A public host name that resolves to a loopback address is refused when connecting
+	client := &http.Client{Transport: Transport("public.example.com")}
+	if _, err := client.Get(srv.URL); !errors.Is(err, ErrInternalAddress) {
+		t.Errorf("Get() error = %v, want %v", err, ErrInternalAddress)
+	}
+
+	client = &http.Client{Transport: Transport("127.0.0.1")}
+	res, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v, internal hosts should reach internal addresses", err)
+	}
+	res.Body.Close()
+}
@@ -0,0 +1,106 @@
+package common
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ParagraphSeparator joins paragraphs and line breaks when HTML is flattened to a single line
+const ParagraphSeparator = " / "
+
+// blockElements start a new paragraph
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true,
+	atom.Dd: true, atom.Div: true, atom.Dl: true, atom.Dt: true, atom.Figcaption: true,
+	atom.Figure: true, atom.Footer: true, atom.H1: true, atom.H2: true, atom.H3: true,
+	atom.H4: true, atom.H5: true, atom.H6: true, atom.Header: true, atom.Hr: true,
+	atom.Li: true, atom.Main: true, atom.Nav: true, atom.Ol: true, atom.P: true,
+	atom.Pre: true, atom.Section: true, atom.Table: true, atom.Td: true, atom.Th: true,
+	atom.Tr: true, atom.Ul: true,
+}
+
+// skippedElements never have visible text
+var skippedElements = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Template: true,
+	atom.Noscript: true, atom.Iframe: true, atom.Svg: true,
+}
+
+// HTMLToText converts an HTML fragment to plain text with one line per
+// paragraph or line break. Entities are decoded and links are shown as their
+// visible text, like a browser would show them.
+func HTMLToText(fragment string) string {
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		// The parser only fails on reader errors, which a string doesn't have
+		return ""
+	}
+
+	var lines []string
+	var line strings.Builder
+	breakLine := func() {
+		if text := strings.Join(strings.Fields(line.String()), " "); text != "" {
+			lines = append(lines, text)
+		}
+		line.Reset()
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			line.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			if skippedElements[n.DataAtom] || hasClass(n, "invisible") {
+				// Mastodon hides the scheme and the tail of long links
+				return
+			}
+			if n.DataAtom == atom.Br {
+				breakLine()
+				return
+			}
+		}
+
+		block := n.Type == html.ElementNode && blockElements[n.DataAtom]
+		if block {
+			breakLine()
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if n.Type == html.ElementNode && hasClass(n, "ellipsis") {
+			line.WriteString("…")
+		}
+		if block {
+			breakLine()
+		}
+	}
+
+	for _, n := range nodes {
+		walk(n)
+	}
+	breakLine()
+
+	return strings.Join(lines, "\n")
+}
+
+// HTMLToLine converts an HTML fragment to a single line of text,
+// paragraphs and line breaks are joined with ParagraphSeparator
+func HTMLToLine(fragment string) string {
+	return strings.ReplaceAll(HTMLToText(fragment), "\n", ParagraphSeparator)
+}
+
+func hasClass(n *html.Node, class string) bool {
+	for _, attr := range n.Attr {
+		if attr.Key == "class" {
+			for _, c := range strings.Fields(attr.Val) {
+				if c == class {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
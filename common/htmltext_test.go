@@ -0,0 +1,46 @@
+package common
+
+import "testing"
+
+func TestHTMLToText(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"Basic HTML tags", "<p>This is a <strong>test</strong> paragraph</p>", "This is a test paragraph"},
+		{"With attributes", `<div class="content">Hello <span style="color:red">world</span>!</div>`, "Hello world!"},
+		{"No HTML", "Just plain text", "Just plain text"},
+		{"Nested tags", "<div><p>Nested <em><strong>content</strong></em></p></div>", "Nested content"},
+		{"Extra spaces", "  <p>  Trim   spaces  </p>  ", "Trim spaces"},
+		{"Entities", "<p>Tom &amp; Jerry&#39;s &quot;show&quot; &lt;3 &hellip;</p>", `Tom & Jerry's "show" <3 …`},
+		{"Paragraphs", "<p>First</p><p>Second</p>", "First\nSecond"},
+		{"Line breaks", "<p>One<br>Two<br/>Three</p>", "One\nTwo\nThree"},
+		{"Links as text", `<p>See <a href="https://example.com/x">the docs</a> for more</p>`, "See the docs for more"},
+		{"Mastodon mention", `<p><span class="h-card"><a href="https://mastodon.social/@Gargron" class="u-url mention">@<span>Gargron</span></a></span> hi</p>`, "@Gargron hi"},
+		{"Mastodon link", `<p><a href="https://example.com/a/very/long/path"><span class="invisible">https://</span><span class="ellipsis">example.com/a/very/lo</span><span class="invisible">ng/path</span></a></p>`, "example.com/a/very/lo…"},
+		{"Scripts skipped", "<p>Text<script>alert(1)</script><style>p{}</style></p>", "Text"},
+		{"List items", "<ul><li>One</li><li>Two</li></ul>", "One\nTwo"},
+		{"Empty", "", ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := HTMLToText(tt.html); got != tt.want {
+				t.Errorf("HTMLToText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTMLToLine(t *testing.T) {
+	t.Parallel()
+
+	got := HTMLToLine("<p>Hyvää huomenta!</p><p>Tänään &amp; huomenna<br>sataa</p>")
+	if want := "Hyvää huomenta! / Tänään & huomenna / sataa"; got != want {
+		t.Errorf("HTMLToLine() = %q, want %q", got, want)
+	}
+}
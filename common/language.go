@@ -0,0 +1,85 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+type languagesKey struct{}
+
+// WithLanguages stores the preferred languages of the request, most preferred first
+func WithLanguages(ctx context.Context, languages []string) context.Context {
+	return context.WithValue(ctx, languagesKey{}, languages)
+}
+
+// Languages returns the preferred languages of the request, nil if there's no preference
+func Languages(ctx context.Context) []string {
+	languages, _ := ctx.Value(languagesKey{}).([]string)
+	return languages
+}
+
+// PrimaryLanguage returns the most preferred language, or an empty string
+func PrimaryLanguage(ctx context.Context) string {
+	if languages := Languages(ctx); len(languages) > 0 {
+		return languages[0]
+	}
+	return ""
+}
+
+// AcceptLanguageFor returns the Accept-Language header for the preferred
+// languages of the request: "fi, en;q=0.9, *;q=0.1". Anything goes if there
+// is no preference.
+func AcceptLanguageFor(ctx context.Context) string {
+	languages := Languages(ctx)
+	if len(languages) == 0 {
+		return AcceptLanguage
+	}
+
+	values := make([]string, 0, len(languages)+1)
+	for i, language := range languages {
+		if i == 0 {
+			values = append(values, language)
+			continue
+		}
+		// quality goes down by 0.1 per language, but stays above the wildcard
+		q := 10 - i
+		if q < 2 {
+			q = 2
+		}
+		values = append(values, fmt.Sprintf("%s;q=0.%d", language, q))
+	}
+	values = append(values, "*;q=0.1")
+
+	return strings.Join(values, ", ")
+}
+
+// NormalizeLanguages cleans up a language list from user input:
+// "FI, en_gb,,en" becomes [fi en-GB en]
+func NormalizeLanguages(languages []string) []string {
+	var normalized []string
+	seen := make(map[string]bool)
+
+	for _, language := range languages {
+		for _, tag := range strings.Split(language, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "" || tag == "*" {
+				continue
+			}
+			base, region, found := strings.Cut(strings.ReplaceAll(tag, "_", "-"), "-")
+			tag = strings.ToLower(base)
+			if found && len(region) == 2 {
+				tag += "-" + strings.ToUpper(region)
+			} else if found && region != "" {
+				// script and variant subtags like zh-Hant are kept as they are
+				tag += "-" + region
+			}
+			if !seen[tag] {
+				seen[tag] = true
+				normalized = append(normalized, tag)
+			}
+		}
+	}
+
+	return normalized
+}
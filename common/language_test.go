@@ -0,0 +1,58 @@
+package common
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestAcceptLanguageFor(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		languages []string
+		want      string
+	}{
+		{"No preference", nil, "*"},
+		{"Single", []string{"fi"}, "fi, *;q=0.1"},
+		{"Multiple", []string{"fi", "sv", "en"}, "fi, sv;q=0.9, en;q=0.8, *;q=0.1"},
+		{"Quality floor", []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}, "a, b;q=0.9, c;q=0.8, d;q=0.7, e;q=0.6, f;q=0.5, g;q=0.4, h;q=0.3, i;q=0.2, j;q=0.2, *;q=0.1"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := WithLanguages(context.Background(), tt.languages)
+			if got := AcceptLanguageFor(ctx); got != tt.want {
+				t.Errorf("AcceptLanguageFor() = '%v', want '%v'", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeLanguages(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		languages []string
+		want      []string
+	}{
+		{"Empty", nil, nil},
+		{"Case and separators", []string{"FI", "en_gb"}, []string{"fi", "en-GB"}},
+		{"Comma separated", []string{"fi, sv,,en"}, []string{"fi", "sv", "en"}},
+		{"Duplicates", []string{"fi", "FI", "fi"}, []string{"fi"}},
+		{"Wildcard dropped", []string{"*", "en"}, []string{"en"}},
+		{"Script subtag", []string{"zh-Hant"}, []string{"zh-Hant"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := NormalizeLanguages(tt.languages); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NormalizeLanguages() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package common
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Text from web pages ends up verbatim in IRC and other chats, so anything
+// that can change how the rest of the line is displayed is removed: control
+// characters (IRC bold \x02, color \x03 etc. are all controls), bidi
+// overrides and invisible format characters.
+
+const (
+	// maxCombiningMarks per character, more than this is "Zalgo" text
+	maxCombiningMarks = 4
+	// ellipsis is added to truncated text
+	ellipsis = "..."
+)
+
+// SanitizeTitle cleans the text and truncates it to max characters
+func SanitizeTitle(s string, max int) string {
+	return Truncate(CleanText(s), max)
+}
+
+// CleanText removes control, bidi and invisible characters, collapses all
+// whitespace to single spaces and normalizes the text to NFC
+func CleanText(s string) string {
+	s = strings.ToValidUTF8(s, "")
+	s = stripIRCFormatting(s)
+
+	runes := []rune(s)
+	var b strings.Builder
+	b.Grow(len(s))
+
+	space := false
+	// prev is the last rune written, joiners and tags depend on it
+	var prev rune
+	for i, r := range runes {
+		switch {
+		case unicode.IsSpace(r):
+			space = true
+			continue
+		case unicode.IsControl(r), isBidi(r):
+			continue
+		case r == '\u200d' || r == '\u200c':
+			// Joiners are needed in emoji sequences and some scripts,
+			// but only between two visible characters
+			if space || !isVisible(prev) || i == len(runes)-1 || !isVisible(runes[i+1]) {
+				continue
+			}
+		case isTag(r):
+			// Tag characters only appear in subdivision flags, like Scotland's
+			if space || (prev != '\U0001F3F4' && !isTag(prev)) {
+				continue
+			}
+		case unicode.Is(unicode.Cf, r):
+			// zero-width spaces, word joiners, BOM, soft hyphens etc.
+			continue
+		}
+
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(r)
+		prev = r
+	}
+
+	return limitCombiningMarks(norm.NFC.String(b.String()))
+}
+
+// Truncate cuts the text to at most max user-perceived characters including
+// the added ellipsis, at a word boundary if there is one near the end.
+// Emoji, flags and accented characters are never split.
+func Truncate(s string, max int) string {
+	if max <= 0 {
+		return ""
+	}
+	if uniseg.GraphemeClusterCount(s) <= max {
+		return s
+	}
+	if max <= len(ellipsis) {
+		return ellipsis[:max]
+	}
+
+	limit := max - len(ellipsis)
+	end, lastSpace, count := 0, -1, 0
+	graphemes := uniseg.NewGraphemes(s)
+	for count < limit && graphemes.Next() {
+		from, to := graphemes.Positions()
+		if strings.TrimSpace(graphemes.Str()) == "" {
+			lastSpace = from
+		}
+		end = to
+		count++
+	}
+
+	cut := s[:end]
+	// Prefer a word boundary, unless it would throw away too much of the text
+	if lastSpace > 0 && lastSpace >= end*2/3 && !strings.HasPrefix(s[end:], " ") {
+		cut = s[:lastSpace]
+	}
+
+	return strings.TrimRightFunc(cut, func(r rune) bool {
+		return unicode.IsSpace(r) || r == ',' || r == ';' || r == ':' || r == '-'
+	}) + ellipsis
+}
+
+// stripIRCFormatting removes mIRC color codes with their color numbers,
+// the control characters themselves are removed with all the others
+func stripIRCFormatting(s string) string {
+	if !strings.ContainsAny(s, "\x03\x04") {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\x03':
+			// \x03FF,BB with one or two digit colors
+			i += skipColor(s[i+1:], 2, isDigit)
+		case '\x04':
+			// \x04RRGGBB,RRGGBB hex colors
+			i += skipColor(s[i+1:], 6, isHexDigit)
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// skipColor returns the length of the foreground[,background] color code
+func skipColor(s string, digits int, valid func(byte) bool) int {
+	n := countPrefix(s, digits, valid)
+	if n > 0 && len(s) > n+1 && s[n] == ',' {
+		if bg := countPrefix(s[n+1:], digits, valid); bg > 0 {
+			n += 1 + bg
+		}
+	}
+	return n
+}
+
+func countPrefix(s string, max int, valid func(byte) bool) int {
+	n := 0
+	for n < len(s) && n < max && valid(s[n]) {
+		n++
+	}
+	return n
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// isBidi is true for the explicit directional formatting characters
+// that can be used to visually reorder the rest of the line
+func isBidi(r rune) bool {
+	return (r >= '\u202a' && r <= '\u202e') || (r >= '\u2066' && r <= '\u2069') ||
+		r == '\u200e' || r == '\u200f' || r == '\u061c'
+}
+
+func isTag(r rune) bool {
+	return r >= '\U000E0020' && r <= '\U000E007F'
+}
+
+func isVisible(r rune) bool {
+	return r != 0 && !unicode.IsSpace(r) && !unicode.IsControl(r) && !unicode.Is(unicode.Cf, r) && r != utf8.RuneError
+}
+
+// limitCombiningMarks drops excess combining marks stacked on a single character
+func limitCombiningMarks(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+
+	marks := 0
+	for _, r := range s {
+		if unicode.Is(unicode.Mn, r) {
+			marks++
+			if marks > maxCombiningMarks {
+				continue
+			}
+		} else {
+			marks = 0
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,125 @@
+package common
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestCleanText(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"Plain", "Hello world", "Hello world"},
+		{"Whitespace", "  Hello\n\t world\r\n ", "Hello world"},
+		{"No-break space", "10\u00a0km", "10 km"},
+		{"IRC bold and reset", "\x02bold\x02 \x1ditalic\x0f", "bold italic"},
+		{"IRC colors", "\x0304,12red on blue\x03 and \x033green", "red on blue and green"},
+		{"IRC hex colors", "\x04FF0000,00FF00hex", "hex"},
+		{"Comma after color", "\x034,text", ",text"},
+		{"Bidi override", "evil\u202etxt.exe", "eviltxt.exe"},
+		{"Bidi isolates", "\u2067abc\u2069 \u200fdef", "abc def"},
+		{"Zero width", "pass\u200bword\ufeff\u2060", "password"},
+		{"Soft hyphen", "Kauko\u00adohjattava", "Kaukoohjattava"},
+		{"Emoji ZWJ sequence", "family \U0001F468\u200d\U0001F469\u200d\U0001F467", "family \U0001F468\u200d\U0001F469\u200d\U0001F467"},
+		{"Dangling ZWJ", "\u200dword\u200d ", "word"},
+		{"Subdivision flag", "\U0001F3F4\U000E0067\U000E0062\U000E0073\U000E0063\U000E0074\U000E007F", "\U0001F3F4\U000E0067\U000E0062\U000E0073\U000E0063\U000E0074\U000E007F"},
+		{"Stray tags", "a\U000E0041b", "ab"},
+		{"NFC", "a\u0308iti", "äiti"},
+		{"Zalgo", "Z\u0300\u0301\u0302\u0303\u0304\u0305\u0306\u0307o", "Z\u0300\u0301\u0302\u0303o"},
+		{"Invalid UTF-8", "bad\xffbyte", "badbyte"},
+		{"Line separator", "one\u2028two", "one two"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := CleanText(tt.text); got != tt.want {
+				t.Errorf("CleanText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		text string
+		max  int
+		want string
+	}{
+		{"Short enough", "Jätteiden mukana", 16, "Jätteiden mukana"},
+		{"Word boundary", "Jätteiden mukana palaa miljoonien edestä metalleja", 30, "Jätteiden mukana palaa..."},
+		{"Multi-byte rune at the cut", "ääääääääää", 8, "äääää..."},
+		{"Long word", "Kolmivaihekilowattituntimittari on pitkä sana", 12, "Kolmivaih..."},
+		{"Emoji not split", "\U0001F468\u200d\U0001F469\u200d\U0001F467\U0001F468\u200d\U0001F469\u200d\U0001F467\U0001F468\u200d\U0001F469\u200d\U0001F467\U0001F468\u200d\U0001F469\u200d\U0001F467\U0001F468\u200d\U0001F469\u200d\U0001F467", 4, "\U0001F468\u200d\U0001F469\u200d\U0001F467..."},
+		{"Flags not split", "\U0001F1EB\U0001F1EE\U0001F1F8\U0001F1EA\U0001F1F3\U0001F1F4\U0001F1E9\U0001F1F0\U0001F1EE\U0001F1F8", 4, "\U0001F1EB\U0001F1EE..."},
+		{"Trailing punctuation", "one, two, three, four", 13, "one, two..."},
+		{"Tiny max", "Hello world", 2, ".."},
+		{"Zero", "Hello", 0, ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := Truncate(tt.text, tt.max)
+			if got != tt.want {
+				t.Errorf("Truncate() = %q, want %q", got, tt.want)
+			}
+			if n := uniseg.GraphemeClusterCount(got); n > tt.max {
+				t.Errorf("Truncate() = %d characters, max %d", n, tt.max)
+			}
+		})
+	}
+}
+
+func FuzzSanitizeTitle(f *testing.F) {
+	for _, seed := range []string{
+		"Plain title",
+		"\x02\x0304,12IRC\x0f \u202eevil",
+		"Jätteiden mukana palaa miljoonien edestä arvokkaita metalleja",
+		"\U0001F468\u200d\U0001F469\u200d\U0001F467 \U0001F3F4\U000E0067\U000E0062\U000E0073\U000E0063\U000E0074\U000E007F",
+		"a\u0308\u0300\u0301\u0302\u0303\u0304 \u200b\ufeff\xff",
+	} {
+		f.Add(seed, 20)
+	}
+
+	f.Fuzz(func(t *testing.T, text string, max int) {
+		if max < 0 || max > 300 {
+			return
+		}
+
+		got := SanitizeTitle(text, max)
+
+		if !utf8.ValidString(got) {
+			t.Fatalf("SanitizeTitle(%q) = %q, invalid UTF-8", text, got)
+		}
+		if n := uniseg.GraphemeClusterCount(got); n > max {
+			t.Fatalf("SanitizeTitle(%q, %d) = %q, %d characters", text, max, got, n)
+		}
+		if got != strings.TrimSpace(got) || strings.Contains(got, "  ") {
+			t.Fatalf("SanitizeTitle(%q) = %q, extra whitespace", text, got)
+		}
+		for _, r := range got {
+			if unicode.IsControl(r) || isBidi(r) || r == '\u200b' || r == '\ufeff' {
+				t.Fatalf("SanitizeTitle(%q) = %q, contains %U", text, got, r)
+			}
+		}
+		if cleaned := CleanText(text); CleanText(cleaned) != cleaned {
+			t.Fatalf("CleanText(%q) is not idempotent: %q", text, cleaned)
+		}
+		if !norm.NFC.IsNormalString(CleanText(text)) {
+			t.Fatalf("CleanText(%q) is not NFC", text)
+		}
+	})
+}
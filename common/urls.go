@@ -0,0 +1,144 @@
+package common
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Chat messages wrap links in all kinds of ways: "<https://...>", "(see
+// https://...)", "https://...!" and plain "www.example.com". Extraction
+// works on whitespace separated words, URLs never contain spaces.
+
+// trailingPunctuation is stripped from the end of URLs, it's almost always
+// part of the sentence and not the link
+const trailingPunctuation = ".,;:!?'\"*>"
+
+// brackets maps closing brackets to opening ones, a closing bracket at the
+// end is only part of the URL if it closes one inside the URL
+var brackets = map[byte]byte{')': '(', ']': '[', '}': '{'}
+
+// Link is a URL found in text
+type Link struct {
+	// URL is the normalized URL, links without a scheme get https
+	URL string
+	// Text is the link as written in the text
+	Text string
+}
+
+// ExtractURLs returns the unique URLs in the text in the order they appear.
+// Links without a scheme starting with "www." get https.
+func ExtractURLs(text string) []string {
+	var urls []string
+	seen := make(map[string]bool)
+
+	for _, link := range ExtractLinks(text) {
+		if seen[link.URL] {
+			continue
+		}
+		seen[link.URL] = true
+		urls = append(urls, link.URL)
+	}
+
+	return urls
+}
+
+// ExtractLinks returns every link in the text in the order they appear,
+// duplicates included
+func ExtractLinks(text string) []Link {
+	var links []Link
+
+	for _, word := range strings.Fields(text) {
+		for word != "" {
+			start, schemeless := urlStart(word)
+			if start < 0 {
+				break
+			}
+
+			candidate := word[start:]
+			rest := ""
+			// <url> is the standard way of delimiting links in plain text
+			if start > 0 && word[start-1] == '<' {
+				if end := strings.IndexByte(candidate, '>'); end >= 0 {
+					candidate, rest = candidate[:end], candidate[end+1:]
+				}
+			}
+			candidate = trimURL(candidate)
+			word = rest
+
+			link := Link{URL: candidate, Text: candidate}
+			if schemeless {
+				link.URL = "https://" + candidate
+			}
+			if !validURL(link.URL) {
+				continue
+			}
+			links = append(links, link)
+		}
+	}
+
+	return links
+}
+
+// urlStart finds where a URL starts in the word, schemeless is true for www. links
+func urlStart(word string) (int, bool) {
+	lower := strings.ToLower(word)
+
+	start := -1
+	for _, scheme := range []string{"http://", "https://"} {
+		if i := strings.Index(lower, scheme); i >= 0 && (start < 0 || i < start) {
+			start = i
+		}
+	}
+
+	// www. counts only at the start of a word, not in "awww.", or inside a URL with a scheme
+	www := -1
+	for i := strings.Index(lower, "www."); i >= 0; {
+		if i == 0 || !isWordByte(lower[i-1]) {
+			www = i
+			break
+		}
+		next := strings.Index(lower[i+1:], "www.")
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+
+	if www >= 0 && (start < 0 || www < start) {
+		return www, true
+	}
+	return start, false
+}
+
+// trimURL removes trailing punctuation and unbalanced closing brackets,
+// "(https://en.wikipedia.org/wiki/Go_(programming_language))" keeps one ")"
+func trimURL(s string) string {
+	for s != "" {
+		last := s[len(s)-1]
+		if strings.IndexByte(trailingPunctuation, last) >= 0 {
+			s = s[:len(s)-1]
+			continue
+		}
+		if open, ok := brackets[last]; ok && strings.Count(s, string(open)) < strings.Count(s, string(last)) {
+			s = s[:len(s)-1]
+			continue
+		}
+		break
+	}
+	return s
+}
+
+// validURL checks the candidate has a host that looks like a domain or an IP
+func validURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	return strings.Contains(host, ".") && !strings.HasPrefix(host, ".") && !strings.HasSuffix(host, ".")
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '-' || c == '.' || c == '/' ||
+		('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
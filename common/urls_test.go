@@ -0,0 +1,56 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractURLs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"No URLs", "just chatting here", nil},
+		{"Plain", "look at https://example.com/page", []string{"https://example.com/page"}},
+		{"Trailing punctuation", "see https://example.com/a. and https://example.com/b, or https://example.com/c!?", []string{"https://example.com/a", "https://example.com/b", "https://example.com/c"}},
+		{"Quoted", `he said "https://example.com/quote"`, []string{"https://example.com/quote"}},
+		{"Angle brackets", "<https://example.com/x>, <https://example.com/y>.", []string{"https://example.com/x", "https://example.com/y"}},
+		{"Two bracketed in one word", "<https://example.com/x>,<https://example.com/y>", []string{"https://example.com/x", "https://example.com/y"}},
+		{"Parentheses around", "(https://example.com/paren)", []string{"https://example.com/paren"}},
+		{"Wikipedia parentheses", "https://en.wikipedia.org/wiki/Go_(programming_language)", []string{"https://en.wikipedia.org/wiki/Go_(programming_language)"}},
+		{"Wikipedia in parentheses", "(https://en.wikipedia.org/wiki/Go_(programming_language))", []string{"https://en.wikipedia.org/wiki/Go_(programming_language)"}},
+		{"Markdown link", "[docs](https://example.com/docs)", []string{"https://example.com/docs"}},
+		{"Schemeless www", "www.example.com/page, nice", []string{"https://www.example.com/page"}},
+		{"Not www", "awww.cute", nil},
+		{"Upper case scheme", "HTTPS://EXAMPLE.COM/", []string{"HTTPS://EXAMPLE.COM/"}},
+		{"Duplicates", "https://example.com/ and again https://example.com/.", []string{"https://example.com/"}},
+		{"No host", "http:// and https://localhost", nil},
+		{"Query and fragment", "https://example.com/?q=a&b=c#top;", []string{"https://example.com/?q=a&b=c#top"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := ExtractURLs(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExtractURLs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractLinks(t *testing.T) {
+	t.Parallel()
+
+	got := ExtractLinks("www.example.com/page, <https://example.com/x> and www.example.com/page again")
+	want := []Link{
+		{URL: "https://www.example.com/page", Text: "www.example.com/page"},
+		{URL: "https://example.com/x", Text: "https://example.com/x"},
+		{URL: "https://www.example.com/page", Text: "www.example.com/page"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractLinks() = %+v, want %+v", got, want)
+	}
+}
@@ -1,78 +1,37 @@
 package handler
 
 import (
-	"fmt"
-	"net/http"
-	"time"
+	"context"
 
-	"github.com/dustin/go-humanize"
-
-	"github.com/PuerkitoBio/goquery"
 	"github.com/lepinkainen/titleparser/lambda"
-	"github.com/pkg/errors"
+	"github.com/lepinkainen/titleparser/locale"
 	log "github.com/sirupsen/logrus"
 )
 
-/*
-   <meta property="og:title" content="Muiden elämä">
-   <meta property="og:video:duration" content="7920">
-   <meta property="og:video:release_date" content="2021-02-10T06:00:00.000+02:00">
-*/
-
-// YleAreena handler TBD
-func YleAreena(url string) (string, error) {
-
-	res, err := http.Get(url)
-	if err != nil {
-		log.Error(err)
-		return "", errors.Wrap(err, "Could not load HTML")
-	}
-	defer func() {
-		if cerr := res.Body.Close(); cerr != nil {
-			log.Warnf("Failed to close response body: %v", cerr)
-		}
-	}()
-
-	// Load the HTML document
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+// YleAreena uses the generic OpenGraph video/audio metadata, but only trusts
+// og:title since the <title> element has the site name in it
+func YleAreena(ctx context.Context, url string) (string, error) {
+	doc, err := lambda.FetchDocument(ctx, url)
 	if err != nil {
 		log.Error(err)
-		return "", errors.Wrap(err, "Could not load HTML")
+		return "", err
 	}
 
-	var title, duration, release_date string
+	title, _ := doc.Find(`meta[property="og:title"]`).First().Attr("content")
 
-	// primarily we want to use og:title
-	s := doc.Find(`meta[property="og:title"]`)
-	if s != nil && s.Size() > 0 {
-		title, _ = s.Attr("content")
-	}
-
-	s = doc.Find(`meta[property="og:video:duration"]`)
-	if s != nil && s.Size() > 0 {
-		s_content, _ := s.Attr("content")
-		duration_time, _ := time.ParseDuration(fmt.Sprintf("%ss", s_content))
-		duration = duration_time.String()
-	}
-	s = doc.Find(`meta[property="og:video:release_date"]`)
-	if s != nil && s.Size() > 0 {
-		s_content, _ := s.Attr("content")
-		// 									  2018-12-25T06:00:00.000+02:00
-		release_date_time, err := time.Parse("2006-01-02T15:04:05.000Z07:00", s_content)
-		if err != nil {
-			log.Error("Error parsing release date: ", err)
-		}
-		release_date = humanize.RelTime(release_date_time, time.Now(), "ago", "")
-
-	}
-
-	if duration == "" || release_date == "" {
+	media := lambda.ExtractMedia(doc)
+	if media == nil {
 		return title, nil
-	} else {
-		return fmt.Sprintf("%s [Duration: %s Released: %s]", title, duration, release_date), nil
 	}
+
+	return media.Format(locale.From(ctx), title), nil
 }
 
 func init() {
-	lambda.RegisterHandler(".*?areena.yle.fi/.*", YleAreena)
+	lambda.Register(lambda.Handler{
+		Description: "Yle Areena programmes with duration, release date and series details from OpenGraph",
+		Patterns:    []string{".*?areena.yle.fi/.*"},
+		Examples:    []string{"https://areena.yle.fi/1-4192173", "https://areena.yle.fi/audio/1-1792200"},
+		Func:        YleAreena,
+	})
 }
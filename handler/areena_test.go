@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"regexp"
 	"testing"
 )
@@ -26,7 +27,7 @@ func TestYleAreena(t *testing.T) {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			got, err := YleAreena(tt.args.url)
+			got, err := YleAreena(context.Background(), tt.args.url)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("YleAreena() error = %v, wantErr %v", err, tt.wantErr)
 				return
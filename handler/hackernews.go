@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -8,7 +9,10 @@ import (
 	"regexp"
 	"time"
 
+	"github.com/lepinkainen/titleparser/common"
 	"github.com/lepinkainen/titleparser/lambda"
+	"github.com/lepinkainen/titleparser/locale"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -34,7 +38,7 @@ type HNAPIResponse struct {
 }
 
 // HackerNews titles using the API
-func HackerNews(url string) (string, error) {
+func HackerNews(ctx context.Context, url string) (string, error) {
 	storyID := hnRegex.FindStringSubmatch(url)
 
 	if len(storyID) < 2 {
@@ -43,14 +47,14 @@ func HackerNews(url string) (string, error) {
 
 	url = fmt.Sprintf(hnAPIURL, storyID[1])
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		log.Fatal("Error reading request. ", err)
+		return "", errors.Wrap(err, "error creating request")
 	}
 
 	// Set headers
 	req.Header.Set("User-Agent", UserAgent)
-	req.Header.Set("Accept-Language", AcceptLanguage)
+	req.Header.Set("Accept-Language", common.AcceptLanguageFor(ctx))
 	req.Header.Set("Accept", Accept)
 
 	// Set client timeout
@@ -59,8 +63,9 @@ func HackerNews(url string) (string, error) {
 	// Send request
 	res, err := client.Do(req)
 	if err != nil {
-		log.Fatal("Error reading response. ", err)
+		return "", errors.Wrap(err, "error querying Hacker News API")
 	}
+	lambda.RecordResponse(ctx, res)
 	defer func() {
 		if cerr := res.Body.Close(); cerr != nil {
 			log.Warnf("Failed to close response body: %v", cerr)
@@ -84,7 +89,8 @@ func HackerNews(url string) (string, error) {
 	if apiResponse.Type == "story" ||
 		apiResponse.Type == "poll" ||
 		apiResponse.Type == "job" {
-		return fmt.Sprintf("%s by %s [%d points]", apiResponse.Title, apiResponse.By, apiResponse.Score), nil
+		loc := locale.From(ctx)
+		return fmt.Sprintf("%s [%s]", loc.Message("by", apiResponse.Title, apiResponse.By), loc.Count("points", apiResponse.Score)), nil
 	}
 
 	return "", nil
@@ -92,5 +98,10 @@ func HackerNews(url string) (string, error) {
 
 // Register the handler function with corresponding regex
 func init() {
-	lambda.RegisterHandler(".*?news\\.ycombinator\\.com.*", HackerNews)
+	lambda.Register(lambda.Handler{
+		Description: "Hacker News stories from the Firebase API",
+		Patterns:    []string{".*?news\\.ycombinator\\.com.*"},
+		Examples:    []string{"https://news.ycombinator.com/item?id=23439437"},
+		Func:        HackerNews,
+	})
 }
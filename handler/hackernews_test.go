@@ -1,6 +1,9 @@
 package handler
 
-import "testing"
+import (
+	"context"
+	"testing"
+)
 
 func TestHackerNews(t *testing.T) {
 	t.Parallel()
@@ -22,7 +25,7 @@ func TestHackerNews(t *testing.T) {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			got, err := HackerNews(tt.args.url)
+			got, err := HackerNews(context.Background(), tt.args.url)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("HackerNews() error = %v, wantErr %v", err, tt.wantErr)
 				return
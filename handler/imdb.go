@@ -1,12 +1,14 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"regexp"
+	"time"
 
 	"github.com/lepinkainen/titleparser/lambda"
 	"github.com/pkg/errors"
@@ -37,7 +39,7 @@ type omdbReply struct {
 }
 
 // OMDB handler
-func OMDB(url string) (string, error) {
+func OMDB(ctx context.Context, url string) (string, error) {
 	omdbKey := os.Getenv("OMDB_KEY")
 	if omdbKey == "" {
 		return "", errors.New("No API key set for OMDB")
@@ -48,19 +50,24 @@ func OMDB(url string) (string, error) {
 		return "", errors.New("No title ID found in URL")
 	}
 
-	// Request the HTML page.
-	res, err := http.Get(fmt.Sprintf(omdbURL, id[1], omdbKey))
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf(omdbURL, id[1], omdbKey), nil)
 	if err != nil {
-		log.Fatal(err)
+		return "", errors.Wrap(err, "error creating request")
 	}
+
+	client := &http.Client{Timeout: time.Second * 10}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "error querying OMDB API")
+	}
+	lambda.RecordResponse(ctx, res)
 	defer func() {
 		if cerr := res.Body.Close(); cerr != nil {
 			log.Warnf("Failed to close response body: %v", cerr)
 		}
 	}()
-	if res.StatusCode != 200 {
-		log.Fatalf("status code error: %d %s", res.StatusCode, res.Status)
-		return "", errors.Wrap(err, "HTTP error")
+	if res.StatusCode != http.StatusOK {
+		return "", errors.Errorf("OMDB API returned non-OK status: %d", res.StatusCode)
 	}
 
 	// error ignored on purpose
@@ -98,5 +105,11 @@ func OMDB(url string) (string, error) {
 }
 
 func init() {
-	lambda.RegisterHandler(".*?imdb\\.com/title/tt.*", OMDB)
+	lambda.Register(lambda.Handler{
+		Description: "IMDb titles with year and IMDb, Rotten Tomatoes and Metacritic scores from the OMDb API",
+		Patterns:    []string{".*?imdb\\.com/title/tt.*"},
+		Examples:    []string{"https://www.imdb.com/title/tt0133093/"},
+		Env:         []string{"OMDB_KEY"},
+		Func:        OMDB,
+	})
 }
@@ -3,6 +3,7 @@
 package handler
 
 import (
+	"context"
 	"regexp"
 	"testing"
 )
@@ -49,7 +50,7 @@ func TestOMDB(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			got, err := OMDB(tt.args.url)
+			got, err := OMDB(context.Background(), tt.args.url)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("OMDB() error = %v, wantErr %v", err, tt.wantErr)
 				return
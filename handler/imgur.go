@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -10,6 +11,8 @@ import (
 	"time"
 
 	"github.com/lepinkainen/titleparser/lambda"
+	"github.com/lepinkainen/titleparser/locale"
+	"github.com/pkg/errors"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -134,7 +137,7 @@ type ImgurResponse struct {
 func getAPIResponse(category, id string) (ImgurResponse, error) {
 	req, err := http.NewRequest("GET", fmt.Sprintf("https://api.imgur.com/3/%s/%s", category, id), nil)
 	if err != nil {
-		log.Fatal("Error reading request. ", err)
+		return ImgurResponse{}, errors.Wrap(err, "error creating request")
 	}
 
 	// Set headers
@@ -147,7 +150,7 @@ func getAPIResponse(category, id string) (ImgurResponse, error) {
 	// Send request
 	res, err := client.Do(req)
 	if err != nil {
-		log.Fatal("Error reading response. ", err)
+		return ImgurResponse{}, errors.Wrap(err, "error querying Imgur API")
 	}
 	defer func() {
 		if cerr := res.Body.Close(); cerr != nil {
@@ -163,7 +166,7 @@ func getAPIResponse(category, id string) (ImgurResponse, error) {
 }
 
 // https://api.imgur.com/models/gallery_album
-func imgurGallery(id string) (string, error) {
+func imgurGallery(loc *locale.Locale, id string) (string, error) {
 	apiResponse, err := getAPIResponse("gallery", id)
 	if err != nil {
 		return "", err
@@ -171,14 +174,14 @@ func imgurGallery(id string) (string, error) {
 
 	title := apiResponse.Data.Title
 	if apiResponse.Data.ImagesCount > 1 {
-		title = fmt.Sprintf("%s [%d images]", title, apiResponse.Data.ImagesCount)
+		title = fmt.Sprintf("%s [%s]", title, loc.Count("images", apiResponse.Data.ImagesCount))
 	}
 	if len(apiResponse.Data.Tags) > 0 {
 		tags := []string{}
 		for _, tag := range apiResponse.Data.Tags {
 			tags = append(tags, tag.DisplayName)
 		}
-		title = fmt.Sprintf("%s [tags: %s]", title, strings.Join(tags, ", "))
+		title = fmt.Sprintf("%s [%s]", title, loc.Message("tags", strings.Join(tags, ", ")))
 	}
 
 	return title, nil
@@ -186,7 +189,7 @@ func imgurGallery(id string) (string, error) {
 
 // Just a normal album, not in the public gallery(?)
 // https://api.imgur.com/models/album
-func imgurAlbum(id string) (string, error) {
+func imgurAlbum(loc *locale.Locale, id string) (string, error) {
 	apiResponse, err := getAPIResponse("album", id)
 	if err != nil {
 		return "", err
@@ -194,14 +197,14 @@ func imgurAlbum(id string) (string, error) {
 
 	title := apiResponse.Data.Title
 	if apiResponse.Data.ImagesCount > 1 {
-		title = fmt.Sprintf("%s [%d images]", title, apiResponse.Data.ImagesCount)
+		title = fmt.Sprintf("%s [%s]", title, loc.Count("images", apiResponse.Data.ImagesCount))
 	}
 	if len(apiResponse.Data.Tags) > 0 {
 		tags := []string{}
 		for _, tag := range apiResponse.Data.Tags {
 			tags = append(tags, tag.DisplayName)
 		}
-		title = fmt.Sprintf("%s [tags: %s]", title, strings.Join(tags, ", "))
+		title = fmt.Sprintf("%s [%s]", title, loc.Message("tags", strings.Join(tags, ", ")))
 	}
 
 	return title, nil
@@ -209,7 +212,7 @@ func imgurAlbum(id string) (string, error) {
 
 // Subreddit images have a special gallery for each "section"
 // Returns: title [/r/subreddit]
-func subredditImage(section, id string) (string, error) {
+func subredditImage(loc *locale.Locale, section, id string) (string, error) {
 	apiResponse, err := getAPIResponse(fmt.Sprintf("gallery/r/%s", section), id)
 	if err != nil {
 		return "", err
@@ -223,7 +226,7 @@ func subredditImage(section, id string) (string, error) {
 		for _, tag := range apiResponse.Data.Tags {
 			tags = append(tags, tag.DisplayName)
 		}
-		title = fmt.Sprintf("%s [tags: %s]", title, strings.Join(tags, ", "))
+		title = fmt.Sprintf("%s [%s]", title, loc.Message("tags", strings.Join(tags, ", ")))
 	}
 
 	title = fmt.Sprintf("%s [/r/%s]", title, section)
@@ -233,7 +236,7 @@ func subredditImage(section, id string) (string, error) {
 
 // Subreddit images have a special gallery for each "section"
 // Returns: title [/r/subreddit]
-func tagImage(section, id string) (string, error) {
+func tagImage(loc *locale.Locale, section, id string) (string, error) {
 	apiResponse, err := getAPIResponse(fmt.Sprintf("gallery/t/%s", section), id)
 	if err != nil {
 		return "", err
@@ -246,7 +249,7 @@ func tagImage(section, id string) (string, error) {
 		for _, tag := range apiResponse.Data.Tags {
 			tags = append(tags, tag.DisplayName)
 		}
-		title = fmt.Sprintf("%s [tags: %s]", title, strings.Join(tags, ", "))
+		title = fmt.Sprintf("%s [%s]", title, loc.Message("tags", strings.Join(tags, ", ")))
 	}
 
 	//title = fmt.Sprintf("%s [/t/%s]", title, section)
@@ -256,7 +259,7 @@ func tagImage(section, id string) (string, error) {
 
 // Image page link
 // Returns: title [tags: 1, 2, 3]
-func imgurImage(id string) (string, error) {
+func imgurImage(loc *locale.Locale, id string) (string, error) {
 	apiResponse, err := getAPIResponse("image", id)
 	if err != nil {
 		return "", err
@@ -266,7 +269,7 @@ func imgurImage(id string) (string, error) {
 
 	// No title, but section exists -> subreddit gallery image
 	if title == "" && apiResponse.Data.Section != "" {
-		return subredditImage(apiResponse.Data.Section, id)
+		return subredditImage(loc, apiResponse.Data.Section, id)
 	}
 
 	if len(apiResponse.Data.Tags) > 0 {
@@ -274,33 +277,34 @@ func imgurImage(id string) (string, error) {
 		for _, tag := range apiResponse.Data.Tags {
 			tags = append(tags, tag.DisplayName)
 		}
-		title = fmt.Sprintf("%s [tags: %s]", title, strings.Join(tags, ", "))
+		title = fmt.Sprintf("%s [%s]", title, loc.Message("tags", strings.Join(tags, ", ")))
 	}
 
 	return title, nil
 }
 
 // Imgur titles are always useless, just don't return anything
-func Imgur(url string) (string, error) {
+func Imgur(ctx context.Context, url string) (string, error) {
+	loc := locale.From(ctx)
 
 	match := galleryRegex.FindStringSubmatch(url)
 	if len(match) > 0 {
-		return imgurGallery(match[1])
+		return imgurGallery(loc, match[1])
 	}
 
 	match = albumRegex.FindStringSubmatch(url)
 	if len(match) > 0 {
-		return imgurAlbum(match[1])
+		return imgurAlbum(loc, match[1])
 	}
 
 	match = tagRegex.FindStringSubmatch(url)
 	if len(match) > 0 {
-		return tagImage(match[1], match[2])
+		return tagImage(loc, match[1], match[2])
 	}
 
 	match = imageRegex.FindStringSubmatch(url)
 	if len(match) > 0 {
-		return imgurImage(match[1])
+		return imgurImage(loc, match[1])
 	}
 
 	// Direct image links don't seem to have title information
@@ -311,8 +315,11 @@ func Imgur(url string) (string, error) {
 
 // Register the handler function with corresponding regex
 func init() {
-	if os.Getenv("IMGUR_KEY") != "" {
-		lambda.RegisterHandler(".*?imgur\\.com.*", Imgur)
-	}
-	_ = fmt.Errorf("IMGUR_KEY not set, handler inactive")
+	lambda.Register(lambda.Handler{
+		Description: "Imgur galleries, albums and images with tags and subreddit from the Imgur API",
+		Patterns:    []string{".*?imgur\\.com.*"},
+		Examples:    []string{"https://imgur.com/gallery/md2Sxjm", "https://imgur.com/a/MZY7mkE"},
+		Env:         []string{"IMGUR_KEY"},
+		Func:        Imgur,
+	})
 }
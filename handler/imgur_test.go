@@ -3,6 +3,7 @@
 package handler
 
 import (
+	"context"
 	"testing"
 )
 
@@ -34,7 +35,7 @@ func TestImgur(t *testing.T) {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			got, err := Imgur(tt.args.url)
+			got, err := Imgur(context.Background(), tt.args.url)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Imgur() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -10,8 +11,9 @@ import (
 	"strings"
 	"time"
 
-	"github.com/dustin/go-humanize"
+	"github.com/lepinkainen/titleparser/common"
 	"github.com/lepinkainen/titleparser/lambda"
+	"github.com/lepinkainen/titleparser/locale"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
@@ -123,12 +125,12 @@ func parseMastodonURL(postURL string) (string, string, string, error) {
 }
 
 // fetchStatusInfo fetches status information from the Mastodon API
-func fetchStatusInfo(instance, statusID string) (*MastodonStatus, error) {
+func fetchStatusInfo(ctx context.Context, instance, statusID string) (*MastodonStatus, error) {
 	// Construct the API URL
 	apiURL := fmt.Sprintf("https://%s/api/v1/statuses/%s", instance, statusID)
 
 	// Create a request with proper headers
-	req, err := http.NewRequest("GET", apiURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
 	if err != nil {
 		return nil, errors.Wrap(err, "error creating request")
 	}
@@ -145,6 +147,7 @@ func fetchStatusInfo(instance, statusID string) (*MastodonStatus, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "error sending request")
 	}
+	lambda.RecordResponse(ctx, res)
 	defer func() {
 		if cerr := res.Body.Close(); cerr != nil {
 			log.Warnf("Failed to close response body: %v", cerr)
@@ -171,40 +174,32 @@ func fetchStatusInfo(instance, statusID string) (*MastodonStatus, error) {
 	return &status, nil
 }
 
-// stripHTML removes HTML tags from a string
-func stripHTML(html string) string {
-	// Simple but effective for our needs
-	re := regexp.MustCompile("<[^>]*>")
-	text := re.ReplaceAllString(html, "")
-	return strings.TrimSpace(text)
-}
-
 // Mastodon extracts information from a Mastodon post URL using the API
-func Mastodon(url string) (string, error) {
+func Mastodon(ctx context.Context, url string) (string, error) {
 	// Parse the Mastodon URL
 	instance, _, statusID, err := parseMastodonURL(url)
 	if err != nil {
 		log.WithError(err).Error("Failed to parse Mastodon URL")
-		return fallbackToScraping(url)
+		return fallbackToScraping(ctx, url)
 	}
 
 	// Fetch status information
-	status, err := fetchStatusInfo(instance, statusID)
+	status, err := fetchStatusInfo(ctx, instance, statusID)
 	if err != nil {
 		log.WithError(err).Error("Failed to fetch status info from API")
-		return fallbackToScraping(url)
+		return fallbackToScraping(ctx, url)
 	}
 
-	// Process the content
-	content := stripHTML(status.Content)
+	// Process the content, paragraphs and line breaks are kept as separators
+	content := common.HTMLToLine(status.Content)
 
 	// Truncate content if too long
-	if len(content) > 100 {
-		content = content[:97] + "..."
-	}
+	content = common.Truncate(common.CleanText(content), 100)
+
+	loc := locale.From(ctx)
 
 	// Format the relative time
-	timestamp := humanize.RelTime(status.CreatedAt, time.Now(), "ago", "")
+	timestamp := loc.RelTime(status.CreatedAt, time.Now())
 
 	// Build title with rich information
 	title := fmt.Sprintf("@%s", status.Account.DisplayName)
@@ -217,13 +212,13 @@ func Mastodon(url string) (string, error) {
 	// Add engagement info
 	engagement := []string{}
 	if status.ReblogsCount > 0 {
-		engagement = append(engagement, fmt.Sprintf("%d boosts", status.ReblogsCount))
+		engagement = append(engagement, loc.Count("boosts", status.ReblogsCount))
 	}
 	if status.FavoritesCount > 0 {
-		engagement = append(engagement, fmt.Sprintf("%d favs", status.FavoritesCount))
+		engagement = append(engagement, loc.Count("favs", status.FavoritesCount))
 	}
 	if status.RepliesCount > 0 {
-		engagement = append(engagement, fmt.Sprintf("%d replies", status.RepliesCount))
+		engagement = append(engagement, loc.Count("replies", status.RepliesCount))
 	}
 
 	// Add media info
@@ -236,11 +231,11 @@ func Mastodon(url string) (string, error) {
 
 		mediaLabels := []string{}
 		for mediaType, count := range types {
-			if count > 1 {
-				mediaLabels = append(mediaLabels, fmt.Sprintf("%d %ss", count, mediaType))
-			} else {
-				mediaLabels = append(mediaLabels, mediaType)
+			id := "media." + mediaType
+			if !loc.Has(id) {
+				id = "media.other"
 			}
+			mediaLabels = append(mediaLabels, loc.Count(id, count))
 		}
 
 		if len(mediaLabels) > 0 {
@@ -254,7 +249,7 @@ func Mastodon(url string) (string, error) {
 	}
 
 	if mediaInfo != "" {
-		title += fmt.Sprintf(" [Media: %s]", mediaInfo)
+		title += fmt.Sprintf(" [%s]", loc.Message("media", mediaInfo))
 	}
 
 	title += fmt.Sprintf(" [%s]", timestamp)
@@ -268,76 +263,27 @@ func Mastodon(url string) (string, error) {
 }
 
 // fallbackToScraping falls back to the old HTML scraping method if the API call fails
-func fallbackToScraping(url string) (string, error) {
-	// Create a request with proper headers
-	req, err := http.NewRequest("GET", url, nil)
+func fallbackToScraping(ctx context.Context, url string) (string, error) {
+	doc, err := lambda.FetchDocument(ctx, url)
 	if err != nil {
-		log.Error("Error creating request: ", err)
+		log.Error("Error fetching page: ", err)
 		return "", err
 	}
 
-	// Set headers
-	req.Header.Set("User-Agent", UserAgent)
-	req.Header.Set("Accept-Language", AcceptLanguage)
-	req.Header.Set("Accept", Accept)
-
-	// Set client timeout
-	client := &http.Client{Timeout: time.Second * 10}
-
-	// Send request
-	res, err := client.Do(req)
-	if err != nil {
-		log.Error("Error sending request: ", err)
-		return "", err
-	}
-	defer func() {
-		if cerr := res.Body.Close(); cerr != nil {
-			log.Warnf("Failed to close response body: %v", cerr)
-		}
-	}()
-
-	// Read the response body
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		log.Error("Error reading response body: ", err)
-		return "", err
-	}
-
-	// Try to find the OpenGraph title
-	ogTitle := extractOpenGraphTitle(string(body))
-	if ogTitle != "" {
-		return ogTitle, nil
+	// OpenGraph title, or just the page title as a last resort
+	title, err := lambda.TitleFromDocument(doc)
+	if err != nil || title == "" {
+		return "Mastodon Post", nil
 	}
 
-	// Extract just the page title as a last resort
-	title := extractTitle(string(body))
-	if title != "" {
-		return title, nil
-	}
-
-	return "Mastodon Post", nil
-}
-
-// extractOpenGraphTitle extracts the OpenGraph title from HTML
-func extractOpenGraphTitle(html string) string {
-	re := regexp.MustCompile(`<meta\s+property="og:title"\s+content="([^"]+)"`)
-	matches := re.FindStringSubmatch(html)
-	if len(matches) > 1 {
-		return matches[1]
-	}
-	return ""
-}
-
-// extractTitle extracts the page title from HTML
-func extractTitle(html string) string {
-	re := regexp.MustCompile(`<title>([^<]+)</title>`)
-	matches := re.FindStringSubmatch(html)
-	if len(matches) > 1 {
-		return matches[1]
-	}
-	return ""
+	return title, nil
 }
 
 func init() {
-	lambda.RegisterHandler(MastodonMatch, Mastodon)
+	lambda.Register(lambda.Handler{
+		Description: "Mastodon posts on any instance, from the API with OpenGraph as a fallback",
+		Patterns:    []string{MastodonMatch},
+		Examples:    []string{"https://mastodon.social/@username/123456789"},
+		Func:        Mastodon,
+	})
 }
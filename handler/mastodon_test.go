@@ -111,130 +111,3 @@ func TestParseMastodonURL(t *testing.T) {
 		})
 	}
 }
-
-func TestStripHTML(t *testing.T) {
-	t.Parallel()
-
-	testCases := []struct {
-		name     string
-		html     string
-		expected string
-	}{
-		{
-			name:     "Basic HTML tags",
-			html:     "<p>This is a <strong>test</strong> paragraph</p>",
-			expected: "This is a test paragraph",
-		},
-		{
-			name:     "With attributes",
-			html:     "<div class=\"content\">Hello <span style=\"color:red\">world</span>!</div>",
-			expected: "Hello world!",
-		},
-		{
-			name:     "No HTML",
-			html:     "Just plain text",
-			expected: "Just plain text",
-		},
-		{
-			name:     "Nested tags",
-			html:     "<div><p>Nested <em><strong>content</strong></em></p></div>",
-			expected: "Nested content",
-		},
-		{
-			name:     "With extra spaces",
-			html:     "  <p>  Trim   spaces  </p>  ",
-			expected: "Trim   spaces",
-		},
-	}
-
-	for _, tc := range testCases {
-		tc := tc // Capture range variable for parallel execution
-		t.Run(tc.name, func(t *testing.T) {
-			t.Parallel()
-
-			result := stripHTML(tc.html)
-
-			if result != tc.expected {
-				t.Errorf("stripHTML() = %q, want %q", result, tc.expected)
-			}
-		})
-	}
-}
-
-func TestExtractOpenGraphTitle(t *testing.T) {
-	t.Parallel()
-
-	testCases := []struct {
-		name     string
-		html     string
-		expected string
-	}{
-		{
-			name:     "Valid OG title",
-			html:     "<html><head><meta property=\"og:title\" content=\"This is the OG title\"></head><body></body></html>",
-			expected: "This is the OG title",
-		},
-		{
-			name:     "No OG title",
-			html:     "<html><head><title>Page Title</title></head><body></body></html>",
-			expected: "",
-		},
-		{
-			name:     "Empty OG title",
-			html:     "<html><head><meta property=\"og:title\" content=\"\"></head><body></body></html>",
-			expected: "",
-		},
-	}
-
-	for _, tc := range testCases {
-		tc := tc // Capture range variable for parallel execution
-		t.Run(tc.name, func(t *testing.T) {
-			t.Parallel()
-
-			result := extractOpenGraphTitle(tc.html)
-
-			if result != tc.expected {
-				t.Errorf("extractOpenGraphTitle() = %q, want %q", result, tc.expected)
-			}
-		})
-	}
-}
-
-func TestExtractTitle(t *testing.T) {
-	t.Parallel()
-
-	testCases := []struct {
-		name     string
-		html     string
-		expected string
-	}{
-		{
-			name:     "Valid title",
-			html:     "<html><head><title>Page Title</title></head><body></body></html>",
-			expected: "Page Title",
-		},
-		{
-			name:     "No title",
-			html:     "<html><head></head><body></body></html>",
-			expected: "",
-		},
-		{
-			name:     "Empty title",
-			html:     "<html><head><title></title></head><body></body></html>",
-			expected: "",
-		},
-	}
-
-	for _, tc := range testCases {
-		tc := tc // Capture range variable for parallel execution
-		t.Run(tc.name, func(t *testing.T) {
-			t.Parallel()
-
-			result := extractTitle(tc.html)
-
-			if result != tc.expected {
-				t.Errorf("extractTitle() = %q, want %q", result, tc.expected)
-			}
-		})
-	}
-}
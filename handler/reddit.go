@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -8,7 +9,9 @@ import (
 	"strings"
 	"time"
 
-	"github.com/dustin/go-humanize"
+	"github.com/lepinkainen/titleparser/common"
+	"github.com/lepinkainen/titleparser/locale"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -193,7 +196,7 @@ type RedditPost []struct {
 }
 
 // followRedirects follows HTTP redirects from v.redd.it URLs to get the final Reddit post URL
-func followRedirects(url string) (string, error) {
+func followRedirects(ctx context.Context, url string) (string, error) {
 	client := &http.Client{
 		Timeout: time.Second * 10,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
@@ -206,13 +209,13 @@ func followRedirects(url string) (string, error) {
 	}
 
 	// Set proper headers to avoid blocking
-	req, err := http.NewRequest("HEAD", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
 	if err != nil {
 		return "", fmt.Errorf("error creating request: %w", err)
 	}
 
 	req.Header.Set("User-Agent", UserAgent)
-	req.Header.Set("Accept-Language", AcceptLanguage)
+	req.Header.Set("Accept-Language", common.AcceptLanguageFor(ctx))
 	req.Header.Set("Accept", Accept)
 
 	resp, err := client.Do(req)
@@ -235,10 +238,10 @@ func followRedirects(url string) (string, error) {
 	return finalURL, nil
 }
 
-func Reddit(url string) (string, error) {
+func Reddit(ctx context.Context, url string) (string, error) {
 	// Handle v.redd.it URLs by following redirects to get actual Reddit post URL
 	if strings.Contains(url, "v.redd.it") {
-		finalURL, err := followRedirects(url)
+		finalURL, err := followRedirects(ctx, url)
 		if err != nil {
 			log.Warnf("Failed to follow redirects for v.redd.it URL %s: %v", url, err)
 			return "", fmt.Errorf("failed to follow v.redd.it redirects: %w", err)
@@ -253,14 +256,14 @@ func Reddit(url string) (string, error) {
 		url = fmt.Sprintf("%s/.json", url)
 	}
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		log.Fatal("Error reading request. ", err)
+		return "", errors.Wrap(err, "error creating request")
 	}
 
 	// Set headers
 	req.Header.Set("User-Agent", UserAgent)
-	req.Header.Set("Accept-Language", AcceptLanguage)
+	req.Header.Set("Accept-Language", common.AcceptLanguageFor(ctx))
 	req.Header.Set("Accept", Accept)
 
 	// Set client timeout
@@ -269,7 +272,7 @@ func Reddit(url string) (string, error) {
 	// Send request
 	res, err := client.Do(req)
 	if err != nil {
-		log.Fatal("Error reading response. ", err)
+		return "", errors.Wrap(err, "error querying Reddit API")
 	}
 	defer func() {
 		if cerr := res.Body.Close(); cerr != nil {
@@ -311,10 +314,11 @@ func Reddit(url string) (string, error) {
 	data := apiResponse[0].Data.Children[0].Data
 	over_18 := data.Over18
 	created := time.Unix(int64(data.CreatedUtc), 0)
-	age := humanize.RelTime(created, time.Now(), "ago", "")
+	loc := locale.From(ctx)
+	age := loc.RelTime(created, time.Now())
 	//author := data.Author
 
-	title := fmt.Sprintf("%s [%d pts, %d comments, %s]", data.Title, data.Score, data.NumComments, age)
+	title := fmt.Sprintf("%s [%s, %s, %s]", data.Title, loc.Count("pts", data.Score), loc.Count("comments", data.NumComments), age)
 	if over_18 {
 		title = fmt.Sprintf("%s (NSFW)", title)
 	}
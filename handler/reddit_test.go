@@ -3,6 +3,7 @@
 package handler
 
 import (
+	"context"
 	"regexp"
 	"strings"
 	"testing"
@@ -32,7 +33,7 @@ func TestReddit(t *testing.T) {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			got, err := Reddit(tt.args.url)
+			got, err := Reddit(context.Background(), tt.args.url)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Reddit() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -1,19 +1,21 @@
 package handler
 
 import (
+	"context"
 	"net/http"
 	"time"
 
+	"github.com/lepinkainen/titleparser/common"
 	"github.com/lepinkainen/titleparser/lambda"
 	log "github.com/sirupsen/logrus"
 )
 
 var TheRegisterMatch = `.*\.theregister\.com.*|^https?://theregister\.com.*`
 
-func TheRegister(url string) (string, error) {
+func TheRegister(ctx context.Context, url string) (string, error) {
 	log.Infof("Using The Register handler for %s", url)
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		log.Errorf("Error creating request for %s: %v", url, err)
 		return "", err
@@ -21,7 +23,7 @@ func TheRegister(url string) (string, error) {
 
 	// Set headers to avoid 403 Forbidden from The Register's bot detection
 	req.Header.Set("User-Agent", UserAgent)
-	req.Header.Set("Accept-Language", AcceptLanguage)
+	req.Header.Set("Accept-Language", common.AcceptLanguageFor(ctx))
 	req.Header.Set("Accept", Accept)
 
 	// Set client timeout (10 seconds, same as other handlers)
@@ -33,6 +35,7 @@ func TheRegister(url string) (string, error) {
 		log.Errorf("Error sending request to %s: %v", url, err)
 		return "", err
 	}
+	lambda.RecordResponse(ctx, res)
 	defer func() {
 		if err := res.Body.Close(); err != nil {
 			log.Warnf("Failed to close response body: %v", err)
@@ -48,5 +51,10 @@ func TheRegister(url string) (string, error) {
 }
 
 func init() {
-	lambda.RegisterHandler(TheRegisterMatch, TheRegister)
+	lambda.Register(lambda.Handler{
+		Description: "The Register articles, fetched with browser headers to get past the bot detection",
+		Patterns:    []string{TheRegisterMatch},
+		Examples:    []string{"https://www.theregister.com/2022/03/21/google_messages_gdpr/"},
+		Func:        TheRegister,
+	})
 }
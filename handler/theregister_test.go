@@ -3,6 +3,7 @@
 package handler
 
 import (
+	"context"
 	"regexp"
 	"strings"
 	"testing"
@@ -25,7 +26,7 @@ func TestTheRegister(t *testing.T) {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			got, err := TheRegister(tt.args.url)
+			got, err := TheRegister(context.Background(), tt.args.url)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("TheRegister() error = %v, wantErr %v", err, tt.wantErr)
 				return
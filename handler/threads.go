@@ -1,9 +1,11 @@
 package handler
 
 import (
+	"context"
 	"net/http"
 	"time"
 
+	"github.com/lepinkainen/titleparser/common"
 	"github.com/lepinkainen/titleparser/lambda"
 	log "github.com/sirupsen/logrus"
 )
@@ -20,10 +22,10 @@ const ThreadsUserAgent = "Mozilla/5.0 (compatible; Twitterbot/1.0)"
 
 // Threads extracts the title for a Threads URL by requesting the page as a
 // social crawler so that the server includes OpenGraph metadata.
-func Threads(url string) (string, error) {
+func Threads(ctx context.Context, url string) (string, error) {
 	log.Infof("Using Threads handler for %s", url)
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		log.Errorf("Error creating request for %s: %v", url, err)
 		return "", err
@@ -32,7 +34,7 @@ func Threads(url string) (string, error) {
 	// Crawler User-Agent is required, otherwise Threads returns the JS shell
 	// without OpenGraph tags.
 	req.Header.Set("User-Agent", ThreadsUserAgent)
-	req.Header.Set("Accept-Language", AcceptLanguage)
+	req.Header.Set("Accept-Language", common.AcceptLanguageFor(ctx))
 	req.Header.Set("Accept", Accept)
 
 	client := &http.Client{Timeout: time.Second * 10}
@@ -42,6 +44,7 @@ func Threads(url string) (string, error) {
 		log.Errorf("Error sending request to %s: %v", url, err)
 		return "", err
 	}
+	lambda.RecordResponse(ctx, res)
 	defer func() {
 		if err := res.Body.Close(); err != nil {
 			log.Warnf("Failed to close response body: %v", err)
@@ -55,5 +58,10 @@ func Threads(url string) (string, error) {
 }
 
 func init() {
-	lambda.RegisterHandler(ThreadsMatch, Threads)
+	lambda.Register(lambda.Handler{
+		Description: "Threads posts, fetched as a social crawler to get the OpenGraph tags",
+		Patterns:    []string{ThreadsMatch},
+		Examples:    []string{"https://www.threads.com/@grimmemento/post/Db2xD9kiAFI", "https://www.threads.com/share/_0ozSh-x9/"},
+		Func:        Threads,
+	})
 }
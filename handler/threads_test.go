@@ -3,6 +3,7 @@
 package handler
 
 import (
+	"context"
 	"regexp"
 	"strings"
 	"testing"
@@ -23,7 +24,7 @@ func TestThreads(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			got, err := Threads(tt.args.url)
+			got, err := Threads(context.Background(), tt.args.url)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Threads() error = %v, wantErr %v", err, tt.wantErr)
 				return
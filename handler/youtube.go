@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -12,8 +13,9 @@ import (
 	"strings"
 	"time"
 
-	"github.com/dustin/go-humanize"
+	"github.com/lepinkainen/titleparser/common"
 	"github.com/lepinkainen/titleparser/lambda"
+	"github.com/lepinkainen/titleparser/locale"
 	"github.com/pkg/errors"
 
 	log "github.com/sirupsen/logrus"
@@ -102,7 +104,9 @@ type YoutubeChannelReply struct {
 	} `json:"items"`
 }
 
-func Youtube(url string) (string, error) {
+// Youtube titles using the Data API, titles and channel names are localized
+// to the preferred language of the request if the uploader has translated them
+func Youtube(ctx context.Context, url string) (string, error) {
 	youtubeKey := os.Getenv("YOUTUBE_KEY")
 	if youtubeKey == "" {
 		return "", errors.New("No API key set for Youtube")
@@ -110,12 +114,12 @@ func Youtube(url string) (string, error) {
 
 	// Check if this is a video URL
 	if videoID := ExtractVideoID(url); videoID != "" {
-		return handleVideoURL(videoID, youtubeKey)
+		return handleVideoURL(ctx, videoID, youtubeKey)
 	}
 
 	// Check if this is a channel URL
 	if channelID, paramType := ExtractChannelInfo(url); channelID != "" {
-		return handleChannelURL(channelID, paramType, youtubeKey)
+		return handleChannelURL(ctx, channelID, paramType, youtubeKey)
 	}
 
 	return "", errors.New("Not a valid YouTube URL")
@@ -151,9 +155,9 @@ func ExtractChannelInfo(url string) (string, string) {
 	return "", ""
 }
 
-func handleVideoURL(videoID, apiKey string) (string, error) {
+func handleVideoURL(ctx context.Context, videoID, apiKey string) (string, error) {
 	client := &http.Client{}
-	req, err := http.NewRequest("GET", videoAPIURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", videoAPIURL, nil)
 	if err != nil {
 		return "", errors.Wrap(err, "Failed to create video API request")
 	}
@@ -163,12 +167,16 @@ func handleVideoURL(videoID, apiKey string) (string, error) {
 	q.Add("key", apiKey)
 	q.Add("part", "snippet,contentDetails,statistics")
 	q.Add("fields", "items(id,snippet,contentDetails,statistics)")
+	if hl := common.PrimaryLanguage(ctx); hl != "" {
+		q.Add("hl", hl)
+	}
 	req.URL.RawQuery = q.Encode()
 
 	res, err := client.Do(req)
 	if err != nil {
 		return "", errors.Wrap(err, "Error querying YouTube video API")
 	}
+	lambda.RecordResponse(ctx, res)
 	defer func() {
 		if cerr := res.Body.Close(); cerr != nil {
 			log.Warnf("Failed to close response body: %v", cerr)
@@ -197,38 +205,45 @@ func handleVideoURL(videoID, apiKey string) (string, error) {
 
 	video := reply.Items[0]
 
+	// localized has the translated title for hl, or the original if there's none
+	videoTitle := video.Snippet.Title
+	if video.Snippet.Localized.Title != "" {
+		videoTitle = video.Snippet.Localized.Title
+	}
+
 	duration := strings.ToLower(video.ContentDetails.Duration[2:])
 
+	loc := locale.From(ctx)
 	ageRestricted := ""
 	if video.ContentDetails.ContentRating.YtRating == "ytAgeRestricted" {
-		ageRestricted = " - age restricted"
+		ageRestricted = " - " + loc.Message("agerestricted")
 	}
 
 	publishedAt, err := time.Parse(time.RFC3339, video.Snippet.PublishedAt)
 	if err != nil {
 		log.Warnf("Failed to parse video publish date: %v", err)
-		return fmt.Sprintf("%s by %s", video.Snippet.Title, video.Snippet.ChannelTitle), nil
+		return loc.Message("by", videoTitle, video.Snippet.ChannelTitle), nil
 	}
-	agestr := humanize.RelTime(publishedAt, time.Now(), "ago", "from now")
+	agestr := loc.RelTime(publishedAt, time.Now())
 
 	viewCount, _ := strconv.ParseInt(video.Statistics.ViewCount, 10, 64)
 	var views string
 	if viewCount >= math.MinInt && viewCount <= math.MaxInt {
-		views = HumanizeNumber(int(viewCount))
+		views = loc.CompactCount("views", int(viewCount))
 	} else {
 		log.Warnf("View count exceeds int range: %d", viewCount)
-		views = HumanizeNumber(math.MaxInt)
+		views = loc.CompactCount("views", math.MaxInt)
 	}
 
-	title := fmt.Sprintf("%s by %s [%s - %s views - %s%s]",
-		video.Snippet.Title, video.Snippet.ChannelTitle, duration, views, agestr, ageRestricted)
+	title := fmt.Sprintf("%s [%s - %s - %s%s]",
+		loc.Message("by", videoTitle, video.Snippet.ChannelTitle), duration, views, agestr, ageRestricted)
 
 	return title, nil
 }
 
-func handleChannelURL(channelID, paramType, apiKey string) (string, error) {
+func handleChannelURL(ctx context.Context, channelID, paramType, apiKey string) (string, error) {
 	client := &http.Client{}
-	req, err := http.NewRequest("GET", channelAPIURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", channelAPIURL, nil)
 	if err != nil {
 		return "", errors.Wrap(err, "Failed to create channel API request")
 	}
@@ -238,12 +253,16 @@ func handleChannelURL(channelID, paramType, apiKey string) (string, error) {
 	q.Add("key", apiKey)
 	q.Add("part", "snippet,statistics")
 	q.Add("fields", "items(id,snippet,statistics)")
+	if hl := common.PrimaryLanguage(ctx); hl != "" {
+		q.Add("hl", hl)
+	}
 	req.URL.RawQuery = q.Encode()
 
 	res, err := client.Do(req)
 	if err != nil {
 		return "", errors.Wrap(err, "Error querying YouTube channel API")
 	}
+	lambda.RecordResponse(ctx, res)
 	defer func() {
 		if cerr := res.Body.Close(); cerr != nil {
 			log.Warnf("Failed to close response body: %v", cerr)
@@ -272,38 +291,50 @@ func handleChannelURL(channelID, paramType, apiKey string) (string, error) {
 
 	channel := reply.Items[0]
 
+	channelTitle := channel.Snippet.Title
+	if channel.Snippet.Localized.Title != "" {
+		channelTitle = channel.Snippet.Localized.Title
+	}
+
+	loc := locale.From(ctx)
+
 	subscriberCount, _ := strconv.ParseInt(channel.Statistics.SubscriberCount, 10, 64)
 	var subscribers string
 	if subscriberCount >= math.MinInt && subscriberCount <= math.MaxInt {
-		subscribers = HumanizeNumber(int(subscriberCount))
+		subscribers = loc.CompactCount("subscribers", int(subscriberCount))
 	} else {
 		log.Warnf("Subscriber count exceeds int range: %d", subscriberCount)
-		subscribers = HumanizeNumber(math.MaxInt)
+		subscribers = loc.CompactCount("subscribers", math.MaxInt)
 	}
 
 	videoCount, _ := strconv.ParseInt(channel.Statistics.VideoCount, 10, 64)
 	var videos string
 	if videoCount >= math.MinInt && videoCount <= math.MaxInt {
-		videos = HumanizeNumber(int(videoCount))
+		videos = loc.CompactCount("videos", int(videoCount))
 	} else {
 		log.Warnf("Video count exceeds int range: %d", videoCount)
-		videos = HumanizeNumber(math.MaxInt)
+		videos = loc.CompactCount("videos", math.MaxInt)
 	}
 
 	publishedAt, err := time.Parse(time.RFC3339, channel.Snippet.PublishedAt)
 	if err != nil {
 		log.Warnf("Failed to parse channel publish date: %v", err)
-		return fmt.Sprintf("%s [Channel - %s subscribers]", channel.Snippet.Title, subscribers), nil
+		return fmt.Sprintf("%s [%s - %s]", channelTitle, loc.Message("channel"), subscribers), nil
 	}
-	agestr := humanize.RelTime(publishedAt, time.Now(), "ago", "from now")
+	agestr := loc.RelTime(publishedAt, time.Now())
 
-	title := fmt.Sprintf("%s [Channel - %s subscribers - %s videos - created %s]",
-		channel.Snippet.Title, subscribers, videos, agestr)
+	title := fmt.Sprintf("%s [%s - %s - %s - %s]",
+		channelTitle, loc.Message("channel"), subscribers, videos, loc.Message("created", agestr))
 
 	return title, nil
 }
 
 func init() {
-	lambda.RegisterHandler(".*youtu.be.*", Youtube)
-	lambda.RegisterHandler(".*youtube\\.com.*", Youtube)
+	lambda.Register(lambda.Handler{
+		Description: "YouTube videos with duration, views and age and channels with subscribers from the Data API, localized when translated",
+		Patterns:    []string{".*youtu.be.*", ".*youtube\\.com.*"},
+		Examples:    []string{"https://www.youtube.com/watch?v=QdpxoFcdORI", "https://youtu.be/QdpxoFcdORI"},
+		Env:         []string{"YOUTUBE_KEY"},
+		Func:        Youtube,
+	})
 }
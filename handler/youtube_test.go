@@ -3,6 +3,7 @@
 package handler
 
 import (
+	"context"
 	"regexp"
 	"testing"
 )
@@ -22,7 +23,7 @@ func TestYoutube(t *testing.T) {
 		{"Marvel 1 - short", args{url: "https://youtu.be/QdpxoFcdORI"}, `Marvel Studios Celebrates The Movies by Marvel Entertainment \[3m11s - \d+M views - \d+ (hours|days?|weeks?|months?|years?) ago\]`, false},
 		{"Age restricted", args{url: "https://www.youtube.com/watch?v=EX_8ZjT2sO4"}, `Grenouer - Alone in the Dark - \[UNCENSORED - AGE RESTRICTED\] by GrenouerVEVO \[3m59s - \d+M views - \d years ago - age restricted\]`, false},
 		{"At timestamp", args{url: "https://youtu.be/EX_8ZjT2sO4?t=98"}, `Grenouer - Alone in the Dark - \[UNCENSORED - AGE RESTRICTED\] by GrenouerVEVO \[3m59s - \d+M views - \d years ago - age restricted\]`, false},
-		{"Gangnam style", args{url: "https://www.youtube.com/watch?v=9bZkp7q19f0"}, `PSY - GANGNAM STYLE\(강남스타일\) M/V by officialpsy \[4m13s - \d+Billion views - \d+ years ago\]`, false},
+		{"Gangnam style", args{url: "https://www.youtube.com/watch?v=9bZkp7q19f0"}, `PSY - GANGNAM STYLE\(강남스타일\) M/V by officialpsy \[4m13s - \d+B views - \d+ years ago\]`, false},
 
 		// Channel tests
 		{"Channel handle", args{url: "https://www.youtube.com/@GoogleDevelopers"}, `Google for Developers \[Channel - \d+[\w.]+ subscribers - \d+[\w.]+ videos - created \d+ years ago\]`, false},
@@ -34,7 +35,7 @@ func TestYoutube(t *testing.T) {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			got, err := Youtube(tt.args.url)
+			got, err := Youtube(context.Background(), tt.args.url)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Youtube() error = %v, wantErr %v", err, tt.wantErr)
 				return
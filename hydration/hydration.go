@@ -0,0 +1,229 @@
+// Package hydration finds the JSON state single page apps embed in their HTML
+// for client side hydration, so their content can be read without running
+// any JavaScript.
+//
+// Supported sources, by the name used to refer to them:
+//
+//	__NEXT_DATA__     <script id="__NEXT_DATA__" type="application/json"> (Next.js)
+//	__NUXT_DATA__     <script id="__NUXT_DATA__" type="application/json"> (Nuxt 3)
+//	__NUXT__          window.__NUXT__ = {...} (Nuxt 2, only when it's plain JSON)
+//	__INITIAL_STATE__ window.__INITIAL_STATE__ = {...} (Redux, Vuex)
+//	__APOLLO_STATE__  window.__APOLLO_STATE__ = {...} (Apollo cache)
+//	jsonld            all <script type="application/ld+json"> blocks as an array
+//
+// Any other window.__NAME__ = {...} assignment is found by its name too.
+package hydration
+
+import (
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	log "github.com/sirupsen/logrus"
+)
+
+// State is the decoded JSON of each source found in a page
+type State map[string]any
+
+var (
+	// scriptIDs are the sources embedded as JSON script elements
+	scriptIDs = []string{"__NEXT_DATA__", "__NUXT_DATA__"}
+
+	// assignmentRegex matches the start of a window.__NAME__ = ... assignment
+	assignmentRegex = regexp.MustCompile(`(?:window|self|globalThis)\.(__[A-Z][A-Z0-9_]*__)\s*=\s*`)
+)
+
+// Extract finds and decodes the named state blobs in the page, all of them
+// if no sources are given
+func Extract(doc *goquery.Document, sources ...string) State {
+	state := State{}
+	wanted := func(name string) bool {
+		return len(sources) == 0 || slices.Contains(sources, name)
+	}
+
+	for _, id := range scriptIDs {
+		if !wanted(id) {
+			continue
+		}
+		s := doc.Find("script#" + id).First()
+		if s.Size() == 0 {
+			continue
+		}
+		var data any
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			log.Debugf("Could not decode %s: %v", id, err)
+			continue
+		}
+		if id == "__NUXT_DATA__" {
+			var ok bool
+			if data, ok = unflatten(data); !ok {
+				log.Debugf("Could not decode %s: more than %d values", id, maxNodes)
+				continue
+			}
+		}
+		state[id] = data
+	}
+
+	if wanted("jsonld") {
+		var jsonld []any
+		doc.Find(`script[type="application/ld+json"]`).Each(func(_ int, s *goquery.Selection) {
+			var data any
+			if err := json.Unmarshal([]byte(s.Text()), &data); err == nil {
+				jsonld = append(jsonld, data)
+			}
+		})
+		if len(jsonld) > 0 {
+			state["jsonld"] = jsonld
+		}
+	}
+
+	doc.Find("script:not([src])").Each(func(_ int, s *goquery.Selection) {
+		text := s.Text()
+		for _, match := range assignmentRegex.FindAllStringSubmatchIndex(text, -1) {
+			name := text[match[2]:match[3]]
+			if _, ok := state[name]; ok || !wanted(name) {
+				continue
+			}
+			if data, ok := decodeValue(text[match[1]:]); ok {
+				state[name] = data
+			}
+		}
+	})
+
+	return state
+}
+
+// decodeValue decodes the JSON value at the start of the script text,
+// ignoring whatever comes after it. JSON.parse("...") wrappers are unwrapped.
+func decodeValue(text string) (any, bool) {
+	text = strings.TrimSpace(text)
+
+	if rest, ok := strings.CutPrefix(text, "JSON.parse("); ok {
+		var encoded string
+		if err := json.NewDecoder(strings.NewReader(rest)).Decode(&encoded); err != nil {
+			return nil, false
+		}
+		text = encoded
+	}
+
+	var data any
+	if err := json.NewDecoder(strings.NewReader(text)).Decode(&data); err != nil {
+		// Most likely a JavaScript expression, not JSON
+		return nil, false
+	}
+
+	return data, true
+}
+
+// devalue markers for values JSON can't represent
+const (
+	devalueUndefined = -1
+	devalueHole      = -2
+	maxDepth         = 64
+	// maxNodes caps the size of the rebuilt object. Values referenced from
+	// several places are copied to each, so a small payload could otherwise
+	// grow exponentially.
+	maxNodes = 100000
+)
+
+// unflatten rebuilds the object from Nuxt 3 devalue format, where the payload
+// is an array and every object and array value is an index into it. Returns
+// false if the object would have more than maxNodes values.
+func unflatten(data any) (any, bool) {
+	values, ok := data.([]any)
+	if !ok || len(values) == 0 {
+		return data, true
+	}
+
+	nodes := 0
+	var hydrate func(index any, depth int) any
+	hydrate = func(index any, depth int) any {
+		i, ok := index.(float64)
+		if !ok || depth > maxDepth || nodes > maxNodes {
+			return nil
+		}
+		nodes++
+		if i == devalueUndefined || i == devalueHole || i < 0 || int(i) >= len(values) {
+			return nil
+		}
+
+		switch v := values[int(i)].(type) {
+		case map[string]any:
+			object := make(map[string]any, len(v))
+			for key, value := range v {
+				object[key] = hydrate(value, depth+1)
+			}
+			return object
+		case []any:
+			if len(v) > 0 {
+				if tag, ok := v[0].(string); ok {
+					switch tag {
+					case "Date":
+						if len(v) > 1 {
+							return v[1]
+						}
+						return nil
+					case "Reactive", "ShallowReactive", "Ref", "ShallowRef":
+						if len(v) > 1 {
+							return hydrate(v[1], depth+1)
+						}
+						return nil
+					case "Set":
+						list := make([]any, 0, len(v)-1)
+						for _, item := range v[1:] {
+							list = append(list, hydrate(item, depth+1))
+						}
+						return list
+					case "EmptyRef", "EmptyShallowRef", "null":
+						return nil
+					}
+				}
+			}
+			list := make([]any, 0, len(v))
+			for _, item := range v {
+				list = append(list, hydrate(item, depth+1))
+			}
+			return list
+		default:
+			return v
+		}
+	}
+
+	result := hydrate(float64(0), 0)
+	if nodes > maxNodes {
+		return nil, false
+	}
+	return result, true
+}
+
+// Lookup returns the first value matching the path in the named source
+func (s State) Lookup(source string, path *Path) (any, bool) {
+	data, ok := s[source]
+	if !ok {
+		return nil, false
+	}
+	return path.First(data)
+}
+
+// Text formats a JSON value for use in a title
+func Text(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+}
@@ -0,0 +1,149 @@
+package hydration
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func loadFixture(t *testing.T, name string) *goquery.Document {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestExtract(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		fixture string
+		source  string
+		path    string
+		want    string
+		wantOK  bool
+	}{
+		{"next.html", "__NEXT_DATA__", "$.props.pageProps.post.caption", "Sunset over the archipelago", true},
+		{"next.html", "__NEXT_DATA__", "props.pageProps.post.user.username", "saaristolainen", true},
+		{"next.html", "__NEXT_DATA__", "$..like_count", "1284", true},
+		{"nuxt.html", "__NUXT_DATA__", "$.data['event-42'].name", "Flow Festival 2024", true},
+		{"nuxt.html", "__NUXT_DATA__", "$.data.*.starts", "2024-08-09T14:00:00.000Z", true},
+		{"nuxt.html", "__NUXT_DATA__", "$.data.*.soldOut", "false", true},
+		{"apollo.html", "__APOLLO_STATE__", "$['Recipe:981'].title", "Karjalanpiirakat", true},
+		{"apollo.html", "__APOLLO_STATE__", "$.*.cookingTime", "90", true},
+		{"apollo.html", "__INITIAL_STATE__", "$.article.headline", "Parsed from a string", true},
+		{"apollo.html", "__NUXT__", "$.title", "", false},
+		{"jsonld.html", "jsonld", "$[-1].name", "Concert in the park", true},
+		{"jsonld.html", "jsonld", "$[*].startDate", "2024-07-01T18:00", true},
+		{"jsonld.html", "__PRELOADED_STATE__", "$.cart.items", "3", true},
+		{"jsonld.html", "__NEXT_DATA__", "$.props", "", false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.fixture+" "+tt.source+" "+tt.path, func(t *testing.T) {
+			t.Parallel()
+			path, err := Compile(tt.path)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			got, ok := Extract(loadFixture(t, tt.fixture)).Lookup(tt.source, path)
+			if ok != tt.wantOK {
+				t.Fatalf("Lookup() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if Text(got) != tt.want {
+				t.Errorf("Lookup() = '%v', want '%v'", Text(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	t.Parallel()
+
+	data := map[string]any{
+		"a": map[string]any{"b.c": "quoted", "list": []any{"first", "second", map[string]any{"x": "deep"}}},
+	}
+
+	tests := []struct {
+		path    string
+		want    []any
+		wantErr bool
+	}{
+		{"$.a['b.c']", []any{"quoted"}, false},
+		{`$.a["b.c"]`, []any{"quoted"}, false},
+		{"$.a.list[0]", []any{"first"}, false},
+		{"$.a.list[-2]", []any{"second"}, false},
+		{"$.a.list[5]", nil, false},
+		{"$..x", []any{"deep"}, false},
+		{"$..list[1]", []any{"second"}, false},
+		{"$", []any{data}, false},
+		{"$.a.", nil, true},
+		{"$.a[", nil, true},
+		{"$.a[x]", nil, true},
+		{"$a", nil, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.path, func(t *testing.T) {
+			t.Parallel()
+			path, err := Compile(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Compile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got := path.Find(data)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Find() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if Text(got[i]) != Text(tt.want[i]) {
+					t.Errorf("Find()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestUnflattenSharedReferences(t *testing.T) {
+	t.Parallel()
+
+	// Every array refers to the next one twice, the rebuilt object doubles in size for each level
+	payload := func(levels int) []any {
+		values := make([]any, 0, levels+1)
+		for i := 1; i <= levels; i++ {
+			values = append(values, []any{float64(i), float64(i)})
+		}
+		return append(values, "x")
+	}
+
+	got, ok := unflatten(payload(3))
+	if !ok || Text(got) != `[[["x","x"],["x","x"]],[["x","x"],["x","x"]]]` {
+		t.Errorf("unflatten() = %v, %v", Text(got), ok)
+	}
+
+	if got, ok := unflatten(payload(60)); ok || got != nil {
+		t.Errorf("unflatten() = %v, %v, want it to give up", got, ok)
+	}
+}
+
+func TestExtractSources(t *testing.T) {
+	t.Parallel()
+
+	state := Extract(loadFixture(t, "apollo.html"), "__APOLLO_STATE__")
+	if _, ok := state["__APOLLO_STATE__"]; !ok || len(state) != 1 {
+		t.Errorf("Extract() = %v, want only __APOLLO_STATE__", state)
+	}
+}
@@ -0,0 +1,190 @@
+package hydration
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Path is a compiled JSONPath-style expression. Supported syntax:
+//
+//	$.props.pageProps.title   child keys, the leading $ is optional
+//	$['ROOT_QUERY']           quoted keys for names with dots or spaces
+//	$.items[0], $.items[-1]   array indexes, negative counts from the end
+//	$.items[*].name, $.*      wildcards
+//	$..headline               recursive descent, finds the key at any depth
+type Path struct {
+	expr     string
+	segments []segment
+}
+
+type segment struct {
+	key       string
+	index     int
+	isIndex   bool
+	wildcard  bool
+	recursive bool
+}
+
+// Compile parses a path expression
+func Compile(expr string) (*Path, error) {
+	p := &Path{expr: expr}
+	rest, rooted := strings.CutPrefix(strings.TrimSpace(expr), "$")
+
+	// a path without $ can start with a bare key
+	if !rooted && rest != "" && rest[0] != '.' && rest[0] != '[' {
+		rest = "." + rest
+	}
+
+	for rest != "" {
+		var seg segment
+
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			seg.recursive = true
+			rest = rest[2:]
+			if strings.HasPrefix(rest, "[") {
+				break
+			}
+			fallthrough
+		case strings.HasPrefix(rest, "."):
+			rest = strings.TrimPrefix(rest, ".")
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			key := rest[:end]
+			rest = rest[end:]
+			if key == "" {
+				return nil, fmt.Errorf("path %q: empty key", expr)
+			}
+			if key == "*" {
+				seg.wildcard = true
+			} else {
+				seg.key = key
+			}
+			p.segments = append(p.segments, seg)
+			continue
+		case !strings.HasPrefix(rest, "["):
+			return nil, fmt.Errorf("path %q: unexpected %q", expr, rest)
+		}
+
+		end := strings.Index(rest, "]")
+		if end == -1 {
+			return nil, fmt.Errorf("path %q: missing ]", expr)
+		}
+		inner := strings.TrimSpace(rest[1:end])
+		rest = rest[end+1:]
+
+		switch {
+		case inner == "*":
+			seg.wildcard = true
+		case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+			seg.key = inner[1 : len(inner)-1]
+		default:
+			index, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("path %q: invalid index %q", expr, inner)
+			}
+			seg.index = index
+			seg.isIndex = true
+		}
+		p.segments = append(p.segments, seg)
+	}
+
+	return p, nil
+}
+
+// String returns the original expression
+func (p *Path) String() string {
+	return p.expr
+}
+
+// Find returns all values matching the path in document order,
+// object keys are visited in sorted order so results are stable
+func (p *Path) Find(data any) []any {
+	current := []any{data}
+	for _, seg := range p.segments {
+		var next []any
+		for _, value := range current {
+			if seg.recursive {
+				walk(value, 0, func(v any) {
+					next = append(next, seg.match(v)...)
+				})
+			} else {
+				next = append(next, seg.match(value)...)
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+// First returns the first non-null value matching the path
+func (p *Path) First(data any) (any, bool) {
+	for _, value := range p.Find(data) {
+		if value != nil {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// match applies a single segment to a value
+func (s segment) match(value any) []any {
+	switch v := value.(type) {
+	case map[string]any:
+		if s.wildcard {
+			var values []any
+			for _, key := range sortedKeys(v) {
+				values = append(values, v[key])
+			}
+			return values
+		}
+		if child, ok := v[s.key]; ok && !s.isIndex {
+			return []any{child}
+		}
+	case []any:
+		if s.wildcard {
+			return v
+		}
+		if s.isIndex {
+			index := s.index
+			if index < 0 {
+				index += len(v)
+			}
+			if index >= 0 && index < len(v) {
+				return []any{v[index]}
+			}
+		}
+	}
+	return nil
+}
+
+// walk calls fn for the value and everything inside it, depth first
+func walk(value any, depth int, fn func(any)) {
+	if depth > maxDepth {
+		return
+	}
+	fn(value)
+	switch v := value.(type) {
+	case map[string]any:
+		for _, key := range sortedKeys(v) {
+			walk(v[key], depth+1, fn)
+		}
+	case []any:
+		for _, item := range v {
+			walk(item, depth+1, fn)
+		}
+	}
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
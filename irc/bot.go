@@ -0,0 +1,449 @@
+// Package irc is a built-in IRC bot: it watches channels for URLs and
+// replies with their titles
+package irc
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lepinkainen/titleparser/common"
+	"github.com/lepinkainen/titleparser/lambda"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	dialTimeout  = 30 * time.Second
+	writeTimeout = 30 * time.Second
+	// readTimeout is longer than any server waits between PINGs
+	readTimeout = 5 * time.Minute
+
+	// Reconnect delays, doubled after every failed attempt
+	minBackoff = 5 * time.Second
+	maxBackoff = 5 * time.Minute
+
+	// maxLineBytes is the IRC line limit without the CRLF
+	maxLineBytes = 510
+	// relayPrefixBytes is room for the ":nick!user@host " the server adds when relaying our messages
+	relayPrefixBytes = 100
+	// saslChunk is the longest AUTHENTICATE payload
+	saslChunk = 400
+	// queueSize is how many messages can wait for the flood limit, the rest are dropped
+	queueSize = 64
+)
+
+// LookupFunc resolves the title for a URL seen in a channel
+type LookupFunc func(context.Context, lambda.TitleQuery) (lambda.TitleQuery, error)
+
+// Bot watches channels for URLs and replies with their titles
+type Bot struct {
+	config Config
+	lookup LookupFunc
+}
+
+// New returns a bot resolving titles with lookup
+func New(config Config, lookup LookupFunc) *Bot {
+	return &Bot{config: config, lookup: lookup}
+}
+
+// Run connects to every configured server and keeps reconnecting until the
+// context is cancelled
+func (b *Bot) Run(ctx context.Context) error {
+	if err := b.config.Validate(); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for _, server := range b.config.Servers {
+		wg.Add(1)
+		go func(server Server) {
+			defer wg.Done()
+			b.runServer(ctx, server)
+		}(server)
+	}
+	wg.Wait()
+	return nil
+}
+
+// runServer reconnects to the server with an increasing delay
+func (b *Bot) runServer(ctx context.Context, server Server) {
+	backoff := minBackoff
+	for {
+		start := time.Now()
+		err := b.connect(ctx, server)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// A connection that worked for a while starts over with a short delay
+		if time.Since(start) > maxBackoff {
+			backoff = minBackoff
+		}
+		log.Warnf("IRC %s: %v, reconnecting in %s", serverName(server), err, backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// session is a single connection to a server
+type session struct {
+	bot      *Bot
+	server   Server
+	conn     net.Conn
+	nick     string
+	ignore   []mask
+	throttle *throttle
+	flood    *bucket
+	out      chan string
+
+	writeMu    sync.Mutex
+	registered bool
+}
+
+// connect runs one connection until it fails or the context is cancelled
+func (b *Bot) connect(ctx context.Context, server Server) error {
+	conn, err := dial(ctx, server)
+	if err != nil {
+		return errors.Wrap(err, "connecting")
+	}
+	log.Infof("IRC %s: connected", serverName(server))
+
+	nick := server.Nick
+	if nick == "" {
+		nick = b.config.Nick
+	}
+	s := &session{
+		bot:      b,
+		server:   server,
+		conn:     conn,
+		nick:     nick,
+		ignore:   compileMasks(append(append([]string{}, b.config.Ignore...), server.Ignore...)),
+		throttle: newThrottle(b.config.Throttle),
+		flood:    newBucket(b.config.Flood.Burst, time.Duration(b.config.Flood.IntervalMillis)*time.Millisecond, time.Now()),
+		out:      make(chan string, queueSize),
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		_ = s.send(line("QUIT", "Shutting down"))
+		conn.Close()
+	}()
+	go s.writer(ctx)
+
+	if err := s.register(); err != nil {
+		return err
+	}
+	return s.read(ctx)
+}
+
+// dial opens a plain or TLS connection
+func dial(ctx context.Context, server Server) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	if !server.TLS {
+		return dialer.DialContext(ctx, "tcp", server.Address)
+	}
+
+	host, _, err := net.SplitHostPort(server.Address)
+	if err != nil {
+		return nil, err
+	}
+	tlsDialer := &tls.Dialer{
+		NetDialer: dialer,
+		Config: &tls.Config{
+			ServerName:         host,
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: server.TLSSkipVerify, // #nosec G402 -- opt-in for self-signed servers
+		},
+	}
+	return tlsDialer.DialContext(ctx, "tcp", server.Address)
+}
+
+// register starts the SASL negotiation if needed and sends the nick and user
+func (s *session) register() error {
+	var lines []string
+	if s.server.SASL.User != "" {
+		lines = append(lines, line("CAP", "REQ", "sasl"))
+	}
+	if s.server.Password != "" {
+		lines = append(lines, line("PASS", s.server.Password))
+	}
+	lines = append(lines,
+		line("NICK", s.nick),
+		line("USER", s.bot.config.User, "0", "*", s.bot.config.RealName),
+	)
+
+	for _, l := range lines {
+		if err := s.send(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read handles incoming lines until the connection fails
+func (s *session) read(ctx context.Context) error {
+	scanner := bufio.NewScanner(s.conn)
+	scanner.Buffer(make([]byte, 4096), 16<<10)
+
+	for {
+		if err := s.conn.SetReadDeadline(time.Now().Add(readTimeout)); err != nil {
+			return err
+		}
+		if !scanner.Scan() {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err := scanner.Err(); err != nil {
+				return errors.Wrap(err, "reading")
+			}
+			return io.EOF
+		}
+
+		msg, err := ParseMessage(scanner.Text())
+		if err != nil {
+			log.Debugf("IRC %s: %v", serverName(s.server), err)
+			continue
+		}
+		if err := s.handle(ctx, msg); err != nil {
+			return err
+		}
+	}
+}
+
+// handle reacts to a single message, an error closes the connection
+func (s *session) handle(ctx context.Context, msg Message) error {
+	switch msg.Command {
+	case "PING":
+		return s.send(line("PONG", msg.Param(0)))
+
+	case "CAP":
+		switch strings.ToUpper(msg.Param(1)) {
+		case "ACK":
+			if hasCap(msg.Param(2), "sasl") {
+				return s.send(line("AUTHENTICATE", "PLAIN"))
+			}
+		case "NAK":
+			// Connecting without the account could get the bot banned or impersonated
+			return errors.New("server doesn't support SASL")
+		}
+
+	case "AUTHENTICATE":
+		if msg.Param(0) == "+" {
+			return s.authenticate()
+		}
+
+	case "903":
+		return s.send(line("CAP", "END"))
+
+	case "902", "904", "905", "906", "908":
+		return errors.Errorf("SASL authentication failed: %s", msg.Param(len(msg.Params)-1))
+
+	case "001":
+		s.registered = true
+		s.nick = msg.Param(0)
+		log.Infof("IRC %s: registered as %s", serverName(s.server), s.nick)
+		for _, channel := range s.server.Channels {
+			s.queue(line("JOIN", strings.Fields(channel)...))
+		}
+
+	case "433":
+		// Nick in use, taken nicks after registration are just not changed
+		if !s.registered {
+			s.nick += "_"
+			return s.send(line("NICK", s.nick))
+		}
+
+	case "NICK":
+		if strings.EqualFold(msg.Nick(), s.nick) {
+			s.nick = msg.Param(0)
+		}
+
+	case "KICK":
+		if strings.EqualFold(msg.Param(1), s.nick) {
+			log.Warnf("IRC %s: kicked from %s by %s: %s", serverName(s.server), msg.Param(0), msg.Nick(), msg.Param(2))
+		}
+
+	case "PRIVMSG":
+		s.privmsg(ctx, msg)
+
+	case "ERROR":
+		return errors.Errorf("server closed the connection: %s", msg.Param(0))
+	}
+
+	return nil
+}
+
+// authenticate sends the SASL PLAIN credentials in chunks
+func (s *session) authenticate() error {
+	payload := base64.StdEncoding.EncodeToString([]byte("\x00" + s.server.SASL.User + "\x00" + s.server.SASL.Password))
+	for len(payload) >= saslChunk {
+		if err := s.send(line("AUTHENTICATE", payload[:saslChunk])); err != nil {
+			return err
+		}
+		payload = payload[saslChunk:]
+	}
+	// An empty last chunk is sent as "+"
+	if payload == "" {
+		payload = "+"
+	}
+	return s.send(line("AUTHENTICATE", payload))
+}
+
+// privmsg looks up the URLs in a channel message
+func (s *session) privmsg(ctx context.Context, msg Message) {
+	target, text := msg.Param(0), msg.Param(1)
+	if !isChannel(target) || strings.EqualFold(msg.Nick(), s.nick) || ignored(s.ignore, msg.Prefix) {
+		return
+	}
+
+	// CTCP, only /me actions can have links
+	if strings.HasPrefix(text, "\x01") {
+		if !strings.HasPrefix(text, "\x01ACTION ") {
+			return
+		}
+		text = strings.TrimSuffix(strings.TrimPrefix(text, "\x01ACTION "), "\x01")
+	}
+
+	urls := common.ExtractURLs(text)
+	if len(urls) > s.bot.config.MaxURLs {
+		urls = urls[:s.bot.config.MaxURLs]
+	}
+
+	var allowed []string
+	now := time.Now()
+	for _, url := range urls {
+		if !s.throttle.allow(target, now) {
+			log.Infof("IRC %s: throttled %s in %s", serverName(s.server), url, target)
+			break
+		}
+		allowed = append(allowed, url)
+	}
+	if len(allowed) == 0 {
+		return
+	}
+
+	// Slow lookups don't hold up reading, replies are in the order of the URLs
+	go s.reply(ctx, target, msg.Nick(), text, allowed)
+}
+
+// reply resolves the URLs and sends the titles to the channel
+func (s *session) reply(ctx context.Context, channel, nick, text string, urls []string) {
+	res := lambda.BatchResult{Results: make([]lambda.BatchItem, len(urls))}
+
+	var wg sync.WaitGroup
+	for i, url := range urls {
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			query, err := s.bot.lookup(ctx, lambda.TitleQuery{User: nick, Channel: channel, URL: url})
+			res.Results[i] = lambda.BatchItem{TitleQuery: query}
+			if err != nil {
+				res.Results[i].Error = err.Error()
+			}
+		}(i, url)
+	}
+	wg.Wait()
+
+	// Titles someone just pasted with the link are left out
+	res = lambda.MarkRedundant(text, res)
+
+	limit := maxLineBytes - relayPrefixBytes - len("PRIVMSG  :") - len(channel)
+	for _, item := range res.Results {
+		if item.Error != "" {
+			log.Infof("IRC %s: no title for %s: %s", serverName(s.server), item.URL, item.Error)
+			continue
+		}
+		if item.Title == "" {
+			continue
+		}
+		s.queue(line("PRIVMSG", channel, fit(s.bot.config.Prefix+item.Title, limit)))
+	}
+}
+
+// queue sends the line when the flood limit allows, dropping it if too many are waiting
+func (s *session) queue(l string) {
+	select {
+	case s.out <- l:
+	default:
+		log.Warnf("IRC %s: send queue full, dropping %q", serverName(s.server), l)
+	}
+}
+
+// writer sends the queued lines at the rate the flood limit allows
+func (s *session) writer(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case l := <-s.out:
+			if wait := s.flood.wait(time.Now()); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return
+				}
+			}
+			s.flood.take(time.Now())
+			if err := s.send(l); err != nil {
+				log.Warnf("IRC %s: %v", serverName(s.server), err)
+				return
+			}
+		}
+	}
+}
+
+// send writes a line right away, for registration and PONGs that can't wait
+func (s *session) send(l string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if err := s.conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return err
+	}
+	_, err := io.WriteString(s.conn, l+"\r\n")
+	return errors.Wrap(err, "writing")
+}
+
+// fit truncates the text to maxBytes without splitting characters
+func fit(text string, maxBytes int) string {
+	if len(text) <= maxBytes {
+		return text
+	}
+	for n := maxBytes; n > 0; n-- {
+		if short := common.Truncate(text, n); len(short) <= maxBytes {
+			return short
+		}
+	}
+	return ""
+}
+
+// hasCap checks a space separated capability list
+func hasCap(caps, name string) bool {
+	for _, c := range strings.Fields(caps) {
+		if strings.EqualFold(c, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func serverName(server Server) string {
+	if server.Name != "" {
+		return server.Name
+	}
+	return server.Address
+}
@@ -0,0 +1,345 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lepinkainen/titleparser/lambda"
+)
+
+// fakeServer is an in-process IRC server for a single client, the test
+// script checks every line the client sends
+type fakeServer struct {
+	t     *testing.T
+	ln    net.Listener
+	conn  chan net.Conn
+	lines chan string
+}
+
+func newFakeServer(t *testing.T, useTLS bool) *fakeServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if useTLS {
+		ln = tls.NewListener(ln, &tls.Config{Certificates: []tls.Certificate{testCertificate(t)}, MinVersion: tls.VersionTLS12})
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	f := &fakeServer{t: t, ln: ln, conn: make(chan net.Conn, 1), lines: make(chan string, 100)}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		t.Cleanup(func() { conn.Close() })
+		f.conn <- conn
+
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			f.lines <- scanner.Text()
+		}
+		close(f.lines)
+	}()
+	return f
+}
+
+func (f *fakeServer) addr() string {
+	return f.ln.Addr().String()
+}
+
+// expect fails the test if the next line from the client isn't want
+func (f *fakeServer) expect(want string) {
+	f.t.Helper()
+	select {
+	case got, ok := <-f.lines:
+		if !ok {
+			f.t.Fatalf("connection closed, want %q", want)
+		}
+		if got != want {
+			f.t.Fatalf("client sent %q, want %q", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		f.t.Fatalf("timed out waiting for %q", want)
+	}
+}
+
+// send writes a line to the client
+func (f *fakeServer) send(format string, args ...interface{}) {
+	f.t.Helper()
+	select {
+	case conn := <-f.conn:
+		f.conn <- conn
+		if _, err := fmt.Fprintf(conn, format+"\r\n", args...); err != nil {
+			f.t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		f.t.Fatal("client didn't connect")
+	}
+}
+
+// welcome completes registration without SASL
+func (f *fakeServer) welcome(nick string) {
+	f.t.Helper()
+	f.expect("NICK " + nick)
+	f.expect("USER titleparser 0 * titleparser")
+	f.send(":irc.test 001 %s :Welcome", nick)
+}
+
+// testCertificate is a self-signed certificate for 127.0.0.1
+func testCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func fakeLookup(ctx context.Context, query lambda.TitleQuery) (lambda.TitleQuery, error) {
+	switch {
+	case strings.Contains(query.URL, "broken"):
+		return query, fmt.Errorf("404 Not Found")
+	case strings.Contains(query.URL, "headline"):
+		query.Title = "Big news today"
+	default:
+		query.Title = "Title of " + query.URL
+	}
+	return query, nil
+}
+
+func testConfig(addr string) Config {
+	c := DefaultConfig()
+	c.Nick = "bot"
+	c.Flood = Flood{Burst: 10, IntervalMillis: 10}
+	c.Servers = []Server{{Name: "test", Address: addr, Channels: []string{"#test"}}}
+	return c
+}
+
+// connect runs a single connection in the background, the returned
+// function stops it and returns its error
+func connect(t *testing.T, config Config) func() error {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- New(config, fakeLookup).connect(ctx, config.Servers[0])
+	}()
+	return func() error {
+		cancel()
+		select {
+		case err := <-errs:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("connection didn't stop")
+			return nil
+		}
+	}
+}
+
+func TestBot(t *testing.T) {
+	t.Parallel()
+
+	srv := newFakeServer(t, true)
+	config := testConfig(srv.addr())
+	config.Ignore = []string{"otherbot", "*!*@spam.example"}
+	config.Servers[0].TLS = true
+	config.Servers[0].TLSSkipVerify = true
+	config.Servers[0].SASL = SASL{User: "bot", Password: "secret"}
+	config.Servers[0].Channels = []string{"#test", "#keyed key"}
+	stop := connect(t, config)
+
+	// SASL PLAIN before registration
+	srv.expect("CAP REQ sasl")
+	srv.expect("NICK bot")
+	srv.expect("USER titleparser 0 * titleparser")
+	srv.send(":irc.test CAP * ACK :sasl")
+	srv.expect("AUTHENTICATE PLAIN")
+	srv.send("AUTHENTICATE +")
+	srv.expect("AUTHENTICATE " + base64.StdEncoding.EncodeToString([]byte("\x00bot\x00secret")))
+	srv.send(":irc.test 903 bot :SASL authentication successful")
+	srv.expect("CAP END")
+
+	srv.send(":irc.test 001 bot :Welcome")
+	srv.expect("JOIN #test")
+	srv.expect("JOIN #keyed key")
+
+	srv.send("PING :irc.test")
+	srv.expect("PONG irc.test")
+
+	// Nothing for ignored users, private messages, CTCP or the bot itself
+	srv.send(":otherbot!b@host PRIVMSG #test :https://example.com/ignored")
+	srv.send(":spammer!s@spam.example PRIVMSG #test :https://example.com/spam")
+	srv.send(":nick!u@host PRIVMSG bot :https://example.com/private")
+	srv.send(":nick!u@host PRIVMSG #test :\x01VERSION https://example.com/ctcp\x01")
+	srv.send(":bot!t@host PRIVMSG #test :https://example.com/self")
+
+	srv.send(":nick!u@host PRIVMSG #test :look <https://example.com/a> and https://broken.example.com/ (https://example.com/b)")
+	srv.expect("PRIVMSG #test :Title: Title of https://example.com/a")
+	srv.expect("PRIVMSG #test :Title: Title of https://example.com/b")
+
+	// The title is already in the message
+	srv.send(":nick!u@host PRIVMSG #test :Big news today! https://example.com/headline")
+
+	srv.send(":nick!u@host PRIVMSG #Test :\x01ACTION likes https://example.com/c\x01")
+	srv.expect("PRIVMSG #Test :Title: Title of https://example.com/c")
+
+	if err := stop(); err != nil {
+		t.Errorf("connect() = %v", err)
+	}
+	srv.expect("QUIT :Shutting down")
+}
+
+func TestBotThrottle(t *testing.T) {
+	t.Parallel()
+
+	srv := newFakeServer(t, false)
+	config := testConfig(srv.addr())
+	config.Throttle = Throttle{Titles: 2, PerSeconds: 3600}
+	stop := connect(t, config)
+	defer stop()
+
+	srv.welcome("bot")
+	srv.expect("JOIN #test")
+
+	// Two titles an hour, the third URL and the next message get nothing
+	srv.send(":nick!u@host PRIVMSG #test :https://example.com/1 https://example.com/2 https://example.com/3")
+	srv.expect("PRIVMSG #test :Title: Title of https://example.com/1")
+	srv.expect("PRIVMSG #test :Title: Title of https://example.com/2")
+	srv.send(":nick!u@host PRIVMSG #test :https://example.com/4")
+	srv.send(":nick!u@host PRIVMSG #other :https://example.com/5")
+	srv.expect("PRIVMSG #other :Title: Title of https://example.com/5")
+
+	srv.send("PING :done")
+	srv.expect("PONG done")
+}
+
+func TestBotMaxURLs(t *testing.T) {
+	t.Parallel()
+
+	srv := newFakeServer(t, false)
+	config := testConfig(srv.addr())
+	config.MaxURLs = 2
+	stop := connect(t, config)
+	defer stop()
+
+	srv.welcome("bot")
+	srv.expect("JOIN #test")
+
+	srv.send(":nick!u@host PRIVMSG #test :https://example.com/1 https://example.com/2 https://example.com/3")
+	srv.expect("PRIVMSG #test :Title: Title of https://example.com/1")
+	srv.expect("PRIVMSG #test :Title: Title of https://example.com/2")
+	srv.send("PING :done")
+	srv.expect("PONG done")
+}
+
+func TestBotFlood(t *testing.T) {
+	t.Parallel()
+
+	srv := newFakeServer(t, false)
+	config := testConfig(srv.addr())
+	config.Flood = Flood{Burst: 1, IntervalMillis: 200}
+	stop := connect(t, config)
+	defer stop()
+
+	srv.welcome("bot")
+	srv.expect("JOIN #test")
+
+	// The JOIN used the burst, every title waits for its turn
+	start := time.Now()
+	srv.send(":nick!u@host PRIVMSG #test :https://example.com/1 https://example.com/2")
+	srv.expect("PRIVMSG #test :Title: Title of https://example.com/1")
+	srv.expect("PRIVMSG #test :Title: Title of https://example.com/2")
+	if elapsed := time.Since(start); elapsed < 350*time.Millisecond {
+		t.Errorf("two titles sent in %s, want them spaced by the flood limit", elapsed)
+	}
+}
+
+func TestBotNickInUse(t *testing.T) {
+	t.Parallel()
+
+	srv := newFakeServer(t, false)
+	config := testConfig(srv.addr())
+	stop := connect(t, config)
+	defer stop()
+
+	srv.expect("NICK bot")
+	srv.expect("USER titleparser 0 * titleparser")
+	srv.send(":irc.test 433 * bot :Nickname is already in use")
+	srv.expect("NICK bot_")
+	srv.send(":irc.test 001 bot_ :Welcome")
+	srv.expect("JOIN #test")
+
+	// Messages from the old nick are someone else's
+	srv.send(":bot!u@host PRIVMSG #test :https://example.com/1")
+	srv.expect("PRIVMSG #test :Title: Title of https://example.com/1")
+}
+
+func TestBotSASLFailure(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		reply   string
+		wantErr string
+	}{
+		{"Not supported", ":irc.test CAP * NAK :sasl", "server doesn't support SASL"},
+		{"Wrong password", ":irc.test 904 bot :SASL authentication failed", "SASL authentication failed"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			srv := newFakeServer(t, false)
+			config := testConfig(srv.addr())
+			config.Servers[0].SASL = SASL{User: "bot", Password: "wrong"}
+
+			errs := make(chan error, 1)
+			go func() {
+				errs <- New(config, fakeLookup).connect(context.Background(), config.Servers[0])
+			}()
+
+			srv.expect("CAP REQ sasl")
+			srv.expect("NICK bot")
+			srv.expect("USER titleparser 0 * titleparser")
+			if strings.Contains(tt.reply, " 904 ") {
+				srv.send(":irc.test CAP * ACK :sasl")
+				srv.expect("AUTHENTICATE PLAIN")
+			}
+			srv.send(tt.reply)
+
+			select {
+			case err := <-errs:
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("connect() = %v, want %q", err, tt.wantErr)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("connect() didn't give up")
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package irc
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Config for the IRC bot, loaded from the file in IRC_CONFIG
+type Config struct {
+	Nick     string `yaml:"nick" json:"nick"`
+	User     string `yaml:"user" json:"user"`
+	RealName string `yaml:"realname" json:"realname"`
+	// Prefix is put before every title
+	Prefix string `yaml:"prefix" json:"prefix"`
+	// MaxURLs is how many URLs of a single message get a title
+	MaxURLs int `yaml:"max_urls" json:"max_urls"`
+	// Throttle limits the titles per channel
+	Throttle Throttle `yaml:"throttle" json:"throttle"`
+	// Flood limits the messages sent to a server, so the bot doesn't get kicked for flooding
+	Flood Flood `yaml:"flood" json:"flood"`
+	// Ignore has nick!user@host masks of users whose messages get no titles,
+	// * and ? are wildcards and a mask without ! is a nick
+	Ignore  []string `yaml:"ignore" json:"ignore"`
+	Servers []Server `yaml:"servers" json:"servers"`
+}
+
+// Throttle allows Titles titles per PerSeconds in a channel, the rest are dropped
+type Throttle struct {
+	Titles     int `yaml:"titles" json:"titles"`
+	PerSeconds int `yaml:"per_seconds" json:"per_seconds"`
+}
+
+// Flood allows Burst messages at once and one every IntervalMillis after that,
+// the rest wait their turn
+type Flood struct {
+	Burst          int `yaml:"burst" json:"burst"`
+	IntervalMillis int `yaml:"interval_ms" json:"interval_ms"`
+}
+
+// Server is one IRC network
+type Server struct {
+	Name string `yaml:"name" json:"name"`
+	// Address is host:port
+	Address string `yaml:"address" json:"address"`
+	TLS     bool   `yaml:"tls" json:"tls"`
+	// TLSSkipVerify accepts any certificate, only for testing and self-signed servers
+	TLSSkipVerify bool `yaml:"tls_skip_verify" json:"tls_skip_verify"`
+	// Password is the server password sent with PASS
+	Password string `yaml:"password" json:"password"`
+	SASL     SASL   `yaml:"sasl" json:"sasl"`
+	// Nick overrides the global nick on this server
+	Nick string `yaml:"nick" json:"nick"`
+	// Channels to join, "#channel key" for channels with a key
+	Channels []string `yaml:"channels" json:"channels"`
+	// Ignore is added to the global ignore list on this server
+	Ignore []string `yaml:"ignore" json:"ignore"`
+}
+
+// SASL PLAIN credentials, the connection fails if authentication fails
+type SASL struct {
+	User     string `yaml:"user" json:"user"`
+	Password string `yaml:"password" json:"password"`
+}
+
+// DefaultConfig has everything but the servers
+func DefaultConfig() Config {
+	return Config{
+		Nick:     "titleparser",
+		User:     "titleparser",
+		RealName: "titleparser",
+		Prefix:   "Title: ",
+		MaxURLs:  3,
+		Throttle: Throttle{Titles: 5, PerSeconds: 60},
+		Flood:    Flood{Burst: 4, IntervalMillis: 2000},
+	}
+}
+
+// LoadConfig reads the configuration from a YAML or JSON file
+// Values not set in the file keep their defaults
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path comes from local configuration
+	if err != nil {
+		return Config{}, errors.Wrap(err, "Could not read IRC config file")
+	}
+
+	return ParseConfig(data, strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// ParseConfig reads configuration from YAML or JSON data on top of the defaults
+func ParseConfig(data []byte, format string) (Config, error) {
+	c := DefaultConfig()
+
+	var err error
+	switch format {
+	case "json":
+		err = json.Unmarshal(data, &c)
+	case "yaml", "yml":
+		err = yaml.Unmarshal(data, &c)
+	default:
+		return c, fmt.Errorf("unknown config format %q", format)
+	}
+	if err != nil {
+		return c, errors.Wrap(err, "Could not parse IRC config")
+	}
+
+	return c, c.Validate()
+}
+
+// Validate checks there's something to connect to
+func (c Config) Validate() error {
+	if len(c.Servers) == 0 {
+		return errors.New("no IRC servers configured")
+	}
+	for i, server := range c.Servers {
+		if server.Address == "" {
+			return errors.Errorf("IRC server %d has no address", i+1)
+		}
+		if c.Nick == "" && server.Nick == "" {
+			return errors.Errorf("no nick for IRC server %s", server.Address)
+		}
+	}
+	if c.Throttle.Titles < 1 || c.Throttle.PerSeconds < 1 {
+		return errors.New("IRC throttle needs at least one title per second")
+	}
+	if c.Flood.Burst < 1 || c.Flood.IntervalMillis < 1 {
+		return errors.New("IRC flood limit needs a burst and an interval")
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package irc
+
+import (
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	t.Parallel()
+
+	yamlConfig := `
+nick: titlebot
+ignore: [otherbot]
+throttle:
+  titles: 2
+servers:
+  - name: libera
+    address: irc.libera.chat:6697
+    tls: true
+    sasl:
+      user: titlebot
+      password: secret
+    channels: ["#titleparser", "#secret key"]
+`
+
+	c, err := ParseConfig([]byte(yamlConfig), "yaml")
+	if err != nil {
+		t.Fatalf("ParseConfig() error = %v", err)
+	}
+	if c.Nick != "titlebot" || c.Prefix != "Title: " || c.Throttle != (Throttle{Titles: 2, PerSeconds: 60}) {
+		t.Errorf("ParseConfig() = %+v, want the file values on top of the defaults", c)
+	}
+	server := c.Servers[0]
+	if !server.TLS || server.SASL.Password != "secret" || len(server.Channels) != 2 {
+		t.Errorf("ParseConfig() server = %+v", server)
+	}
+
+	tests := []struct {
+		name   string
+		data   string
+		format string
+	}{
+		{"No servers", `{"nick": "bot"}`, "json"},
+		{"No address", `{"servers": [{"name": "x"}]}`, "json"},
+		{"No nick", `{"nick": "", "servers": [{"address": "irc.test:6667"}]}`, "json"},
+		{"No throttle", `{"throttle": {"titles": 0}, "servers": [{"address": "irc.test:6667"}]}`, "json"},
+		{"Unknown format", `nick = "bot"`, "toml"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := ParseConfig([]byte(tt.data), tt.format); err == nil {
+				t.Error("ParseConfig() accepted an invalid config")
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package irc
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// bucket is a token bucket: capacity tokens at most, refilled at one token per interval
+type bucket struct {
+	capacity float64
+	tokens   float64
+	interval time.Duration
+	last     time.Time
+}
+
+func newBucket(capacity int, interval time.Duration, now time.Time) *bucket {
+	return &bucket{capacity: float64(capacity), tokens: float64(capacity), interval: interval, last: now}
+}
+
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += float64(elapsed) / float64(b.interval)
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+		b.last = now
+	}
+}
+
+// take uses a token if there's one
+func (b *bucket) take(now time.Time) bool {
+	b.refill(now)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// wait returns how long until the next token is available
+func (b *bucket) wait(now time.Time) time.Duration {
+	b.refill(now)
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) * float64(b.interval))
+}
+
+// throttle limits the titles per channel, channel names are case insensitive
+type throttle struct {
+	mu       sync.Mutex
+	config   Throttle
+	channels map[string]*bucket
+}
+
+func newThrottle(config Throttle) *throttle {
+	return &throttle{config: config, channels: make(map[string]*bucket)}
+}
+
+// allow takes a title from the channel's allowance
+func (t *throttle) allow(channel string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	channel = strings.ToLower(channel)
+	b, ok := t.channels[channel]
+	if !ok {
+		interval := time.Duration(t.config.PerSeconds) * time.Second / time.Duration(t.config.Titles)
+		b = newBucket(t.config.Titles, interval, now)
+		t.channels[channel] = b
+	}
+	return b.take(now)
+}
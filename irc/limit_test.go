@@ -0,0 +1,85 @@
+package irc
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestBucket(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1000, 0)
+	b := newBucket(2, time.Second, now)
+
+	if !b.take(now) || !b.take(now) {
+		t.Fatal("burst not allowed")
+	}
+	if b.take(now) {
+		t.Error("took more than the capacity")
+	}
+	if wait := b.wait(now); wait != time.Second {
+		t.Errorf("wait() = %s, want 1s", wait)
+	}
+	if wait := b.wait(now.Add(400 * time.Millisecond)); wait != 600*time.Millisecond {
+		t.Errorf("wait() = %s, want 600ms", wait)
+	}
+	if !b.take(now.Add(time.Second)) {
+		t.Error("token not refilled")
+	}
+
+	// Refilling stops at the capacity
+	later := now.Add(time.Hour)
+	if !b.take(later) || !b.take(later) || b.take(later) {
+		t.Error("refilled past the capacity")
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1000, 0)
+	th := newThrottle(Throttle{Titles: 2, PerSeconds: 60})
+
+	if !th.allow("#chan", now) || !th.allow("#CHAN", now) {
+		t.Fatal("titles within the limit throttled")
+	}
+	if th.allow("#Chan", now) {
+		t.Error("third title in a minute allowed")
+	}
+	if !th.allow("#other", now) {
+		t.Error("other channel throttled")
+	}
+	if !th.allow("#chan", now.Add(30*time.Second)) {
+		t.Error("title not allowed after the interval")
+	}
+}
+
+func TestFit(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		text     string
+		maxBytes int
+		want     string
+	}{
+		{"Short", "Title: hello", 20, "Title: hello"},
+		{"Truncated", "Title: hello world again", 18, "Title: hello..."},
+		{"Multi-byte", strings.Repeat("ä", 20), 11, "ääää..."},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := fit(tt.text, tt.maxBytes)
+			if got != tt.want {
+				t.Errorf("fit() = %q, want %q", got, tt.want)
+			}
+			if len(got) > tt.maxBytes || !utf8.ValidString(got) {
+				t.Errorf("fit() = %q, %d bytes", got, len(got))
+			}
+		})
+	}
+}
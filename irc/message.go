@@ -0,0 +1,130 @@
+package irc
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Message is a single IRC protocol line, RFC 1459 with IRCv3 tags ignored
+type Message struct {
+	// Prefix is the source, nick!user@host for users
+	Prefix  string
+	Command string
+	Params  []string
+}
+
+// ParseMessage parses a line without the trailing CRLF
+func ParseMessage(line string) (Message, error) {
+	line = strings.TrimRight(line, "\r\n")
+	var m Message
+
+	if strings.HasPrefix(line, "@") {
+		end := strings.IndexByte(line, ' ')
+		if end < 0 {
+			return m, errors.Errorf("no command in %q", line)
+		}
+		line = strings.TrimLeft(line[end:], " ")
+	}
+
+	if strings.HasPrefix(line, ":") {
+		end := strings.IndexByte(line, ' ')
+		if end < 0 {
+			return m, errors.Errorf("no command in %q", line)
+		}
+		m.Prefix = line[1:end]
+		line = strings.TrimLeft(line[end:], " ")
+	}
+
+	for line != "" {
+		if strings.HasPrefix(line, ":") {
+			m.Params = append(m.Params, line[1:])
+			break
+		}
+		end := strings.IndexByte(line, ' ')
+		if end < 0 {
+			m.Params = append(m.Params, line)
+			break
+		}
+		m.Params = append(m.Params, line[:end])
+		line = strings.TrimLeft(line[end:], " ")
+	}
+
+	if len(m.Params) == 0 {
+		return m, errors.Errorf("no command in %q", line)
+	}
+	m.Command = strings.ToUpper(m.Params[0])
+	m.Params = m.Params[1:]
+	return m, nil
+}
+
+// Param returns the nth parameter or "" if there aren't that many
+func (m Message) Param(n int) string {
+	if n < len(m.Params) {
+		return m.Params[n]
+	}
+	return ""
+}
+
+// Nick is the nick part of the prefix
+func (m Message) Nick() string {
+	if end := strings.IndexAny(m.Prefix, "!@"); end >= 0 {
+		return m.Prefix[:end]
+	}
+	return m.Prefix
+}
+
+// isChannel checks if the target is a channel and not a nick
+func isChannel(target string) bool {
+	return target != "" && strings.ContainsRune("#&+!", rune(target[0]))
+}
+
+// line formats a command with the last parameter as the trailing one
+func line(command string, params ...string) string {
+	var b strings.Builder
+	b.WriteString(command)
+	for i, param := range params {
+		b.WriteByte(' ')
+		if i == len(params)-1 && (param == "" || strings.ContainsRune(param, ' ') || strings.HasPrefix(param, ":")) {
+			b.WriteByte(':')
+		}
+		// A line break would end the command and start another one
+		b.WriteString(strings.NewReplacer("\r", " ", "\n", " ", "\x00", "").Replace(param))
+	}
+	return b.String()
+}
+
+// mask is a compiled nick!user@host ignore mask
+type mask struct {
+	re *regexp.Regexp
+}
+
+// compileMasks turns the wildcard masks into regexps, nick only masks match any user and host
+func compileMasks(patterns []string) []mask {
+	masks := make([]mask, 0, len(patterns))
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if !strings.ContainsAny(pattern, "!@") {
+			pattern += "!*@*"
+		}
+		expr := regexp.QuoteMeta(strings.ToLower(pattern))
+		expr = strings.NewReplacer(`\*`, `.*`, `\?`, `.`).Replace(expr)
+		masks = append(masks, mask{re: regexp.MustCompile("^" + expr + "$")})
+	}
+	return masks
+}
+
+// ignored checks the message source against the masks
+func ignored(masks []mask, prefix string) bool {
+	prefix = strings.ToLower(prefix)
+	for _, m := range masks {
+		if m.re.MatchString(prefix) {
+			return true
+		}
+	}
+	return false
+}
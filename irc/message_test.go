@@ -0,0 +1,94 @@
+package irc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMessage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		line    string
+		want    Message
+		wantErr bool
+	}{
+		{"Privmsg", ":nick!user@host PRIVMSG #chan :hello there\r\n", Message{Prefix: "nick!user@host", Command: "PRIVMSG", Params: []string{"#chan", "hello there"}}, false},
+		{"Ping without prefix", "PING :irc.example.com", Message{Command: "PING", Params: []string{"irc.example.com"}}, false},
+		{"Numeric", ":irc.test 001 bot :Welcome to IRC", Message{Prefix: "irc.test", Command: "001", Params: []string{"bot", "Welcome to IRC"}}, false},
+		{"Tags", "@time=2026-01-01T00:00:00Z;account=nick :nick!u@h PRIVMSG #chan :hi", Message{Prefix: "nick!u@h", Command: "PRIVMSG", Params: []string{"#chan", "hi"}}, false},
+		{"Lower case command", "ping x", Message{Command: "PING", Params: []string{"x"}}, false},
+		{"Empty trailing", ":n!u@h PRIVMSG #chan :", Message{Prefix: "n!u@h", Command: "PRIVMSG", Params: []string{"#chan", ""}}, false},
+		{"Extra spaces", ":irc.test  CAP  *  ACK :sasl", Message{Prefix: "irc.test", Command: "CAP", Params: []string{"*", "ACK", "sasl"}}, false},
+		{"Empty", "", Message{}, true},
+		{"Only prefix", ":irc.test", Message{}, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := ParseMessage(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMessage() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLine(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		command string
+		params  []string
+		want    string
+	}{
+		{"Single word", "NICK", []string{"bot"}, "NICK bot"},
+		{"Trailing with spaces", "PRIVMSG", []string{"#chan", "Title: hello"}, "PRIVMSG #chan :Title: hello"},
+		{"Trailing starting with colon", "PRIVMSG", []string{"#chan", ":)"}, "PRIVMSG #chan ::)"},
+		{"Line breaks", "PRIVMSG", []string{"#chan", "one\r\nQUIT :injected"}, "PRIVMSG #chan :one  QUIT :injected"},
+		{"Channel key", "JOIN", []string{"#chan", "key"}, "JOIN #chan key"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := line(tt.command, tt.params...); got != tt.want {
+				t.Errorf("line() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIgnored(t *testing.T) {
+	t.Parallel()
+
+	masks := compileMasks([]string{"OtherBot", "*!*@*.spam.example", "troll?!*@*", " "})
+
+	tests := []struct {
+		prefix string
+		want   bool
+	}{
+		{"otherbot!bot@host.example", true},
+		{"otherbot2!bot@host.example", false},
+		{"nick!user@a.spam.example", true},
+		{"nick!user@spam.example.com", false},
+		{"troll1!x@y", true},
+		{"troll12!x@y", false},
+		{"nick!user@host", false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.prefix, func(t *testing.T) {
+			t.Parallel()
+			if got := ignored(masks, tt.prefix); got != tt.want {
+				t.Errorf("ignored(%q) = %v, want %v", tt.prefix, got, tt.want)
+			}
+		})
+	}
+}
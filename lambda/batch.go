@@ -0,0 +1,203 @@
+package lambda
+
+import (
+	"context"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultBatchConcurrency is how many URLs of a batch are fetched at the same time
+	DefaultBatchConcurrency = 8
+	// MaxBatchConcurrency caps the concurrency a batch can ask for
+	MaxBatchConcurrency = 32
+)
+
+// BatchQuery is a list of URLs sharing the user, channel and language settings
+type BatchQuery struct {
+	User      string   `json:"user"`
+	Channel   string   `json:"channel"`
+	URLs      []string `json:"urls"`
+	Languages []string `json:"languages,omitempty"`
+	Locale    string   `json:"locale,omitempty"`
+	// Concurrency limits parallel fetches, DefaultBatchConcurrency if not set
+	Concurrency int `json:"concurrency,omitempty"`
+}
+
+// BatchItem is the result for one URL of a batch, Error is set if the lookup failed
+type BatchItem struct {
+	TitleQuery
+	Error string `json:"error,omitempty"`
+	// Redundant is set when the title was left out because the message already had it
+	Redundant bool `json:"redundant,omitempty"`
+}
+
+// BatchResult has a result for every URL of the batch, in the same order
+type BatchResult struct {
+	Results []BatchItem `json:"results"`
+}
+
+// Failed returns the number of URLs that couldn't be resolved
+func (r BatchResult) Failed() int {
+	failed := 0
+	for _, item := range r.Results {
+		if item.Error != "" {
+			failed++
+		}
+	}
+	return failed
+}
+
+// HandleBatch resolves all URLs of the batch like HandleRequest would, but
+// reads and writes the cache in batches and fetches with a bounded pool
+func HandleBatch(ctx context.Context, batch BatchQuery) BatchResult {
+	log.Infof("Handling batch of %d URLs for %s", len(batch.URLs), batch.Channel)
+
+	results := make([]BatchItem, len(batch.URLs))
+	contexts := make([]context.Context, len(batch.URLs))
+
+	// Policy, languages and locale first, denied and silent URLs are done here
+	var pending []int
+	for i, url := range batch.URLs {
+		query := TitleQuery{
+			User:      batch.User,
+			Channel:   batch.Channel,
+			URL:       url,
+			Languages: batch.Languages,
+			Locale:    batch.Locale,
+		}
+		qctx, query, done, err := prepare(ctx, query)
+		results[i].TitleQuery = query
+		if err != nil {
+			results[i].Error = err.Error()
+		}
+		if done {
+			continue
+		}
+		contexts[i] = qctx
+		pending = append(pending, i)
+	}
+
+	// The same page twice in a batch is only fetched once
+	first := make(map[string]int)
+	var unique, duplicates []int
+	for _, i := range pending {
+		key := languageCacheKey(results[i].URL, results[i].Languages, results[i].Locale)
+		if _, ok := first[key]; ok {
+			duplicates = append(duplicates, i)
+			continue
+		}
+		first[key] = i
+		unique = append(unique, i)
+	}
+
+	misses := unique
+	var blocked map[int]bool
+	if CacheEnabled(ctx) {
+		misses, blocked = batchFromCache(ctx, results, unique)
+	}
+
+	fetchPool(batch.Concurrency, misses, func(i int) {
+		query, title, err := fetch(contexts[i], results[i].TitleQuery)
+		if err != nil {
+			query.Title = ""
+			results[i] = BatchItem{TitleQuery: query, Error: err.Error()}
+			return
+		}
+		results[i].TitleQuery = stamp(query, title)
+	})
+
+	if CacheEnabled(ctx) {
+		var store []TitleQuery
+		for _, i := range unique {
+			if results[i].Error == "" && !blocked[i] {
+				store = append(store, results[i].TitleQuery)
+			}
+		}
+		if err := storeCacheBatch(ctx, store); err != nil {
+			log.Warnf("Could not cache batch results: %v", err)
+		}
+	}
+
+	for _, i := range duplicates {
+		original := results[first[languageCacheKey(results[i].URL, results[i].Languages, results[i].Locale)]]
+		// the fragment can differ, so the original URL is kept
+		url := results[i].URL
+		results[i] = original
+		results[i].URL = url
+	}
+
+	for _, i := range pending {
+		if results[i].Error == "" {
+			results[i].TitleQuery = decorate(contexts[i], results[i].TitleQuery)
+		}
+	}
+
+	return BatchResult{Results: results}
+}
+
+// batchFromCache fills in the cached results and returns the indexes that
+// weren't cached, and the cached ones the channel policy doesn't allow
+func batchFromCache(ctx context.Context, results []BatchItem, indexes []int) ([]int, map[int]bool) {
+	queries := make([]TitleQuery, 0, len(indexes))
+	for _, i := range indexes {
+		queries = append(queries, results[i].TitleQuery)
+	}
+
+	cached, err := lookupCacheBatch(ctx, queries)
+	if err != nil {
+		log.Warnf("Batch cache lookup failed, fetching everything: %v", err)
+	}
+
+	var misses []int
+	blocked := make(map[int]bool)
+	for _, i := range indexes {
+		query := results[i].TitleQuery
+		hit, ok := cached[languageCacheKey(query.URL, query.Languages, query.Locale)]
+		if !ok {
+			misses = append(misses, i)
+			continue
+		}
+		// The cache is shared, the result may have been redirected somewhere this channel doesn't allow
+		if silent, err := policyFor(query.Channel).CheckChain(hit.RedirectChain); silent || err != nil {
+			blocked[i] = true
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+			continue
+		}
+		results[i].TitleQuery = stamp(fromCache(query, hit), hit.Title)
+	}
+
+	return misses, blocked
+}
+
+// fetchPool runs work for every index with at most concurrency goroutines
+func fetchPool(concurrency int, indexes []int, work func(int)) {
+	if concurrency <= 0 {
+		concurrency = DefaultBatchConcurrency
+	}
+	if concurrency > MaxBatchConcurrency {
+		concurrency = MaxBatchConcurrency
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(indexes); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				work(i)
+			}
+		}()
+	}
+
+	// work gets the request context, so a cancelled batch drains quickly
+	for _, i := range indexes {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,175 @@
+package lambda
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHandleBatch(t *testing.T) {
+	var running, maxRunning, requests int32
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		mu.Lock()
+		seen[r.URL.Path]++
+		mu.Unlock()
+
+		now := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			old := atomic.LoadInt32(&maxRunning)
+			if now <= old || atomic.CompareAndSwapInt32(&maxRunning, old, now) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, "<html><head><title>Page %s</title></head></html>", r.URL.Path)
+	}))
+	defer srv.Close()
+
+	t.Setenv("RUNMODE", "local")
+	c := DefaultConfig()
+	c.Policy.Denied = []string{"denied.example.com"}
+	SetConfig(c)
+	t.Cleanup(func() { SetConfig(DefaultConfig()) })
+
+	batch := BatchQuery{
+		Channel: "#test",
+		URLs: []string{
+			srv.URL + "/a",
+			srv.URL + "/missing",
+			"https://denied.example.com/",
+			srv.URL + "/b",
+			srv.URL + "/a#section",
+			srv.URL + "/c",
+			srv.URL + "/d",
+		},
+		Concurrency: 2,
+	}
+	res := HandleBatch(context.Background(), batch)
+
+	if len(res.Results) != len(batch.URLs) {
+		t.Fatalf("HandleBatch() returned %d results, want %d", len(res.Results), len(batch.URLs))
+	}
+
+	want := []struct {
+		title   string
+		failed  bool
+		channel string
+	}{
+		{"Page /a", false, "#test"},
+		{"", true, "#test"},
+		{"", true, "#test"},
+		{"Page /b", false, "#test"},
+		{"Page /a", false, "#test"},
+		{"Page /c", false, "#test"},
+		{"Page /d", false, "#test"},
+	}
+	for i, w := range want {
+		got := res.Results[i]
+		if got.URL != batch.URLs[i] {
+			t.Errorf("result %d URL = %v, want %v", i, got.URL, batch.URLs[i])
+		}
+		if got.Title != w.title || (got.Error != "") != w.failed || got.Channel != w.channel {
+			t.Errorf("result %d = %q, error %q, channel %q, want %q, failed %v", i, got.Title, got.Error, got.Channel, w.title, w.failed)
+		}
+	}
+	if res.Failed() != 2 {
+		t.Errorf("Failed() = %d, want 2", res.Failed())
+	}
+
+	if maxRunning > 2 {
+		t.Errorf("%d concurrent fetches, want at most 2", maxRunning)
+	}
+	if seen["/a"] != 1 {
+		t.Errorf("/a fetched %d times, want once", seen["/a"])
+	}
+}
+
+func TestFetchPool(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		concurrency int
+		jobs        int
+		wantMax     int32
+	}{
+		{"Default", 0, 50, DefaultBatchConcurrency},
+		{"Capped", 1000, 100, MaxBatchConcurrency},
+		{"Fewer jobs than workers", 8, 3, 3},
+		{"Serial", 1, 5, 1},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var running, maxRunning int32
+			done := make([]bool, tt.jobs)
+			indexes := make([]int, tt.jobs)
+			for i := range indexes {
+				indexes[i] = i
+			}
+
+			fetchPool(tt.concurrency, indexes, func(i int) {
+				now := atomic.AddInt32(&running, 1)
+				for {
+					old := atomic.LoadInt32(&maxRunning)
+					if now <= old || atomic.CompareAndSwapInt32(&maxRunning, old, now) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				done[i] = true
+				atomic.AddInt32(&running, -1)
+			})
+
+			for i, ok := range done {
+				if !ok {
+					t.Errorf("job %d not run", i)
+				}
+			}
+			if maxRunning > tt.wantMax {
+				t.Errorf("%d jobs ran at the same time, want at most %d", maxRunning, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestChunks(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		items []int
+		size  int
+		want  [][]int
+	}{
+		{"Empty", nil, 3, nil},
+		{"Exact", []int{1, 2, 3}, 3, [][]int{{1, 2, 3}}},
+		{"Remainder", []int{1, 2, 3, 4, 5}, 2, [][]int{{1, 2}, {3, 4}, {5}}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := chunks(tt.items, tt.size); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("chunks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
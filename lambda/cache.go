@@ -16,12 +16,21 @@ import (
 
 // CheckCache will return a non-empty string if the URL given is in the cache
 func CheckCache(query TitleQuery) (string, error) {
+	cached, err := lookupCache(query)
+	if err != nil {
+		return "", err
+	}
+	return cached.Title, nil
+}
+
+// lookupCache returns the whole cached item for the URL
+func lookupCache(query TitleQuery) (TitleQuery, error) {
 	ctx := context.TODO()
 
 	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion("eu-west-1"))
 	if err != nil {
 		log.Errorf("could not connect to AWS %v", err)
-		return "", err
+		return TitleQuery{}, err
 	}
 
 	// Create DynamoDB client
@@ -29,7 +38,7 @@ func CheckCache(query TitleQuery) (string, error) {
 
 	input := &dynamodb.GetItemInput{
 		Key: map[string]types.AttributeValue{
-			"url": &types.AttributeValueMemberS{Value: query.URL},
+			"url": &types.AttributeValueMemberS{Value: languageCacheKey(query.URL, query.Languages, query.Locale)},
 		},
 		TableName: aws.String("urls"),
 	}
@@ -39,21 +48,73 @@ func CheckCache(query TitleQuery) (string, error) {
 	// Error when fetching
 	if err != nil {
 		logDynamoDBError(err)
-		return "", errors.New(err.Error())
+		return TitleQuery{}, errors.New(err.Error())
 	}
 
-	// Grab the title from the result and return it
 	// TODO:
 	// optionally update ttl in DB -> frequent stuff gets cached longer
 
-	if result.Item != nil {
-		titleAttr, ok := result.Item["title"].(*types.AttributeValueMemberS)
-		if !ok {
-			return "", errors.New("Cache miss")
+	if result.Item == nil {
+		return TitleQuery{}, errors.New("Cache miss")
+	}
+
+	if _, ok := result.Item["title"].(*types.AttributeValueMemberS); !ok {
+		return TitleQuery{}, errors.New("Cache miss")
+	}
+
+	var cached TitleQuery
+	if err := attributevalue.UnmarshalMap(result.Item, &cached); err != nil {
+		log.Errorf("error unmarshaling from dynamodb: %v", err)
+		return TitleQuery{}, err
+	}
+
+	return cached, nil
+}
+
+// CachedItem returns the cached result for the URL with the languages and
+// locale the query would get, for inspecting the cache
+func CachedItem(query TitleQuery) (TitleQuery, error) {
+	return lookupCache(cacheQuery(query))
+}
+
+// PurgeCache removes the cached result for the URL, and its canonical URL
+// alias if there's one. Returns the removed keys.
+func PurgeCache(ctx context.Context, query TitleQuery) ([]string, error) {
+	query = cacheQuery(query)
+	keys := []string{languageCacheKey(query.URL, query.Languages, query.Locale)}
+	if cached, err := lookupCache(query); err == nil && cached.Canonical != "" {
+		query.Canonical = cached.Canonical
+		keys = cacheKeys(query)
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion("eu-west-1"))
+	if err != nil {
+		log.Errorf("could not connect to AWS %v", err)
+		return nil, err
+	}
+	svc := dynamodb.NewFromConfig(cfg)
+
+	for _, key := range keys {
+		_, err := svc.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+			Key: map[string]types.AttributeValue{
+				"url": &types.AttributeValueMemberS{Value: key},
+			},
+			TableName: aws.String(cacheTable),
+		})
+		if err != nil {
+			logDynamoDBError(err)
+			return nil, err
 		}
-		return titleAttr.Value, nil
 	}
-	return "", errors.New("Cache miss")
+
+	return keys, nil
+}
+
+// cacheQuery picks the languages and locale like a request would, they are part of the cache key
+func cacheQuery(query TitleQuery) TitleQuery {
+	query.Languages = languagesFor(query)
+	query.Locale = localeFor(query).Tag
+	return query
 }
 
 // CacheAndReturn inserts a successfully found title to cache
@@ -74,30 +135,189 @@ func CacheAndReturn(query TitleQuery, title string, err error) (TitleQuery, erro
 	// Create DynamoDB client
 	svc := dynamodb.NewFromConfig(cfg)
 	// create a map for DD
-	query.Title = title
-	query.Added = time.Now().Unix()
-	query.TTL = time.Now().Unix() + 86400 // 24 hours
+	query = stamp(query, title)
 
 	log.Infof("Storing TitleQuery: %v", query)
 
-	av, err := attributevalue.MarshalMap(query)
+	// Stored under the canonical URL and rel=canonical alias,
+	// the query itself keeps the original
+	for _, key := range cacheKeys(query) {
+		item := query
+		item.URL = key
+
+		av, err := attributevalue.MarshalMap(item)
+		if err != nil {
+			log.Errorf("error marshaling to dynamodb: %v", err)
+			return TitleQuery{}, err
+		}
+
+		// construct an input that DD can handle
+		input := &dynamodb.PutItemInput{
+			Item:      av,
+			TableName: aws.String("urls"),
+		}
+		// put item in DD
+		_, err = svc.PutItem(ctx, input)
+		if err != nil {
+			logDynamoDBError(err)
+			return query, err
+		}
+	}
+
+	return query, nil
+}
+
+// cacheKeys returns the keys the result is stored under: the URL and the
+// canonical URL of the page if it differs, both for the requested languages
+func cacheKeys(query TitleQuery) []string {
+	keys := []string{languageCacheKey(query.URL, query.Languages, query.Locale)}
+	if query.Canonical != "" {
+		if alias := languageCacheKey(query.Canonical, query.Languages, query.Locale); alias != keys[0] {
+			keys = append(keys, alias)
+		}
+	}
+	return keys
+}
+
+const (
+	// cacheTable is the DynamoDB table for cached titles
+	cacheTable = "urls"
+	// DynamoDB limits for a single batch request
+	maxBatchGet   = 100
+	maxBatchWrite = 25
+	// batchRetries is how many times unprocessed items are retried
+	batchRetries = 3
+)
+
+// lookupCacheBatch reads the cached items for all queries with BatchGetItem.
+// The result maps cache keys to items, misses are left out.
+func lookupCacheBatch(ctx context.Context, queries []TitleQuery) (map[string]TitleQuery, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion("eu-west-1"))
 	if err != nil {
-		log.Errorf("error marshaling to dynamodb: %v", err)
-		return TitleQuery{}, err
+		log.Errorf("could not connect to AWS %v", err)
+		return nil, err
 	}
+	svc := dynamodb.NewFromConfig(cfg)
 
-	// construct an input that DD can handle
-	input := &dynamodb.PutItemInput{
-		Item:      av,
-		TableName: aws.String("urls"),
+	var keys []string
+	seen := make(map[string]bool)
+	for _, query := range queries {
+		key := languageCacheKey(query.URL, query.Languages, query.Locale)
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	found := make(map[string]TitleQuery)
+	for _, chunk := range chunks(keys, maxBatchGet) {
+		request := make([]map[string]types.AttributeValue, 0, len(chunk))
+		for _, key := range chunk {
+			request = append(request, map[string]types.AttributeValue{
+				"url": &types.AttributeValueMemberS{Value: key},
+			})
+		}
+		items := map[string]types.KeysAndAttributes{cacheTable: {Keys: request}}
+
+		for attempt := 0; len(items) > 0; attempt++ {
+			if attempt > batchRetries {
+				log.Warnf("Giving up on %d unprocessed cache reads", len(items[cacheTable].Keys))
+				break
+			}
+			if attempt > 0 {
+				time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+			}
+
+			result, err := svc.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{RequestItems: items})
+			if err != nil {
+				logDynamoDBError(err)
+				return found, err
+			}
+
+			for _, item := range result.Responses[cacheTable] {
+				if _, ok := item["title"].(*types.AttributeValueMemberS); !ok {
+					continue
+				}
+				var cached TitleQuery
+				if err := attributevalue.UnmarshalMap(item, &cached); err != nil {
+					log.Errorf("error unmarshaling from dynamodb: %v", err)
+					continue
+				}
+				found[cached.URL] = cached
+			}
+
+			items = result.UnprocessedKeys
+		}
 	}
-	// put item in DD
-	_, err = svc.PutItem(ctx, input)
+
+	return found, nil
+}
+
+// storeCacheBatch writes the results with BatchWriteItem, under the same
+// keys CacheAndReturn would use
+func storeCacheBatch(ctx context.Context, queries []TitleQuery) error {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion("eu-west-1"))
 	if err != nil {
-		logDynamoDBError(err)
+		log.Errorf("could not connect to AWS %v", err)
+		return err
+	}
+	svc := dynamodb.NewFromConfig(cfg)
+
+	// A batch can't have the same key twice
+	var requests []types.WriteRequest
+	seen := make(map[string]bool)
+	for _, query := range queries {
+		for _, key := range cacheKeys(query) {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			item := query
+			item.URL = key
+			av, err := attributevalue.MarshalMap(item)
+			if err != nil {
+				log.Errorf("error marshaling to dynamodb: %v", err)
+				return err
+			}
+			requests = append(requests, types.WriteRequest{PutRequest: &types.PutRequest{Item: av}})
+		}
 	}
 
-	return query, err
+	for _, chunk := range chunks(requests, maxBatchWrite) {
+		items := map[string][]types.WriteRequest{cacheTable: chunk}
+
+		for attempt := 0; len(items) > 0; attempt++ {
+			if attempt > batchRetries {
+				return errors.Errorf("%d cache writes unprocessed", len(items[cacheTable]))
+			}
+			if attempt > 0 {
+				time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+			}
+
+			result, err := svc.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: items})
+			if err != nil {
+				logDynamoDBError(err)
+				return err
+			}
+			items = result.UnprocessedItems
+		}
+	}
+
+	return nil
+}
+
+// chunks splits the items into slices of at most size items
+func chunks[T any](items []T, size int) [][]T {
+	var out [][]T
+	for len(items) > size {
+		out = append(out, items[:size])
+		items = items[size:]
+	}
+	if len(items) > 0 {
+		out = append(out, items)
+	}
+	return out
 }
 
 // logDynamoDBError logs known DynamoDB error types with their code and message,
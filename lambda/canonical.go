@@ -0,0 +1,59 @@
+package lambda
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/lepinkainen/titleparser/locale"
+)
+
+// CacheKey returns the canonical form of the URL used as the cache key.
+// Scheme and host are lowercased, default ports dropped and the fragment
+// removed, so all sections of a page share the same cache entry.
+func CacheKey(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil || u.Host == "" {
+		return rawurl
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if port := u.Port(); (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		u.Host = u.Hostname()
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	return u.String()
+}
+
+// languageCacheKey adds the preferred languages and output locale to the
+// cache key, titles in different languages are cached separately. English
+// titles keep the plain key. The fragment is never part of a cache key, so
+// it can't collide with a URL.
+func languageCacheKey(rawurl string, languages []string, loc string) string {
+	key := CacheKey(rawurl)
+	var variant []string
+	if len(languages) > 0 {
+		variant = append(variant, "lang="+strings.Join(languages, ","))
+	}
+	if loc != "" && loc != locale.English.Tag {
+		variant = append(variant, "locale="+loc)
+	}
+	if len(variant) > 0 {
+		key += "#" + strings.Join(variant, "&")
+	}
+	return key
+}
+
+// Fragment returns the decoded fragment of the URL, if any
+func Fragment(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return ""
+	}
+	return u.Fragment
+}
@@ -0,0 +1,117 @@
+package lambda
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the runtime configuration, loaded from the file in CONFIG_FILE
+type Config struct {
+	Policy    Policy    `yaml:"policy" json:"policy"`
+	Freshness Freshness `yaml:"freshness" json:"freshness"`
+	// Shorteners are expanded before picking the handler, so the destination
+	// gets its own handler
+	Shorteners []string `yaml:"shorteners" json:"shorteners"`
+	// Channels has per-channel overrides, keyed by channel name
+	Channels map[string]ChannelConfig `yaml:"channels" json:"channels"`
+}
+
+// ChannelConfig overrides the global configuration for a single channel
+type ChannelConfig struct {
+	Policy Policy `yaml:"policy" json:"policy"`
+	// ReadingTime adds "[8 min read]" to article titles
+	ReadingTime bool `yaml:"reading_time" json:"reading_time"`
+	// Languages are the preferred title languages when the request doesn't have any
+	Languages []string `yaml:"languages" json:"languages"`
+	// Locale is the language of counts and labels in titles, e.g. "fi"
+	Locale string `yaml:"locale" json:"locale"`
+}
+
+// activeConfig is set once at startup and only read after that
+var activeConfig = DefaultConfig()
+
+// DefaultConfig is used when no configuration file is given
+func DefaultConfig() Config {
+	return Config{
+		Policy: Policy{
+			Silent: []string{
+				// titles are always useless
+				"apina.biz",
+				// blocks external agents
+				"twitter.com",
+				// javascript-only gallery site with no API
+				"pr0gramm.com",
+			},
+		},
+		Freshness: Freshness{
+			OldAfterDays: 365,
+			Marker:       "year",
+		},
+		Shorteners: []string{
+			"bit.ly",
+			"t.co",
+			"lnkd.in",
+			"tinyurl.com",
+		},
+	}
+}
+
+// LoadConfig reads the configuration from a YAML or JSON file
+// Values not set in the file keep their defaults
+func LoadConfig(path string) error {
+	data, err := os.ReadFile(path) // #nosec G304 -- path comes from local configuration
+	if err != nil {
+		return errors.Wrap(err, "Could not read config file")
+	}
+
+	c, err := ParseConfig(data, strings.TrimPrefix(filepath.Ext(path), "."))
+	if err != nil {
+		return err
+	}
+
+	SetConfig(c)
+	return nil
+}
+
+// ParseConfig reads configuration from YAML or JSON data on top of the defaults
+func ParseConfig(data []byte, format string) (Config, error) {
+	c := DefaultConfig()
+
+	var err error
+	switch format {
+	case "json":
+		err = json.Unmarshal(data, &c)
+	case "yaml", "yml":
+		err = yaml.Unmarshal(data, &c)
+	default:
+		return c, fmt.Errorf("unknown config format %q", format)
+	}
+	if err != nil {
+		return c, errors.Wrap(err, "Could not parse config")
+	}
+
+	return c, nil
+}
+
+// SetConfig replaces the active configuration, not safe to call while requests are handled
+func SetConfig(c Config) {
+	// IRC channel names are case insensitive
+	channels := make(map[string]ChannelConfig, len(c.Channels))
+	for name, channel := range c.Channels {
+		channels[strings.ToLower(name)] = channel
+	}
+	c.Channels = channels
+
+	activeConfig = c
+}
+
+// channelConfig returns the overrides for the given channel, if any
+func channelConfig(channel string) ChannelConfig {
+	return activeConfig.Channels[strings.ToLower(channel)]
+}
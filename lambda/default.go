@@ -1,14 +1,16 @@
 package lambda
 
 import (
+	"context"
 	"fmt"
 	"net/http"
-	"regexp"
 	"strings"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/lepinkainen/titleparser/common"
+	"github.com/lepinkainen/titleparser/locale"
+	"github.com/lepinkainen/titleparser/oembed"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
@@ -21,165 +23,181 @@ var (
 
 	// TitleMax is the maximum length for a title
 	TitleMax = 200
-
-	// RemoveWhitespaceRegex marks all tokens with more than one whitespace
-	RemoveWhitespaceRegex = regexp.MustCompile(`[\s]{2,}`)
 )
 
 // DefaultHandler is the fallback for sites that don't have a special handler
-// TODO: Split to two parts: 1) fetch url 2) parse title from html
-//
-//	Tests for both parts
-func DefaultHandler(url string) (string, error) {
-	// Create request with proper browser headers to avoid User-Agent blocking
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		log.Fatal(err)
+func DefaultHandler(ctx context.Context, url string) (string, error) {
+	explanation := explanationFrom(ctx)
+
+	// Known oEmbed providers have an API for this, no need to scrape
+	if endpoint, ok := oembed.Lookup(url); ok {
+		title, err := oembedTitle(ctx, url, endpoint)
+		if err == nil {
+			explanation.Source = "oembed"
+			return title, nil
+		}
+		log.Warnf("oEmbed lookup failed for %s, scraping instead: %v", url, err)
+		explanation.decide("oEmbed lookup failed, scraping the page: %v", err)
 	}
 
-	// Set headers to avoid 403 Forbidden from sites that block Go client
-	req.Header.Set("User-Agent", common.UserAgent)
-	req.Header.Set("Accept-Language", common.AcceptLanguage)
-	req.Header.Set("Accept", common.Accept)
-
-	// Set client timeout (10 seconds, consistent with other handlers)
-	client := &http.Client{Timeout: time.Second * 10}
-
-	// Send request
-	res, err := client.Do(req)
+	page, err := FetchPage(ctx, url)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
-	defer func() {
-		if cerr := res.Body.Close(); cerr != nil {
-			log.Warnf("Failed to close response body: %v", cerr)
+	doc := page.Doc
+
+	// JS-only pages without OpenGraph tags might still link to an oEmbed endpoint
+	if doc.Find(`meta[property="og:title"]`).Size() == 0 {
+		if endpoint, ok := oembed.Discover(doc, page.URL); ok {
+			title, err := oembedTitle(ctx, page.URL, endpoint)
+			if err == nil {
+				explanation.Source = "oembed"
+				return title, nil
+			}
+			log.Warnf("Discovered oEmbed failed for %s: %v", url, err)
+			explanation.decide("discovered oEmbed failed, using the page title: %v", err)
 		}
-	}()
+	}
 
-	// Not html, don't bother parsing
-	contentType := res.Header.Get("content-type")
-	if !strings.HasPrefix(contentType, "text/html") {
-		return "", ErrNotHTML
+	title, source, err := titleFromDocument(doc)
+	if err != nil {
+		return "", err
+	}
+	explanation.Source = source
+
+	// Stored with the result, channels decide whether to show it
+	details := detailsFromContext(ctx)
+	details.ReadingTime = ReadingTime(doc)
+	// All sections are stored so every fragment of the page can use the same cache entry
+	details.Sections = Sections(doc)
+	details.Canonical = page.Canonical
+	details.FinalURL = page.URL
+	details.Redirects = append(details.Redirects, page.Redirects...)
+
+	loc := locale.From(ctx)
+
+	// Shop pages get the price and availability too
+	if product := ExtractProduct(doc); product != nil {
+		explanation.decide("product page, added price and availability")
+		return product.Format(loc, title, PageLanguage(doc)), nil
 	}
 
-	if res.StatusCode != 200 {
-		switch res.StatusCode {
-		case 403:
-			return "", errors.New("403 Forbidden")
-		case 404:
-			return "", errors.New("404 Not Found")
-		case 405:
-			return "", errors.New("405 Method Not Allowed")
-		case 429:
-			return "", errors.New("429 Too Many Requests")
-		case 500:
-			return "", errors.New("500 Internal Server Error")
-		case 502:
-			return "", errors.New("502 Bad Gateway")
-		default:
-			log.Fatalf("unhandled status code: %d (%s)", res.StatusCode, res.Status)
-			return "", errors.Wrap(err, "HTTP error")
+	// Video and audio pages get duration, release date and series info
+	if media := ExtractMedia(doc); media != nil {
+		explanation.decide("media page, added duration and release details")
+		return media.Format(loc, title), nil
+	}
+
+	// Reposted old news gets marked as such
+	if published, ok := Published(doc); ok {
+		if marker := AgeMarker(loc, published, time.Now()); marker != "" {
+			explanation.decide("published %s, added an age marker", published.Format("2006-01-02"))
+			title = fmt.Sprintf("%s %s", title, marker)
 		}
 	}
 
-	// Load the HTML document
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+	return title, nil
+}
+
+// oembedTitle fetches and formats an oEmbed response. The endpoint and its
+// redirects are checked like redirects of the page itself.
+func oembedTitle(ctx context.Context, pageURL, endpoint string) (string, error) {
+	client, err := linkedClient(ctx, pageURL, endpoint)
 	if err != nil {
-		log.Fatal(err)
-		return "", errors.Wrap(err, "Could not load HTML")
+		return "", err
+	}
+
+	res, err := oembed.Fetch(ctx, client, endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	title, err := res.Format(locale.From(ctx))
+	if err != nil {
+		return "", err
 	}
 
+	return sanitize(title), nil
+}
+
+// TitleFromDocument picks the best title from a parsed HTML document
+func TitleFromDocument(doc *goquery.Document) (string, error) {
+	title, _, err := titleFromDocument(doc)
+	return title, err
+}
+
+// titleFromDocument also returns where the title was found, og:title or title
+func titleFromDocument(doc *goquery.Document) (string, string, error) {
 	// primarily we want to use og:title
 	s := doc.Find(`meta[property="og:title"]`)
-	if s != nil && s.Size() > 0 {
-		title, _ := s.Attr("content")
-		return sanitize(title), nil
+	if title := strings.TrimSpace(s.AttrOr("content", "")); title != "" {
+		return sanitize(title), "og:title", nil
 	}
 
 	// Bleh, just a boring old title then
 	s = doc.Find("title")
-	if s != nil && s.Size() > 0 {
+	if s.Size() > 0 {
 		// Just grab the first one, some pages (ab)use the title element
-		title := s.First().Text()
-		return sanitize(title), nil
+		if title := strings.TrimSpace(s.First().Text()); title != "" {
+			return sanitize(title), "title", nil
+		}
 	}
 
 	// No title, report it
-	return "", ErrTitleNotFound
+	return "", "", ErrTitleNotFound
 }
 
-// sanitize the url by removing everything superfluous
+// sanitize cleans control and formatting characters from the title and
+// truncates it to TitleMax characters
 func sanitize(title string) string {
-	// remove extra whitespace in the middle of the title
-	// some crappy CMSes leave it all over the place
-	title = RemoveWhitespaceRegex.ReplaceAllLiteralString(title, " ")
-	// remove leading and trailing whitespace
-	title = strings.TrimSpace(title)
-	// remove newlines
-	title = strings.ReplaceAll(title, "\n", "")
-	title = strings.ReplaceAll(title, "\r", "")
-
-	// max size 200 characters. It's a title, not a goddamn novel
-	end := len(title)
-	if end > TitleMax {
-		end = TitleMax
-		title = fmt.Sprintf("%s...", title[:end])
-	}
-
-	return title
+	// It's a title, not a goddamn novel
+	return common.SanitizeTitle(title, TitleMax)
 }
 
 // ParseHTMLFromResponse extracts title from an HTTP response
 // This is used by custom handlers that need to do their own HTTP requests
 func ParseHTMLFromResponse(res *http.Response, url string) (string, error) {
+	if err := checkResponse(res, url); err != nil {
+		return "", err
+	}
+
+	// Load the HTML document
+	doc, err := goquery.NewDocumentFromReader(res.Body)
+	if err != nil {
+		log.Errorf("Could not load HTML from %s: %v", url, err)
+		return "", errors.Wrap(err, "Could not load HTML")
+	}
+
+	return TitleFromDocument(doc)
+}
+
+// checkResponse makes sure the response is a successfully fetched HTML page
+func checkResponse(res *http.Response, url string) error {
 	// Not html, don't bother parsing
 	contentType := res.Header.Get("content-type")
 	if !strings.HasPrefix(contentType, "text/html") {
-		return "", ErrNotHTML
+		return ErrNotHTML
 	}
 
 	if res.StatusCode != 200 {
 		switch res.StatusCode {
 		case 403:
-			return "", errors.New("403 Forbidden")
+			return errors.New("403 Forbidden")
 		case 404:
-			return "", errors.New("404 Not Found")
+			return errors.New("404 Not Found")
 		case 405:
-			return "", errors.New("405 Method Not Allowed")
+			return errors.New("405 Method Not Allowed")
 		case 429:
-			return "", errors.New("429 Too Many Requests")
+			return errors.New("429 Too Many Requests")
 		case 500:
-			return "", errors.New("500 Internal Server Error")
+			return errors.New("500 Internal Server Error")
 		case 502:
-			return "", errors.New("502 Bad Gateway")
+			return errors.New("502 Bad Gateway")
 		default:
 			log.Errorf("unhandled status code: %d (%s) for URL: %s", res.StatusCode, res.Status, url)
-			return "", fmt.Errorf("HTTP error: %d %s", res.StatusCode, res.Status)
+			return fmt.Errorf("HTTP error: %d %s", res.StatusCode, res.Status)
 		}
 	}
 
-	// Load the HTML document
-	doc, err := goquery.NewDocumentFromReader(res.Body)
-	if err != nil {
-		log.Errorf("Could not load HTML from %s: %v", url, err)
-		return "", errors.Wrap(err, "Could not load HTML")
-	}
-
-	// primarily we want to use og:title
-	s := doc.Find(`meta[property="og:title"]`)
-	if s != nil && s.Size() > 0 {
-		title, _ := s.Attr("content")
-		return sanitize(title), nil
-	}
-
-	// Bleh, just a boring old title then
-	s = doc.Find("title")
-	if s != nil && s.Size() > 0 {
-		// Just grab the first one, some pages (ab)use the title element
-		title := s.First().Text()
-		return sanitize(title), nil
-	}
-
-	// No title, report it
-	return "", ErrTitleNotFound
+	return nil
 }
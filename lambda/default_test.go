@@ -1,7 +1,14 @@
 package lambda
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/PuerkitoBio/goquery"
 )
 
 func TestDefaultHandler(t *testing.T) {
@@ -23,7 +30,7 @@ func TestDefaultHandler(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := DefaultHandler(tt.args.url)
+			got, err := DefaultHandler(context.Background(), tt.args.url)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("DefaultHandler() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -34,3 +41,93 @@ func TestDefaultHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestTitleFromDocument(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		html    string
+		want    string
+		wantErr bool
+	}{
+		{"OpenGraph preferred", `<html><head><title>Page | Site</title><meta property="og:title" content="Page"></head></html>`, "Page", false},
+		{"Title fallback", `<html><head><title>  Page
+			 | Site </title></head></html>`, "Page | Site", false},
+		{"Attribute order", `<html><head><meta content="Reversed attributes" property="og:title"></head></html>`, "Reversed attributes", false},
+		{"Entities", `<html><head><meta property="og:title" content="Tom &amp; Jerry&#39;s &quot;show&quot;"></head></html>`, `Tom & Jerry's "show"`, false},
+		{"Entities in title", `<html><head><title>Fish &amp; Chips &ndash; Recipes</title></head></html>`, "Fish & Chips – Recipes", false},
+		{"Empty OpenGraph title", `<html><head><meta property="og:title" content=""><title>Page Title</title></head></html>`, "Page Title", false},
+		{"Empty title", `<html><head><title></title></head></html>`, "", true},
+		{"No title", `<html><head></head><body>Hello</body></html>`, "", true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(tt.html))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := TitleFromDocument(doc)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("TitleFromDocument() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("TitleFromDocument() = '%v', want '%v'", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultHandlerOEmbedDiscovery(t *testing.T) {
+	t.Parallel()
+
+	var srv *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/shell", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<html><head><title>Loading...</title>
+<link rel="alternate" type="application/json+oembed" href="/oembed?url=x"></head><body></body></html>`)
+	})
+	mux.HandleFunc("/opengraph", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<html><head><meta property="og:title" content="OpenGraph title">
+<link rel="alternate" type="application/json+oembed" href="/oembed?url=x"></head><body></body></html>`)
+	})
+	mux.HandleFunc("/elsewhere", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		// Same server, but the page can't tell us to go to another host
+		fmt.Fprintf(w, `<html><head><title>Loading...</title>
+<link rel="alternate" type="application/json+oembed" href="%s"></head><body></body></html>`,
+			strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)+"/oembed?url=x")
+	})
+	mux.HandleFunc("/oembed", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"type":"video","version":"1.0","title":"Video title","author_name":"Author","provider_name":"Example"}`)
+	})
+	srv = httptest.NewServer(mux)
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"JS shell uses oEmbed", "/shell", "Video title by Author [Example]"},
+		{"OpenGraph preferred", "/opengraph", "OpenGraph title"},
+		{"Endpoint on another host", "/elsewhere", "Loading..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DefaultHandler(context.Background(), srv.URL+tt.path)
+			if err != nil {
+				t.Fatalf("DefaultHandler() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("DefaultHandler() = '%v', want '%v'", got, tt.want)
+			}
+		})
+	}
+}
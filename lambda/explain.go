@@ -0,0 +1,93 @@
+package lambda
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Cache statuses in an explanation
+const (
+	CacheHit      = "hit"
+	CacheMiss     = "miss"
+	CacheDisabled = "disabled"
+)
+
+// Explanation tells how the title of a request was found, returned when the
+// request sets Explain. The redirect chain and final URL are in the result.
+type Explanation struct {
+	// Handler is the handler that ran, empty for cached results
+	Handler string `json:"handler,omitempty"`
+	// Pattern is the pattern the handler matched, empty for forced and default handlers
+	Pattern string `json:"pattern,omitempty"`
+	// Decisions are the choices made along the way, in order: handlers that
+	// also matched, fallbacks, policy and formatting
+	Decisions []string `json:"decisions,omitempty"`
+	// Cache is CacheHit, CacheMiss or CacheDisabled
+	Cache string `json:"cache"`
+	// CacheAge is how old the cached result was in seconds
+	CacheAge int64 `json:"cache_age,omitempty"`
+	// Status and ContentType are from the last response, handlers calling
+	// APIs themselves report them with RecordResponse
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	// Source is where the title came from: og:title, title, oembed or handler
+	Source string `json:"source,omitempty"`
+	// Timings are the stages of the request in the order they finished
+	Timings []Timing `json:"timings"`
+}
+
+// Timing is how long a stage of the request took
+type Timing struct {
+	Stage string `json:"stage"`
+	// Millis is the duration in milliseconds
+	Millis float64 `json:"ms"`
+}
+
+// decide records a decision
+func (e *Explanation) decide(format string, args ...interface{}) {
+	e.Decisions = append(e.Decisions, fmt.Sprintf(format, args...))
+}
+
+// timed records the time since start for the stage, meant to be deferred
+func (e *Explanation) timed(stage string, start time.Time) {
+	e.Timings = append(e.Timings, Timing{Stage: stage, Millis: float64(time.Since(start).Microseconds()) / 1000})
+}
+
+// RecordResponse notes the status and content type of the response in the
+// explanation, for handlers calling an API themselves
+func RecordResponse(ctx context.Context, res *http.Response) {
+	explanation := explanationFrom(ctx)
+	explanation.Status = res.StatusCode
+	explanation.ContentType = res.Header.Get("content-type")
+}
+
+// recordingTransport records every response in the explanation of the request
+type recordingTransport struct {
+	http.RoundTripper
+}
+
+func (t recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.RoundTripper.RoundTrip(req)
+	if err == nil {
+		RecordResponse(req.Context(), res)
+	}
+	return res, err
+}
+
+type explanationKey struct{}
+
+// withExplanation starts explaining a request
+func withExplanation(ctx context.Context, explanation *Explanation) context.Context {
+	return context.WithValue(ctx, explanationKey{}, explanation)
+}
+
+// explanationFrom returns the explanation being collected for the request,
+// or a throwaway value when the request didn't ask for one
+func explanationFrom(ctx context.Context) *Explanation {
+	if explanation, ok := ctx.Value(explanationKey{}).(*Explanation); ok {
+		return explanation
+	}
+	return &Explanation{}
+}
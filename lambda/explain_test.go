@@ -0,0 +1,121 @@
+package lambda
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestHandleRequestExplain(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		switch r.URL.Path {
+		case "/og":
+			fmt.Fprint(w, `<html><head><meta property="og:title" content="OpenGraph title"><title>Page title</title></head></html>`)
+		case "/title":
+			fmt.Fprint(w, `<html><head><title>Page title</title></head></html>`)
+		case "/shell":
+			fmt.Fprint(w, `<html><head><title>Loading...</title>
+<link rel="alternate" type="application/json+oembed" href="/oembed"></head></html>`)
+		case "/oembed":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"type":"video","version":"1.0","title":"Video title"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	t.Setenv("RUNMODE", "local")
+	RegisterNamedHandler("test.Explain", `^http://127\.0\.0\.1:[0-9]+/explain/`, func(ctx context.Context, url string) (string, error) {
+		return "From handler", nil
+	})
+	RegisterNamedHandler("test.ExplainOverlap", `/explain/overlap`, registryTestHandler)
+
+	tests := []struct {
+		name    string
+		path    string
+		want    Explanation
+		wantErr bool
+	}{
+		{"OpenGraph", "/og", Explanation{
+			Handler:     DefaultHandlerName,
+			Decisions:   []string{"no handler pattern matched, using the default handler"},
+			Cache:       CacheDisabled,
+			Status:      200,
+			ContentType: "text/html; charset=utf-8",
+			Source:      "og:title",
+		}, false},
+		{"Title element", "/title", Explanation{
+			Handler:     DefaultHandlerName,
+			Decisions:   []string{"no handler pattern matched, using the default handler"},
+			Cache:       CacheDisabled,
+			Status:      200,
+			ContentType: "text/html; charset=utf-8",
+			Source:      "title",
+		}, false},
+		{"oEmbed", "/shell", Explanation{
+			Handler:     DefaultHandlerName,
+			Decisions:   []string{"no handler pattern matched, using the default handler"},
+			Cache:       CacheDisabled,
+			Status:      200,
+			ContentType: "application/json",
+			Source:      "oembed",
+		}, false},
+		{"Not found", "/missing", Explanation{
+			Handler:     DefaultHandlerName,
+			Decisions:   []string{"no handler pattern matched, using the default handler"},
+			Cache:       CacheDisabled,
+			Status:      404,
+			ContentType: "text/html; charset=utf-8",
+		}, true},
+		{"Overlapping handlers", "/explain/overlap", Explanation{
+			Handler: "test.Explain",
+			Pattern: `^http://127\.0\.0\.1:[0-9]+/explain/`,
+			Decisions: []string{
+				"test.ExplainOverlap also matched with /explain/overlap, the first match is used",
+				"test.Explain didn't report an HTTP status or content type",
+			},
+			Cache:  CacheDisabled,
+			Source: "handler",
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HandleRequest(context.Background(), TitleQuery{URL: srv.URL + tt.path, Explain: true})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("HandleRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Explanation == nil {
+				t.Fatal("HandleRequest() returned no explanation")
+			}
+
+			explanation := *got.Explanation
+			var stages []string
+			for _, timing := range explanation.Timings {
+				stages = append(stages, timing.Stage)
+			}
+			if want := []string{"policy", "fetch", "decorate", "total"}; !tt.wantErr && !reflect.DeepEqual(stages, want) {
+				t.Errorf("HandleRequest() stages = %v, want %v", stages, want)
+			}
+
+			explanation.Timings = nil
+			if !reflect.DeepEqual(explanation, tt.want) {
+				t.Errorf("HandleRequest() explanation = %+v, want %+v", explanation, tt.want)
+			}
+		})
+	}
+
+	t.Run("Not asked for", func(t *testing.T) {
+		got, err := HandleRequest(context.Background(), TitleQuery{URL: srv.URL + "/og"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Explanation != nil {
+			t.Errorf("HandleRequest() explanation = %+v, want none", got.Explanation)
+		}
+	})
+}
@@ -0,0 +1,317 @@
+package lambda
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/lepinkainen/titleparser/common"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// maxRedirects is the combined limit for HTTP, meta refresh and JavaScript redirects
+	maxRedirects = 10
+	// maxRefreshDelay is the longest meta refresh we consider a redirect,
+	// news sites reload their front pages every few minutes
+	maxRefreshDelay = 5
+	// fetchTimeout is how long fetching a page may take, redirects included
+	fetchTimeout = 10 * time.Second
+	// maxInterstitialText is the most visible text a JavaScript redirect page can have
+	maxInterstitialText = 500
+)
+
+var (
+	// ErrTooManyRedirects is returned when the redirect limit is reached
+	ErrTooManyRedirects = errors.New("Too many redirects")
+	// ErrRedirectBlocked is returned when a redirect points somewhere we won't go
+	ErrRedirectBlocked = errors.New("Redirect blocked")
+
+	// jsRedirectRegex matches the trivial location changes used by redirect pages:
+	// window.location = "...", location.href='...', location.replace("...")
+	jsRedirectRegex = regexp.MustCompile(`(?:(?:window|document|top|self)\.)?location(?:\.href)?\s*(?:=\s*|\.(?:replace|assign)\(\s*)["']([^"']+)["']`)
+)
+
+// Page is a fetched and parsed HTML page
+type Page struct {
+	Doc *goquery.Document
+	// URL is the final URL of the page after all redirects
+	URL string
+	// Redirects lists the URLs redirected to in order, not including the original
+	Redirects []string
+	// Canonical is the rel=canonical URL of the page, only set if it's on the same site
+	Canonical string
+}
+
+// FetchDocument loads the given url and parses it as HTML
+// Used by the default handler and anything else that needs the whole document
+func FetchDocument(ctx context.Context, url string) (*goquery.Document, error) {
+	page, err := FetchPage(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return page.Doc, nil
+}
+
+// FetchPage loads the given url and parses it as HTML, following HTTP,
+// meta refresh and JavaScript redirects. The page is asked for in the
+// preferred languages of the request. The whole redirect chain has to
+// finish within fetchTimeout.
+func FetchPage(ctx context.Context, rawurl string) (*Page, error) {
+	origin, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not parse URL")
+	}
+
+	// One deadline for every hop, a per-request timeout would let a long
+	// chain of slow redirects take minutes
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	page := &Page{}
+
+	client := &http.Client{
+		Transport: common.Transport(origin.Hostname()),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(page.Redirects) >= maxRedirects {
+				return ErrTooManyRedirects
+			}
+			if err := checkRedirect(ctx, origin, req.URL); err != nil {
+				return err
+			}
+			page.Redirects = append(page.Redirects, req.URL.String())
+			return nil
+		},
+	}
+
+	current := rawurl
+	for {
+		doc, final, err := fetchHTML(ctx, client, current)
+		if err != nil {
+			return nil, err
+		}
+
+		page.Doc = doc
+		page.URL = final.String()
+
+		target, ok := htmlRedirect(doc)
+		if !ok {
+			break
+		}
+		next, err := final.Parse(target)
+		if err != nil || next.String() == final.String() {
+			log.Debugf("Ignoring redirect from %s to %q", final, target)
+			break
+		}
+		if len(page.Redirects) >= maxRedirects {
+			return nil, ErrTooManyRedirects
+		}
+		if err := checkRedirect(ctx, origin, next); err != nil {
+			return nil, err
+		}
+
+		log.Infof("Following HTML redirect from %s to %s", final, next)
+		page.Redirects = append(page.Redirects, next.String())
+		current = next.String()
+	}
+
+	page.Canonical = canonicalLink(page.Doc, page.URL)
+
+	return page, nil
+}
+
+// fetchHTML does a single GET request, returning the parsed document and
+// the URL it ended up at after HTTP redirects
+func fetchHTML(ctx context.Context, client *http.Client, url string) (*goquery.Document, *url.URL, error) {
+	// Create request with proper browser headers to avoid User-Agent blocking
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Could not create request")
+	}
+
+	// Set headers to avoid 403 Forbidden from sites that block Go client
+	req.Header.Set("User-Agent", common.UserAgent)
+	req.Header.Set("Accept-Language", common.AcceptLanguageFor(ctx))
+	req.Header.Set("Accept", common.Accept)
+
+	// Send request
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Could not fetch URL")
+	}
+	defer func() {
+		if cerr := res.Body.Close(); cerr != nil {
+			log.Warnf("Failed to close response body: %v", cerr)
+		}
+	}()
+
+	explanation := explanationFrom(ctx)
+	explanation.Status = res.StatusCode
+	explanation.ContentType = res.Header.Get("content-type")
+
+	if err := checkResponse(res, url); err != nil {
+		return nil, nil, err
+	}
+
+	// Load the HTML document
+	doc, err := goquery.NewDocumentFromReader(res.Body)
+	if err != nil {
+		log.Errorf("Could not load HTML from %s: %v", url, err)
+		return nil, nil, errors.Wrap(err, "Could not load HTML")
+	}
+
+	return doc, res.Request.URL, nil
+}
+
+// checkRedirect makes sure a redirect stays on the public web and respects
+// the domain policy of the request. Redirects into internal addresses are
+// only allowed if the original URL was internal too.
+func checkRedirect(ctx context.Context, origin, target *url.URL) error {
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return errors.Wrapf(ErrRedirectBlocked, "unsupported scheme %q", target.Scheme)
+	}
+
+	if common.IsInternalHost(target.Hostname()) && !common.IsInternalHost(origin.Hostname()) {
+		return errors.Wrapf(ErrRedirectBlocked, "internal address %s", target.Hostname())
+	}
+
+	silent, err := policyFrom(ctx).Check(target.String())
+	if err != nil {
+		return err
+	}
+	if silent {
+		return errors.Wrapf(ErrRedirectBlocked, "silent domain %s", target.Hostname())
+	}
+
+	return nil
+}
+
+// linkedClient checks a URL the page pointed us to like a redirect of the page
+// and returns a client that checks its redirects the same way
+func linkedClient(ctx context.Context, pageURL, linked string) (*http.Client, error) {
+	origin, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid page URL")
+	}
+	target, err := url.Parse(linked)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid linked URL")
+	}
+	if err := checkRedirect(ctx, origin, target); err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Timeout:   fetchTimeout,
+		Transport: recordingTransport{common.Transport(origin.Hostname())},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return ErrTooManyRedirects
+			}
+			return checkRedirect(ctx, origin, req.URL)
+		},
+	}, nil
+}
+
+// htmlRedirect returns the target of a meta refresh or a JavaScript redirect
+func htmlRedirect(doc *goquery.Document) (string, bool) {
+	var target string
+	doc.Find("meta[http-equiv]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		if !strings.EqualFold(s.AttrOr("http-equiv", ""), "refresh") {
+			return true
+		}
+		var ok bool
+		target, ok = parseRefresh(s.AttrOr("content", ""))
+		return !ok
+	})
+	if target != "" {
+		return target, true
+	}
+
+	// Only pages that are nothing but a redirect, real pages use location
+	// changes all over the place in click handlers and such
+	if doc.Find(`meta[property="og:title"]`).Size() > 0 {
+		return "", false
+	}
+	body := doc.Find("body").Clone()
+	body.Find("script, style, noscript").Remove()
+	if len(strings.TrimSpace(body.Text())) > maxInterstitialText {
+		return "", false
+	}
+
+	doc.Find("script:not([src])").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		if match := jsRedirectRegex.FindStringSubmatch(s.Text()); match != nil {
+			target = match[1]
+			return false
+		}
+		return true
+	})
+
+	return target, target != ""
+}
+
+// parseRefresh parses meta refresh content like "0; url=https://example.com/"
+func parseRefresh(content string) (string, bool) {
+	delay, rest, found := strings.Cut(content, ";")
+	if !found {
+		delay, rest, found = strings.Cut(content, ",")
+		if !found {
+			// refresh without a URL just reloads the page
+			return "", false
+		}
+	}
+
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(delay), 64)
+	if err != nil || seconds > maxRefreshDelay {
+		return "", false
+	}
+
+	rest = strings.TrimSpace(rest)
+	if len(rest) > 4 && strings.EqualFold(rest[:4], "url=") {
+		rest = strings.TrimSpace(rest[4:])
+	}
+	rest = strings.Trim(rest, `"'`)
+
+	return rest, rest != ""
+}
+
+// canonicalLink returns the rel=canonical URL of the page, as long as it's on
+// the same site. Otherwise any page could claim to be the canonical
+// version of some other site's URL and poison the cache for it.
+func canonicalLink(doc *goquery.Document, pageURL string) string {
+	href, ok := doc.Find(`link[rel="canonical"]`).First().Attr("href")
+	if !ok || strings.TrimSpace(href) == "" {
+		return ""
+	}
+
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	canonical, err := base.Parse(strings.TrimSpace(href))
+	if err != nil || (canonical.Scheme != "http" && canonical.Scheme != "https") {
+		return ""
+	}
+
+	if siteName(canonical.Hostname()) != siteName(base.Hostname()) {
+		log.Debugf("Ignoring canonical %s for %s, different site", canonical, pageURL)
+		return ""
+	}
+
+	return canonical.String()
+}
+
+// siteName strips the www. and m. prefixes from a host name
+func siteName(host string) string {
+	host = strings.ToLower(host)
+	for _, prefix := range []string{"www.", "m."} {
+		host = strings.TrimPrefix(host, prefix)
+	}
+	return host
+}
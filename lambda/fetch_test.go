@@ -0,0 +1,235 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFetchPage(t *testing.T) {
+	t.Parallel()
+
+	html := func(w http.ResponseWriter, body string) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, body)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/final", func(w http.ResponseWriter, r *http.Request) {
+		html(w, `<html><head><title>Final page</title><link rel="canonical" href="/canonical"></head><body></body></html>`)
+	})
+	mux.HandleFunc("/meta", func(w http.ResponseWriter, r *http.Request) {
+		html(w, `<html><head><title>Redirecting...</title><meta http-equiv="Refresh" content="0; URL='/final'"></head></html>`)
+	})
+	mux.HandleFunc("/js", func(w http.ResponseWriter, r *http.Request) {
+		html(w, `<html><head><title>Wait</title></head><body><script>window.location.href = "/final";</script>Redirecting</body></html>`)
+	})
+	mux.HandleFunc("/http", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/meta", http.StatusFound)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		html(w, `<html><head><meta http-equiv="refresh" content="0;url=/loop2"></head></html>`)
+	})
+	mux.HandleFunc("/loop2", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/file", func(w http.ResponseWriter, r *http.Request) {
+		html(w, `<html><head><meta http-equiv="refresh" content="0;url=file:///etc/passwd"></head></html>`)
+	})
+	mux.HandleFunc("/reload", func(w http.ResponseWriter, r *http.Request) {
+		html(w, `<html><head><title>News</title><meta http-equiv="refresh" content="300;url=/final"></head></html>`)
+	})
+	mux.HandleFunc("/app", func(w http.ResponseWriter, r *http.Request) {
+		html(w, `<html><head><meta property="og:title" content="App"></head><body><script>function go() { location.href = "/final"; }</script></body></html>`)
+	})
+	mux.HandleFunc("/foreign", func(w http.ResponseWriter, r *http.Request) {
+		html(w, `<html><head><title>Copy</title><link rel="canonical" href="https://example.com/original"></head></html>`)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name          string
+		path          string
+		wantURL       string
+		wantRedirects []string
+		wantCanonical string
+		wantErr       bool
+	}{
+		{"Meta refresh", "/meta", "/final", []string{"/final"}, "/canonical", false},
+		{"JavaScript", "/js", "/final", []string{"/final"}, "/canonical", false},
+		{"HTTP and meta refresh", "/http", "/final", []string{"/meta", "/final"}, "/canonical", false},
+		{"Loop", "/loop", "", nil, "", true},
+		{"Other scheme", "/file", "", nil, "", true},
+		{"Slow refresh", "/reload", "/reload", nil, "", false},
+		{"Real page", "/app", "/app", nil, "", false},
+		{"Canonical on other site", "/foreign", "/foreign", nil, "", false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			page, err := FetchPage(context.Background(), srv.URL+tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FetchPage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if page.URL != srv.URL+tt.wantURL {
+				t.Errorf("FetchPage() URL = %v, want %v", page.URL, srv.URL+tt.wantURL)
+			}
+			var redirects []string
+			for _, r := range page.Redirects {
+				redirects = append(redirects, strings.TrimPrefix(r, srv.URL))
+			}
+			if !reflect.DeepEqual(redirects, tt.wantRedirects) {
+				t.Errorf("FetchPage() redirects = %v, want %v", redirects, tt.wantRedirects)
+			}
+			if strings.TrimPrefix(page.Canonical, srv.URL) != tt.wantCanonical {
+				t.Errorf("FetchPage() canonical = %v, want %v", page.Canonical, tt.wantCanonical)
+			}
+		})
+	}
+}
+
+func TestCheckRedirect(t *testing.T) {
+	public, _ := url.Parse("https://bit.ly/abc")
+	internal, _ := url.Parse("http://127.0.0.1:8080/")
+
+	tests := []struct {
+		name    string
+		channel string
+		origin  *url.URL
+		target  string
+		wantErr bool
+	}{
+		{"Public to public", "", public, "https://example.com/article", false},
+		{"Public to loopback", "", public, "http://127.0.0.1/admin", true},
+		{"Public to metadata", "", public, "http://169.254.169.254/latest/meta-data/", true},
+		{"Public to private", "", public, "http://10.0.0.1/", true},
+		{"Public to localhost", "", public, "http://localhost:9000/", true},
+		{"Internal to internal", "", internal, "http://127.0.0.1:8080/next", false},
+		{"Other scheme", "", public, "ftp://example.com/file", true},
+		{"Denied domain", "", public, "https://denied.example/", true},
+		{"Silent domain", "", public, "https://twitter.com/someone", true},
+		{"Denied in the channel", "#strict", public, "https://example.com/article", true},
+		{"Allowed in other channels", "#other", public, "https://example.com/article", false},
+	}
+
+	c := DefaultConfig()
+	c.Policy.Denied = []string{"denied.example"}
+	c.Channels = map[string]ChannelConfig{"#strict": {Policy: Policy{Denied: []string{"example.com"}}}}
+	SetConfig(c)
+	t.Cleanup(func() { SetConfig(DefaultConfig()) })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := url.Parse(tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := checkRedirect(withPolicy(context.Background(), policyFor(tt.channel)), tt.origin, target); (err != nil) != tt.wantErr {
+				t.Errorf("checkRedirect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLinkedClient(t *testing.T) {
+	t.Parallel()
+
+	if _, err := linkedClient(context.Background(), "https://example.com/video", "http://169.254.169.254/oembed"); !errors.Is(err, ErrRedirectBlocked) {
+		t.Errorf("linkedClient() error = %v, want ErrRedirectBlocked", err)
+	}
+
+	client, err := linkedClient(context.Background(), "https://example.com/video", "https://example.com/oembed")
+	if err != nil {
+		t.Fatalf("linkedClient() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		target  string
+		via     int
+		wantErr error
+	}{
+		{"Public", "https://api.example.com/oembed", 0, nil},
+		{"Internal", "http://10.0.0.1/oembed", 0, ErrRedirectBlocked},
+		{"Silent domain", "https://twitter.com/oembed", 0, ErrRedirectBlocked},
+		{"Too many", "https://api.example.com/oembed", maxRedirects, ErrTooManyRedirects},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			err := client.CheckRedirect(req, make([]*http.Request, tt.via))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckRedirect() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseRefresh(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		content string
+		want    string
+		wantOK  bool
+	}{
+		{"0; url=https://example.com/", "https://example.com/", true},
+		{"0;URL='/next'", "/next", true},
+		{`1, url="/next"`, "/next", true},
+		{"0; /next", "/next", true},
+		{"300; url=/", "", false},
+		{"30", "", false},
+		{"soon; url=/next", "", false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.content, func(t *testing.T) {
+			t.Parallel()
+			got, ok := parseRefresh(tt.content)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("parseRefresh() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestCacheKeys(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		query TitleQuery
+		want  []string
+	}{
+		{"No canonical", TitleQuery{URL: "https://example.com/a#b"}, []string{"https://example.com/a"}},
+		{"Same canonical", TitleQuery{URL: "https://example.com/a", Canonical: "https://example.com/a"}, []string{"https://example.com/a"}},
+		{"Alias", TitleQuery{URL: "https://example.com/a?utm_source=x", Canonical: "https://example.com/a"}, []string{"https://example.com/a?utm_source=x", "https://example.com/a"}},
+		{"Languages", TitleQuery{URL: "https://example.com/a", Languages: []string{"fi", "en"}}, []string{"https://example.com/a#lang=fi,en"}},
+		{"Locale", TitleQuery{URL: "https://example.com/a", Locale: "fi"}, []string{"https://example.com/a#locale=fi"}},
+		{"English locale", TitleQuery{URL: "https://example.com/a", Locale: "en"}, []string{"https://example.com/a"}},
+		{"Languages and locale", TitleQuery{URL: "https://example.com/a", Languages: []string{"fi"}, Locale: "fi"}, []string{"https://example.com/a#lang=fi&locale=fi"}},
+		{"Languages with alias", TitleQuery{URL: "https://example.com/a?x=1", Canonical: "https://example.com/a", Languages: []string{"sv"}}, []string{"https://example.com/a?x=1#lang=sv", "https://example.com/a#lang=sv"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := cacheKeys(tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cacheKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
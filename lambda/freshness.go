@@ -0,0 +1,132 @@
+package lambda
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/lepinkainen/titleparser/locale"
+	log "github.com/sirupsen/logrus"
+)
+
+// Freshness configures the "old news" marker added to titles of old articles
+type Freshness struct {
+	// OldAfterDays marks articles older than this, 0 disables the marker
+	OldAfterDays int `yaml:"old_after_days" json:"old_after_days"`
+	// Marker is "year" for [2019] or "relative" for [published 4 years ago]
+	Marker string `yaml:"marker" json:"marker"`
+}
+
+// dateLayouts are the formats commonly seen in meta tags and JSON-LD
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05.000Z07:00",
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// ParseDate parses the date formats used in page metadata
+func ParseDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	var err error
+	for _, layout := range dateLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, err
+}
+
+// Published returns the publication date of the page from its metadata.
+// Sources in order of preference: article:published_time, og:video:release_date,
+// JSON-LD datePublished/uploadDate, <time> elements and og:updated_time.
+func Published(doc *goquery.Document) (time.Time, bool) {
+	var candidates []string
+
+	meta := func(property string) {
+		if value, ok := doc.Find(fmt.Sprintf(`meta[property=%q]`, property)).First().Attr("content"); ok {
+			candidates = append(candidates, value)
+		}
+	}
+
+	meta("article:published_time")
+	meta("og:video:release_date")
+	candidates = append(candidates, jsonLDDates(doc)...)
+
+	// Only <time> elements that are about the article itself, product pages
+	// have plenty of them for reviews and such
+	doc.Find(`time[itemprop="datePublished"], time[pubdate], article time[datetime]`).Each(func(_ int, s *goquery.Selection) {
+		if value, ok := s.Attr("datetime"); ok {
+			candidates = append(candidates, value)
+		}
+	})
+
+	meta("og:updated_time")
+
+	for _, candidate := range candidates {
+		if t, err := ParseDate(candidate); err == nil {
+			return t, true
+		}
+		log.Debugf("Could not parse date %q", candidate)
+	}
+
+	return time.Time{}, false
+}
+
+// jsonLDDates returns datePublished and uploadDate values from JSON-LD blocks
+func jsonLDDates(doc *goquery.Document) []string {
+	var dates []string
+
+	var walk func(data any)
+	walk = func(data any) {
+		switch v := data.(type) {
+		case []any:
+			for _, item := range v {
+				walk(item)
+			}
+		case map[string]any:
+			for _, key := range []string{"datePublished", "uploadDate"} {
+				if date, ok := v[key].(string); ok {
+					dates = append(dates, date)
+				}
+			}
+			if graph, ok := v["@graph"]; ok {
+				walk(graph)
+			}
+		}
+	}
+
+	doc.Find(`script[type="application/ld+json"]`).Each(func(_ int, s *goquery.Selection) {
+		var data any
+		if err := json.Unmarshal([]byte(s.Text()), &data); err == nil {
+			walk(data)
+		}
+	})
+
+	return dates
+}
+
+// AgeMarker returns the "old news" marker for something published at the
+// given time, or an empty string if it's recent enough
+func AgeMarker(loc *locale.Locale, published, now time.Time) string {
+	freshness := activeConfig.Freshness
+	if freshness.OldAfterDays <= 0 {
+		return ""
+	}
+
+	if now.Sub(published) < time.Duration(freshness.OldAfterDays)*24*time.Hour {
+		return ""
+	}
+
+	if freshness.Marker == "relative" {
+		return fmt.Sprintf("[%s]", loc.Message("published", loc.RelTime(published, now)))
+	}
+	return fmt.Sprintf("[%d]", published.Year())
+}
@@ -0,0 +1,117 @@
+package lambda
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/lepinkainen/titleparser/locale"
+)
+
+func TestPublished(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		html   string
+		want   string
+		wantOK bool
+	}{
+		{"article:published_time", "", "2018-11-20T06:12:43+02:00", true},
+		{"JSON-LD graph", "", "2019-03-01T10:00:00Z", true},
+		{"Areena release date", `<meta property="og:video:release_date" content="2021-02-10T06:00:00.000+02:00">`, "2021-02-10T06:00:00+02:00", true},
+		{"Time element", `<article><h1>Post</h1><time datetime="2020-05-04">May 4th</time></article>`, "2020-05-04T00:00:00Z", true},
+		{"Unrelated time element", `<div class="review"><time datetime="2020-05-04">May 4th</time></div>`, "", false},
+		{"Updated time as last resort", `<meta property="og:updated_time" content="2017-01-02T03:04:05Z">`, "2017-01-02T03:04:05Z", true},
+		{"Garbage date", `<meta property="article:published_time" content="yesterday">`, "", false},
+		{"Nothing", `<title>x</title>`, "", false},
+	}
+	fixtures := map[string]string{
+		"article:published_time": "article.html",
+		"JSON-LD graph":          "jsonld-article.html",
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var doc *goquery.Document
+			if fixture, ok := fixtures[tt.name]; ok {
+				doc = loadFixture(t, fixture)
+			} else {
+				var err error
+				doc, err = goquery.NewDocumentFromReader(strings.NewReader(tt.html))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, ok := Published(doc)
+			if ok != tt.wantOK {
+				t.Fatalf("Published() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && got.Format(time.RFC3339) != tt.want {
+				t.Errorf("Published() = %v, want %v", got.Format(time.RFC3339), tt.want)
+			}
+		})
+	}
+}
+
+func TestAgeMarker(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	old := time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC)
+	recent := now.Add(-24 * time.Hour)
+
+	tests := []struct {
+		name      string
+		freshness Freshness
+		published time.Time
+		want      string
+	}{
+		{"Year marker", Freshness{OldAfterDays: 365, Marker: "year"}, old, "[2019]"},
+		{"Relative marker", Freshness{OldAfterDays: 365, Marker: "relative"}, old, "[published 4 years ago]"},
+		{"Recent", Freshness{OldAfterDays: 365, Marker: "year"}, recent, ""},
+		{"Short threshold", Freshness{OldAfterDays: 1, Marker: "year"}, recent, "[2023]"},
+		{"Disabled", Freshness{}, old, ""},
+	}
+	t.Cleanup(func() { SetConfig(DefaultConfig()) })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := DefaultConfig()
+			c.Freshness = tt.freshness
+			SetConfig(c)
+			if got := AgeMarker(locale.English, tt.published, now); got != tt.want {
+				t.Errorf("AgeMarker() = '%v', want '%v'", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"2018-12-25T06:00:00.000+02:00", "2018-12-25T06:00:00+02:00", false},
+		{"2018-12-25T06:00:00Z", "2018-12-25T06:00:00Z", false},
+		{"2018-12-25T06:00:00+0200", "2018-12-25T06:00:00+02:00", false},
+		{" 2018-12-25 ", "2018-12-25T00:00:00Z", false},
+		{"25.12.2018", "", true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.value, func(t *testing.T) {
+			t.Parallel()
+			got, err := ParseDate(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseDate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && got.Format(time.RFC3339) != tt.want {
+				t.Errorf("ParseDate() = %v, want %v", got.Format(time.RFC3339), tt.want)
+			}
+		})
+	}
+}
@@ -2,18 +2,18 @@ package lambda
 
 import (
 	"context"
+	"fmt"
 	"os"
-	"regexp"
 	"time"
 
+	"github.com/lepinkainen/titleparser/common"
+	"github.com/lepinkainen/titleparser/locale"
+	"github.com/pkg/errors"
+	"github.com/rivo/uniseg"
 	log "github.com/sirupsen/logrus"
 	//"github.com/lepinkainen/titleparser/handler"
 )
 
-var (
-	handlerFunctions = make(map[string]func(string) (string, error))
-)
-
 // TitleQuery received via HTTP(s)
 type TitleQuery struct {
 	Added   int64  `json:"timestamp" dynamodbav:"timestamp"`
@@ -22,67 +22,285 @@ type TitleQuery struct {
 	URL     string `json:"url" dynamodbav:"url"`
 	Title   string `json:"title" dynamodbav:"title"`
 	TTL     int64  `json:"ttl" dynamodbav:"ttl"` // TTL is used to expire the item in DynamoDB automatically
+	// ReadingTime is the estimated reading time of an article in minutes
+	ReadingTime int `json:"reading_time,omitempty" dynamodbav:"reading_time,omitempty"`
+	// BaseTitle is the title before the per-request additions of decorate
+	BaseTitle string `json:"-" dynamodbav:"-"`
+	// Section is the heading the URL fragment points to
+	Section string `json:"section,omitempty" dynamodbav:"-"`
+	// Sections maps the fragments of the page to their headings, only cached
+	Sections map[string]string `json:"-" dynamodbav:"sections,omitempty"`
+	// Canonical is the rel=canonical URL of the page, the result is cached for it too
+	Canonical string `json:"canonical,omitempty" dynamodbav:"canonical,omitempty"`
+	// FinalURL is where the URL ended up after all redirects
+	FinalURL string `json:"final_url,omitempty" dynamodbav:"final_url,omitempty"`
+	// RedirectChain lists every URL redirected to, in order
+	RedirectChain []string `json:"redirect_chain,omitempty" dynamodbav:"redirect_chain,omitempty"`
+	// Languages are the preferred languages for the title, most preferred first
+	Languages []string `json:"languages,omitempty" dynamodbav:"languages,omitempty"`
+	// Locale is the language counts, relative times and labels are written in
+	Locale string `json:"locale,omitempty" dynamodbav:"locale,omitempty"`
+	// Explain asks for an explanation of how the title was found
+	Explain bool `json:"explain,omitempty" dynamodbav:"-"`
+	// Explanation is set when the request asked for it
+	Explanation *Explanation `json:"explanation,omitempty" dynamodbav:"-"`
 }
 
-type handlerFunc func(string) (string, error)
+// HandleRequest is the function entry point
+func HandleRequest(ctx context.Context, query TitleQuery) (TitleQuery, error) {
+	log.Infof("Handling %v", query)
+	if !query.Explain {
+		return handle(ctx, query)
+	}
 
-// RegisterHandler adds the given url parser and pattern to the map of handlers
-func RegisterHandler(pattern string, function handlerFunc) {
-	handlerFunctions[pattern] = function
+	explanation := &Explanation{}
+	start := time.Now()
+	res, err := handle(withExplanation(ctx, explanation), query)
+	explanation.timed("total", start)
+	res.Explanation = explanation
+	return res, err
 }
 
-// func registerParser(pattern string, function handlerFunc) {
-// 	handlerFunctions[pattern] = function
-// }
+// handle runs a single request from policy checks to the finished title
+func handle(ctx context.Context, query TitleQuery) (TitleQuery, error) {
+	ctx, query, done, err := prepare(ctx, query)
+	if done || err != nil {
+		return query, err
+	}
 
-// HandleRequest is the function entry point
-func HandleRequest(ctx context.Context, query TitleQuery) (TitleQuery, error) {
+	res, err := resolve(ctx, query)
+	if err != nil {
+		return res, err
+	}
 
-	// open session to dynamoDB
+	defer explanationFrom(ctx).timed("decorate", time.Now())
+	return decorate(ctx, res), nil
+}
 
-	log.Infof("Handling %v", query)
+// prepare checks the domain policy and picks the languages and locale for
+// the query. done is set if there's nothing to fetch.
+func prepare(ctx context.Context, query TitleQuery) (context.Context, TitleQuery, bool, error) {
+	explanation := explanationFrom(ctx)
+	defer explanation.timed("policy", time.Now())
+
+	// Domain policy is checked before anything gets fetched, cache included.
+	// Redirects are checked against the same policy.
+	policy := policyFor(query.Channel)
+	ctx = withPolicy(ctx, policy)
+	silent, err := policy.Check(query.URL)
+	if err != nil {
+		log.Infof("Policy denied %s: %v", query.URL, err)
+		explanation.decide("denied by the domain policy: %v", err)
+		query.Title = ""
+		return ctx, query, true, err
+	}
+	if silent {
+		log.Infof("Silent domain, not fetching %s", query.URL)
+		explanation.decide("silent domain, not fetched")
+		query.Title = ""
+		return ctx, query, true, nil
+	}
+
+	// Sites that have the content in multiple languages pick one of these
+	query.Languages = languagesFor(query)
+	ctx = common.WithLanguages(ctx, query.Languages)
+	loc := localeFor(query)
+	query.Locale = loc.Tag
+	ctx = locale.With(ctx, loc)
+
+	return ctx, query, false, nil
+}
+
+// decorate adds the parts of the title that aren't cached. The title is
+// shortened to keep the result within TitleMax, the additions are kept whole.
+func decorate(ctx context.Context, res TitleQuery) TitleQuery {
+	res.BaseTitle = res.Title
+	if res.Title == "" {
+		return res
+	}
+
+	// Per-channel additions, not part of the cached title
+	var tail string
+	if res.ReadingTime > 0 && channelConfig(res.Channel).ReadingTime {
+		tail = fmt.Sprintf(" [%s]", locale.From(ctx).Count("readingtime", res.ReadingTime))
+	}
+	// Readers want to know where a link goes before clicking it
+	tail += destinationMarker(res.URL, res.FinalURL)
+	budget := TitleMax - uniseg.GraphemeClusterCount(tail)
+
+	// The cached title is for the whole page, fragments add the section name.
+	// Headings can be long too, the section gets at most half of the space.
+	var section string
+	if fragment := Fragment(res.URL); fragment != "" {
+		if heading := res.Sections[fragment]; heading != "" && heading != res.Title {
+			res.Section = heading
+			section = " § " + common.Truncate(heading, budget/2)
+		}
+	}
+
+	res.Title = common.Truncate(res.Title, budget-uniseg.GraphemeClusterCount(section)) + section + tail
+	return res
+}
+
+// resolve gets the title from cache or by running the matching handler
+func resolve(ctx context.Context, query TitleQuery) (TitleQuery, error) {
+	explanation := explanationFrom(ctx)
 
 	// If we are running locally, don't use dynamodb as a cache
 	// TODO: Possibly add an in-memory DB or sqlite for local mode caching?
-	var runmode = os.Getenv("RUNMODE")
-	if runmode != "local" {
-		// if query is cached, return from cache instead of fetching
-		if title, err := CheckCache(query); err == nil {
-			return CacheAndReturn(query, title, nil)
+	if !CacheEnabled(ctx) {
+		explanation.Cache = CacheDisabled
+		query, title, err := fetch(ctx, query)
+		log.Infoln("Local mode, not caching result")
+		return stamp(query, title), err
+	}
+
+	// if query is cached, return from cache instead of fetching
+	start := time.Now()
+	cached, err := lookupCache(query)
+	explanation.timed("cache read", start)
+	if err == nil {
+		explanation.Cache = CacheHit
+		explanation.CacheAge = time.Now().Unix() - cached.Added
+		if silent, err := policyFrom(ctx).CheckChain(cached.RedirectChain); silent || err != nil {
+			explanation.decide("cached result redirected to a domain the policy doesn't allow")
+			query.Title = ""
+			return query, err
 		}
+		defer explanation.timed("cache write", time.Now())
+		return CacheAndReturn(fromCache(query, cached), cached.Title, nil)
 	}
+	explanation.Cache = CacheMiss
 
-	for pattern, handler := range handlerFunctions {
-		match, err := regexp.MatchString(pattern, query.URL)
+	query, title, err := fetch(ctx, query)
+	defer explanation.timed("cache write", time.Now())
+	return CacheAndReturn(query, title, err)
+}
+
+// CacheEnabled is false when running locally, there's no DynamoDB to use,
+// or when the request asks not to use the cache
+func CacheEnabled(ctx context.Context) bool {
+	return os.Getenv("RUNMODE") != "local" && !optionsFrom(ctx).NoCache
+}
+
+// fetch runs the handler for the query and collects the page details
+func fetch(ctx context.Context, query TitleQuery) (TitleQuery, string, error) {
+	explanation := explanationFrom(ctx)
+	defer explanation.timed("fetch", time.Now())
+
+	ctx, details := withDetails(ctx)
+	title, err := dispatch(ctx, query.URL)
+	if err == nil && explanation.Source == "" {
+		// Site specific handlers use APIs or their own selectors
+		explanation.Source = "handler"
+	}
+	if explanation.Handler != "" && explanation.Status == 0 {
+		explanation.decide("%s didn't report an HTTP status or content type", explanation.Handler)
+	}
+	// Every handler's output ends up in chat, nothing gets through uncleaned
+	title = sanitize(title)
+	query.ReadingTime = details.ReadingTime
+	query.Sections = details.Sections
+	query.Canonical = details.Canonical
+	query.FinalURL = details.FinalURL
+	query.RedirectChain = details.Redirects
+	if query.FinalURL == "" && len(query.RedirectChain) > 0 {
+		// A handler was picked for the expanded URL
+		query.FinalURL = query.RedirectChain[len(query.RedirectChain)-1]
+	}
+
+	return query, title, err
+}
+
+// fromCache copies the page details from a cached item to the query
+func fromCache(query, cached TitleQuery) TitleQuery {
+	query.ReadingTime = cached.ReadingTime
+	query.Sections = cached.Sections
+	query.Canonical = cached.Canonical
+	query.FinalURL = cached.FinalURL
+	query.RedirectChain = cached.RedirectChain
+	return query
+}
+
+// stamp sets the title and its cache lifetime
+func stamp(query TitleQuery, title string) TitleQuery {
+	query.Title = title
+	query.Added = time.Now().Unix()
+	query.TTL = time.Now().Unix() + 86400 // 24 hours
+	return query
+}
+
+// dispatch runs the handler matching the url, or the default handler if none match
+func dispatch(ctx context.Context, url string) (string, error) {
+	explanation := explanationFrom(ctx)
 
-		// error in matching, log and continue to next pattern
+	// Shortened URLs are expanded first, so the destination gets the right handler
+	if isShortener(url) {
+		expanded, redirects, err := ExpandURL(ctx, url)
 		if err != nil {
-			log.Errorf("Error matching with pattern %s: %v", pattern, err)
-			continue
+			log.Warnf("Could not expand %s: %v", url, err)
+			explanation.decide("could not expand the shortened URL, using it as is: %v", err)
+		} else {
+			detailsFromContext(ctx).Redirects = redirects
+			explanation.decide("shortened URL expanded to %s", expanded)
+			url = expanded
 		}
+	}
 
-		// no error and match, run function to get actual title and return
-		if err == nil && match {
-			log.Infof("Handler match found for %s\n", query.URL)
-			title, err := handler(query.URL)
-			if runmode != "local" {
-				return CacheAndReturn(query, title, err)
-			}
-			log.Infoln("Local mode, not caching result")
-
-			query.Title = title
-			query.Added = time.Now().Unix()
-			query.TTL = time.Now().Unix() + 86400 // 24 hours
+	if name := optionsFrom(ctx).Handler; name != "" {
+		handler, ok := handlerNamed(name)
+		if !ok {
+			return "", errors.Errorf("no handler named %q", name)
+		}
+		if disabled := handlerDisabled(name); disabled != "" {
+			return "", errors.Errorf("handler %s is disabled: %s", name, disabled)
+		}
+		log.Infof("Using handler %s for %s", name, url)
+		explanation.Handler = name
+		explanation.decide("handler %s forced by the request", name)
+		return handler(ctx, url)
+	}
 
-			return query, err
+	if reg, ok := matchHandler(url); ok {
+		log.Infof("Handler %s matched %s", reg.name, url)
+		explanation.Handler = reg.name
+		explanation.Pattern = reg.pattern
+		for _, other := range Match(url) {
+			switch {
+			case other.Pattern == reg.pattern:
+			case other.Disabled != "":
+				explanation.decide("%s matched with %s but is disabled: %s", other.Name, other.Pattern, other.Disabled)
+			default:
+				explanation.decide("%s also matched with %s, the first match is used", other.Name, other.Pattern)
+			}
 		}
+		return reg.handler(ctx, url)
 	}
 
-	log.Infof("No handler found for %s, falling back to default", query.URL)
+	log.Infof("No handler found for %s, falling back to default", url)
+	for _, other := range Match(url) {
+		explanation.decide("%s matched with %s but is disabled: %s", other.Name, other.Pattern, other.Disabled)
+	}
+	explanation.Handler = DefaultHandlerName
+	explanation.decide("no handler pattern matched, using the default handler")
 
 	// custom parsers didn't match, use the default parser
-	title, err := DefaultHandler(query.URL)
-	return CacheAndReturn(query, title, err)
+	return DefaultHandler(ctx, url)
+}
+
+// languagesFor returns the languages asked for in the query, or the channel's defaults
+func languagesFor(query TitleQuery) []string {
+	if languages := common.NormalizeLanguages(query.Languages); len(languages) > 0 {
+		return languages
+	}
+	return common.NormalizeLanguages(channelConfig(query.Channel).Languages)
+}
+
+// localeFor picks the output locale: the one asked for in the query, the
+// channel's locale, or the first preferred language with a catalog
+func localeFor(query TitleQuery) *locale.Locale {
+	candidates := []string{query.Locale, channelConfig(query.Channel).Locale}
+	return locale.Select(append(candidates, query.Languages...)...)
 }
 
 func init() {
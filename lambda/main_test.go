@@ -0,0 +1,45 @@
+package lambda
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleRequestLanguages(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, "<html><head><title>%s</title></head></html>", r.Header.Get("Accept-Language"))
+	}))
+	defer srv.Close()
+
+	t.Setenv("RUNMODE", "local")
+	c := DefaultConfig()
+	c.Channels = map[string]ChannelConfig{"#suomi": {Languages: []string{"fi", "en"}}}
+	SetConfig(c)
+	t.Cleanup(func() { SetConfig(DefaultConfig()) })
+
+	tests := []struct {
+		name  string
+		query TitleQuery
+		want  string
+	}{
+		{"No preference", TitleQuery{Channel: "#other"}, "*"},
+		{"Channel default", TitleQuery{Channel: "#suomi"}, "fi, en;q=0.9, *;q=0.1"},
+		{"Request overrides channel", TitleQuery{Channel: "#suomi", Languages: []string{"SV"}}, "sv, *;q=0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.query.URL = srv.URL + "/page"
+			got, err := HandleRequest(context.Background(), tt.query)
+			if err != nil {
+				t.Fatalf("HandleRequest() error = %v", err)
+			}
+			if got.Title != tt.want {
+				t.Errorf("HandleRequest() title = '%v', want '%v'", got.Title, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package lambda
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/lepinkainen/titleparser/locale"
+	log "github.com/sirupsen/logrus"
+)
+
+/*
+   OpenGraph video and music tags, as used by Yle Areena, Ruutu, MTV Katsomo,
+   news video pages, podcast hosts etc.
+
+   <meta property="og:video:duration" content="7920">
+   <meta property="og:video:release_date" content="2021-02-10T06:00:00.000+02:00">
+   <meta property="video:series" content="Pikku Kakkonen">
+   <meta property="music:duration" content="242">
+   <meta property="music:musician_description" content="Queen">
+*/
+
+// Media is the video or audio metadata of a page
+type Media struct {
+	Duration time.Duration
+	Released time.Time
+	Series   string
+	Episode  string
+	Artist   string
+}
+
+// ExtractMedia reads OpenGraph video and music metadata, nil if the page has none
+func ExtractMedia(doc *goquery.Document) *Media {
+	meta := func(properties ...string) string {
+		for _, property := range properties {
+			if value, ok := doc.Find(fmt.Sprintf(`meta[property=%q]`, property)).First().Attr("content"); ok {
+				if value = strings.TrimSpace(value); value != "" {
+					return value
+				}
+			}
+		}
+		return ""
+	}
+
+	m := &Media{
+		Series:  meta("video:series", "og:video:series"),
+		Episode: meta("video:episode", "og:video:episode"),
+		// music:musician is supposed to be a profile URL, the description has the name
+		Artist: meta("music:musician_description", "music:creator"),
+	}
+	if m.Artist == "" {
+		if musician := meta("music:musician"); !isURL(musician) {
+			m.Artist = musician
+		}
+	}
+	if isURL(m.Series) {
+		m.Series = ""
+	}
+
+	if duration := meta("og:video:duration", "video:duration", "og:audio:duration", "music:duration"); duration != "" {
+		seconds, err := strconv.ParseFloat(duration, 64)
+		if err != nil {
+			log.Debugf("Could not parse duration %q: %v", duration, err)
+		} else {
+			m.Duration = time.Duration(seconds) * time.Second
+		}
+	}
+
+	if released := meta("og:video:release_date", "video:release_date", "music:release_date"); released != "" {
+		t, err := ParseDate(released)
+		if err != nil {
+			log.Debugf("Could not parse release date %q: %v", released, err)
+		} else {
+			m.Released = t
+		}
+	}
+
+	if m.Duration == 0 && m.Released.IsZero() && m.Series == "" && m.Episode == "" && m.Artist == "" {
+		return nil
+	}
+
+	return m
+}
+
+// Format adds the media information to the title:
+// "Title by Artist [Series: Name, Episode 3] [Duration: 2h12m0s - Released: 3 years ago]"
+func (m *Media) Format(loc *locale.Locale, title string) string {
+	if m.Artist != "" && !strings.Contains(title, m.Artist) {
+		title = loc.Message("by", title, m.Artist)
+	}
+
+	var series []string
+	if m.Series != "" && !strings.Contains(title, m.Series) {
+		series = append(series, loc.Message("series", m.Series))
+	}
+	if m.Episode != "" {
+		series = append(series, loc.Message("episode", m.Episode))
+	}
+	if len(series) > 0 {
+		title = fmt.Sprintf("%s [%s]", title, strings.Join(series, ", "))
+	}
+
+	var details []string
+	if m.Duration > 0 {
+		details = append(details, loc.Message("duration", loc.Duration(m.Duration)))
+	}
+	if !m.Released.IsZero() {
+		details = append(details, loc.Message("released", loc.RelTime(m.Released, time.Now())))
+	}
+	if len(details) > 0 {
+		title = fmt.Sprintf("%s [%s]", title, strings.Join(details, " - "))
+	}
+
+	return title
+}
+
+// isURL checks if an OpenGraph value is a link instead of a name
+func isURL(value string) bool {
+	return strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://")
+}
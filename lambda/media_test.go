@@ -0,0 +1,53 @@
+package lambda
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/lepinkainen/titleparser/locale"
+)
+
+func TestExtractMedia(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		locale  *locale.Locale
+		fixture string
+		want    string
+	}{
+		{"Areena movie", locale.English, "areena.html", `^Muiden elämä \[Duration: 2h 12min - Released: \d+ years? ago\]$`},
+		{"Series episode", locale.English, "episode.html", `^Jakso 3: Paluu \[Series: Salatut elämät, Episode 3\] \[Duration: 21min 30s\]$`},
+		{"Song", locale.English, "music.html", `^Bohemian Rhapsody - Remastered 2011 by Queen \[Duration: 5min 54s - Released: \d+ years ago\]$`},
+		{"Finnish", locale.Finnish, "episode.html", `^Jakso 3: Paluu \[Sarja: Salatut elämät, Jakso 3\] \[Kesto: 21 min 30 s\]$`},
+		{"Finnish song", locale.Finnish, "music.html", `^Bohemian Rhapsody - Remastered 2011 – Queen \[Kesto: 5 min 54 s - Julkaistu: \d+ vuotta sitten\]$`},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			doc := loadFixture(t, tt.fixture)
+			title, err := TitleFromDocument(doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			media := ExtractMedia(doc)
+			if media == nil {
+				t.Fatal("ExtractMedia() = nil")
+			}
+			if got := media.Format(tt.locale, title); !regexp.MustCompile(tt.want).MatchString(got) {
+				t.Errorf("Format() = '%v', want match '%v'", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractMediaNone(t *testing.T) {
+	t.Parallel()
+
+	for _, fixture := range []string{"article.html", "verkkokauppa.html"} {
+		if media := ExtractMedia(loadFixture(t, fixture)); media != nil {
+			t.Errorf("ExtractMedia(%s) = %+v, want nil", fixture, media)
+		}
+	}
+}
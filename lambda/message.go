@@ -0,0 +1,78 @@
+package lambda
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"github.com/lepinkainen/titleparser/common"
+)
+
+// MessageQuery is a raw chat message, every URL in it gets a title
+type MessageQuery struct {
+	User      string   `json:"user"`
+	Channel   string   `json:"channel"`
+	Message   string   `json:"message"`
+	Languages []string `json:"languages,omitempty"`
+	Locale    string   `json:"locale,omitempty"`
+	// Concurrency limits parallel fetches, DefaultBatchConcurrency if not set
+	Concurrency int `json:"concurrency,omitempty"`
+}
+
+// Batch returns a batch of the unique URLs in the message
+func (m MessageQuery) Batch() BatchQuery {
+	return BatchQuery{
+		User:        m.User,
+		Channel:     m.Channel,
+		URLs:        common.ExtractURLs(m.Message),
+		Languages:   m.Languages,
+		Locale:      m.Locale,
+		Concurrency: m.Concurrency,
+	}
+}
+
+// HandleMessage extracts the URLs from the message and resolves them as a batch
+func HandleMessage(ctx context.Context, message MessageQuery) BatchResult {
+	batch := message.Batch()
+	if len(batch.URLs) == 0 {
+		return BatchResult{Results: []BatchItem{}}
+	}
+	return MarkRedundant(message.Message, HandleBatch(ctx, batch))
+}
+
+// MarkRedundant clears titles the message already says, e.g. when someone
+// pastes the headline with the link. The title is left out like for silent
+// domains, Redundant tells why. The title is compared without the section,
+// reading time and destination added to it.
+func MarkRedundant(message string, res BatchResult) BatchResult {
+	// the links themselves would match titles that are in the slug, they're
+	// removed as written, schemeless links are normalized in the results
+	text := message
+	for _, link := range common.ExtractLinks(message) {
+		text = strings.Replace(text, link.Text, " ", 1)
+	}
+	text = " " + titleWords(text) + " "
+
+	for i, item := range res.Results {
+		if item.Title == "" || item.Error != "" {
+			continue
+		}
+		title := item.BaseTitle
+		if title == "" {
+			title = item.Title
+		}
+		if words := titleWords(title); words != "" && strings.Contains(text, " "+words+" ") {
+			res.Results[i].Title = ""
+			res.Results[i].Redundant = true
+		}
+	}
+	return res
+}
+
+// titleWords lowercases the text and keeps only the words, so punctuation
+// and formatting differences don't matter
+func titleWords(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}), " ")
+}
@@ -0,0 +1,69 @@
+package lambda
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMessageBatch(t *testing.T) {
+	t.Parallel()
+
+	message := MessageQuery{
+		User:    "nick",
+		Channel: "#test",
+		Message: "<https://example.com/a> vs (https://example.com/b), also https://example.com/a",
+		Locale:  "fi",
+	}
+	want := BatchQuery{
+		User:    "nick",
+		Channel: "#test",
+		URLs:    []string{"https://example.com/a", "https://example.com/b"},
+		Locale:  "fi",
+	}
+	if got := message.Batch(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Batch() = %+v, want %+v", got, want)
+	}
+}
+
+func TestMarkRedundant(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		message       string
+		title         string
+		baseTitle     string
+		err           string
+		wantRedundant bool
+	}{
+		{"Headline pasted", "Jätteiden mukana palaa metalleja! https://yle.fi/a/1", "Jätteiden mukana palaa metalleja", "", "", true},
+		{"Different case and punctuation", "\"new GO release: 1.26\" https://go.dev/", "New Go release - 1.26", "", "", true},
+		{"Not in message", "look at this https://yle.fi/a/1", "Jätteiden mukana palaa metalleja", "", "", false},
+		{"Partial word", "gopher https://go.dev/", "Go", "", "", false},
+		{"Only in the URL", "https://example.com/hello-world", "Hello world", "", "", false},
+		{"Only in a schemeless URL", "www.example.com/hello-world", "Hello world", "", "", false},
+		{"Decorated title", "Jätteiden mukana palaa metalleja https://yle.fi/a/1", "Jätteiden mukana palaa metalleja [5 min read]", "Jätteiden mukana palaa metalleja", "", true},
+		{"Failed lookup", "404 Not Found https://example.com/", "", "", "404 Not Found", false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			url := MessageQuery{Message: tt.message}.Batch().URLs[0]
+			res := MarkRedundant(tt.message, BatchResult{Results: []BatchItem{
+				{TitleQuery: TitleQuery{URL: url, Title: tt.title, BaseTitle: tt.baseTitle}, Error: tt.err},
+			}})
+
+			got := res.Results[0]
+			if got.Redundant != tt.wantRedundant {
+				t.Errorf("Redundant = %v, want %v", got.Redundant, tt.wantRedundant)
+			}
+			if got.Redundant && got.Title != "" {
+				t.Errorf("redundant title kept: %q", got.Title)
+			}
+			if !got.Redundant && got.Title != tt.title {
+				t.Errorf("Title = %q, want %q", got.Title, tt.title)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package lambda
+
+import "context"
+
+// Options change how a request is handled, for the command line and debugging
+type Options struct {
+	// Handler runs the named handler instead of the one matching the URL,
+	// DefaultHandlerName for the default handler
+	Handler string
+	// NoCache neither reads nor writes the cache
+	NoCache bool
+}
+
+type optionsKey struct{}
+
+// WithOptions sets the options for requests handled with the context
+func WithOptions(ctx context.Context, options Options) context.Context {
+	return context.WithValue(ctx, optionsKey{}, options)
+}
+
+// optionsFrom returns the options of the request, the zero value if none were set
+func optionsFrom(ctx context.Context) Options {
+	options, _ := ctx.Value(optionsKey{}).(Options)
+	return options
+}
@@ -0,0 +1,100 @@
+package lambda
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrDenied is returned for URLs the domain policy doesn't allow fetching
+var ErrDenied = errors.New("URL denied by policy")
+
+// Policy decides which domains we fetch at all, checked before any handler runs
+// Domains match themselves and all their subdomains
+type Policy struct {
+	// Silent domains get an empty title without fetching anything
+	Silent []string `yaml:"silent" json:"silent"`
+	// Denied domains return ErrDenied, for intranet hosts, malware domains etc.
+	Denied []string `yaml:"denied" json:"denied"`
+	// AllowedOnly restricts fetching to these domains when not empty
+	AllowedOnly []string `yaml:"allowed_only" json:"allowed_only"`
+}
+
+// policyFor returns the effective policy for a channel.
+// Channel silent and denied lists are added to the global ones,
+// a channel allowed-only list replaces the global one.
+func policyFor(channel string) Policy {
+	global := activeConfig.Policy
+	override := channelConfig(channel).Policy
+
+	p := Policy{
+		Silent:      append(append([]string{}, global.Silent...), override.Silent...),
+		Denied:      append(append([]string{}, global.Denied...), override.Denied...),
+		AllowedOnly: global.AllowedOnly,
+	}
+	if len(override.AllowedOnly) > 0 {
+		p.AllowedOnly = override.AllowedOnly
+	}
+
+	return p
+}
+
+type policyKey struct{}
+
+// withPolicy sets the policy every URL of the request is checked against, redirects included
+func withPolicy(ctx context.Context, p Policy) context.Context {
+	return context.WithValue(ctx, policyKey{}, p)
+}
+
+// policyFrom returns the policy of the request, the global one outside of requests
+func policyFrom(ctx context.Context) Policy {
+	if p, ok := ctx.Value(policyKey{}).(Policy); ok {
+		return p
+	}
+	return policyFor("")
+}
+
+// CheckChain checks every URL a cached result was redirected to, the cache is
+// shared by channels with different policies
+func (p Policy) CheckChain(redirects []string) (bool, error) {
+	for _, redirect := range redirects {
+		if silent, err := p.Check(redirect); silent || err != nil {
+			return silent, err
+		}
+	}
+	return false, nil
+}
+
+// Check returns true if the URL should get an empty title without fetching,
+// or ErrDenied if it shouldn't be fetched at all
+func (p Policy) Check(rawurl string) (bool, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil || u.Hostname() == "" {
+		// Not for the policy to decide, the handlers will complain
+		return false, nil
+	}
+	host := strings.ToLower(u.Hostname())
+
+	if matchesDomain(host, p.Denied) {
+		return false, errors.Wrap(ErrDenied, host)
+	}
+
+	if len(p.AllowedOnly) > 0 && !matchesDomain(host, p.AllowedOnly) {
+		return false, errors.Wrapf(ErrDenied, "%s not in allowed domains", host)
+	}
+
+	return matchesDomain(host, p.Silent), nil
+}
+
+// matchesDomain checks if host is one of the domains or their subdomain
+func matchesDomain(host string, domains []string) bool {
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	t.Parallel()
+
+	policy := Policy{
+		Silent: []string{"apina.biz", "pr0gramm.com"},
+		Denied: []string{"intranet.local", "evil.example"},
+	}
+	allowed := Policy{AllowedOnly: []string{"yle.fi"}}
+
+	tests := []struct {
+		name       string
+		policy     Policy
+		url        string
+		wantSilent bool
+		wantErr    bool
+	}{
+		{"Silent domain", policy, "https://pr0gramm.com/top/3974894", true, false},
+		{"Silent subdomain", policy, "http://www.apina.biz/123.jpg", true, false},
+		{"Denied domain", policy, "http://wiki.intranet.local/page", false, true},
+		{"Denied case insensitive", policy, "http://EVIL.example/", false, true},
+		{"Lookalike domain", policy, "https://notapina.biz/", false, false},
+		{"Other domain", policy, "https://example.com/", false, false},
+		{"Allowed only match", allowed, "https://areena.yle.fi/1-4192173", false, false},
+		{"Allowed only miss", allowed, "https://example.com/", false, true},
+		{"Not a URL", policy, "apina.biz", false, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			silent, err := tt.policy.Check(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil && !errors.Is(err, ErrDenied) {
+				t.Errorf("Check() error = %v, want ErrDenied", err)
+			}
+			if silent != tt.wantSilent {
+				t.Errorf("Check() silent = %v, want %v", silent, tt.wantSilent)
+			}
+		})
+	}
+}
+
+func TestPolicyForChannel(t *testing.T) {
+	c, err := ParseConfig([]byte(`
+policy:
+  denied: [intranet.local]
+  allowed_only: [example.com, intranet.local]
+channels:
+  "#Strict":
+    policy:
+      silent: [example.com]
+      allowed_only: [yle.fi]
+`), "yaml")
+	if err != nil {
+		t.Fatalf("ParseConfig() error = %v", err)
+	}
+	SetConfig(c)
+	t.Cleanup(func() { SetConfig(DefaultConfig()) })
+
+	// defaults are kept when not set in the file
+	if len(c.Policy.Silent) != len(DefaultConfig().Policy.Silent) {
+		t.Errorf("default silent domains missing from parsed config")
+	}
+
+	tests := []struct {
+		name       string
+		channel    string
+		url        string
+		wantSilent bool
+		wantErr    bool
+	}{
+		{"Global allowed", "#other", "https://example.com/", false, false},
+		{"Global not allowed", "#other", "https://yle.fi/", false, true},
+		{"Global denied beats allowed", "", "https://intranet.local/", false, true},
+		{"Channel allowed replaces global", "#strict", "https://yle.fi/", false, false},
+		{"Channel not allowed", "#strict", "https://example.com/", false, true},
+		{"Channel still denied", "#strict", "https://intranet.local/", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			silent, err := policyFor(tt.channel).Check(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if silent != tt.wantSilent {
+				t.Errorf("Check() silent = %v, want %v", silent, tt.wantSilent)
+			}
+		})
+	}
+}
+
+func TestHandleRequestPolicy(t *testing.T) {
+	c := DefaultConfig()
+	c.Policy.Denied = []string{"intranet.local"}
+	SetConfig(c)
+	t.Cleanup(func() { SetConfig(DefaultConfig()) })
+
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{"Silent", "https://pr0gramm.com/top/Triggerhochlad/3974882", false},
+		{"Twitter", "https://twitter.com/someone/status/123", false},
+		{"Denied", "http://wiki.intranet.local/", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HandleRequest(context.Background(), TitleQuery{URL: tt.url})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("HandleRequest() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got.Title != "" {
+				t.Errorf("HandleRequest() title = %v, want empty", got.Title)
+			}
+		})
+	}
+}
+
+func TestPolicyCheckChain(t *testing.T) {
+	t.Parallel()
+
+	p := Policy{Silent: []string{"silent.example"}, Denied: []string{"denied.example"}}
+	tests := []struct {
+		name       string
+		chain      []string
+		wantSilent bool
+		wantErr    bool
+	}{
+		{"No redirects", nil, false, false},
+		{"Allowed", []string{"https://a.example/", "https://b.example/"}, false, false},
+		{"Silent hop", []string{"https://a.example/", "https://silent.example/x"}, true, false},
+		{"Denied hop", []string{"https://denied.example/x", "https://a.example/"}, false, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			silent, err := p.CheckChain(tt.chain)
+			if silent != tt.wantSilent || (err != nil) != tt.wantErr {
+				t.Errorf("CheckChain() = %v, %v, want %v, error %v", silent, err, tt.wantSilent, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,361 @@
+package lambda
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/lepinkainen/titleparser/common"
+	"github.com/lepinkainen/titleparser/locale"
+	"github.com/rivo/uniseg"
+	log "github.com/sirupsen/logrus"
+)
+
+// Product is a schema.org Product with its offer, as found in a shop page
+type Product struct {
+	Name         string
+	Price        float64
+	Currency     string
+	Availability string
+}
+
+var (
+	// currencySymbols for the currencies we see most, others are shown as the ISO code
+	currencySymbols = map[string]string{
+		"EUR": "€",
+		"USD": "$",
+		"GBP": "£",
+		"SEK": "kr",
+		"NOK": "kr",
+		"DKK": "kr",
+		"JPY": "¥",
+	}
+
+	// commaLocales write prices as "1 299,90 €" instead of "€1,299.90"
+	commaLocales = map[string]bool{
+		"fi": true, "sv": true, "et": true, "de": true, "fr": true, "es": true, "it": true, "nl": true,
+		"pt": true, "da": true, "nb": true, "nn": true, "no": true, "pl": true, "cs": true, "ru": true,
+	}
+
+	// availabilities maps schema.org ItemAvailability values and OpenGraph product:availability to messages
+	availabilities = map[string]string{
+		"instock":             "stock.in",
+		"in stock":            "stock.in",
+		"instoreonly":         "stock.storeonly",
+		"onlineonly":          "stock.in",
+		"limitedavailability": "stock.limited",
+		"outofstock":          "stock.out",
+		"out of stock":        "stock.out",
+		"oos":                 "stock.out",
+		"soldout":             "stock.soldout",
+		"preorder":            "stock.preorder",
+		"presale":             "stock.preorder",
+		"backorder":           "stock.backorder",
+		"discontinued":        "stock.discont",
+	}
+)
+
+// ExtractProduct finds product information from JSON-LD, microdata or
+// OpenGraph product tags, in that order. Returns nil if there's no price.
+func ExtractProduct(doc *goquery.Document) *Product {
+	for _, extract := range []func(*goquery.Document) *Product{productFromJSONLD, productFromMicrodata, productFromOpenGraph} {
+		if p := extract(doc); p != nil && p.Price > 0 {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// Format renders the product as "Name – 129,90 € (in stock)" using the
+// number format of the given language and availability in the given locale.
+// Title is used if the product has no name.
+func (p *Product) Format(loc *locale.Locale, title, lang string) string {
+	name := p.Name
+	if name == "" {
+		name = title
+	}
+
+	suffix := " – " + FormatPrice(p.Price, p.Currency, lang)
+	if availability, ok := availabilities[normalizeAvailability(p.Availability)]; ok {
+		suffix = fmt.Sprintf("%s (%s)", suffix, loc.Message(availability))
+	}
+
+	// The price is what the title is for, long names are shortened instead
+	return common.SanitizeTitle(name, TitleMax-uniseg.GraphemeClusterCount(suffix)) + suffix
+}
+
+// FormatPrice formats a price the way it's written in the given language
+func FormatPrice(price float64, currency, lang string) string {
+	currency = strings.ToUpper(currency)
+	symbol, ok := currencySymbols[currency]
+	if !ok {
+		symbol = currency
+	}
+
+	if commaLocales[baseLanguage(lang)] {
+		amount := groupThousands(price, " ", ",")
+		if symbol == "" {
+			return amount
+		}
+		return amount + " " + symbol
+	}
+
+	amount := groupThousands(price, ",", ".")
+	if utf8.RuneCountInString(symbol) > 1 {
+		// ISO codes and "kr" read better after the number
+		return amount + " " + symbol
+	}
+	return symbol + amount
+}
+
+// groupThousands formats the price with two decimals and the given separators
+func groupThousands(price float64, thousands, decimal string) string {
+	cents := int64(math.Round(price * 100))
+	whole := strconv.FormatInt(cents/100, 10)
+
+	var b strings.Builder
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(thousands)
+		}
+		b.WriteRune(r)
+	}
+
+	return fmt.Sprintf("%s%s%02d", b.String(), decimal, cents%100)
+}
+
+// baseLanguage turns "fi-FI", "fi_FI" and "FI" into "fi"
+func baseLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	return lang
+}
+
+// PageLanguage returns the declared language of the document, if any
+func PageLanguage(doc *goquery.Document) string {
+	if lang, ok := doc.Find("html").Attr("lang"); ok && lang != "" {
+		return lang
+	}
+	if locale, ok := doc.Find(`meta[property="og:locale"]`).Attr("content"); ok {
+		return locale
+	}
+	return ""
+}
+
+// normalizeAvailability turns "https://schema.org/InStock" into "instock"
+func normalizeAvailability(availability string) string {
+	availability = strings.TrimSpace(availability)
+	if i := strings.LastIndex(availability, "/"); i >= 0 {
+		availability = availability[i+1:]
+	}
+	return strings.ToLower(availability)
+}
+
+// parsePrice understands both "1299.90" and "1 299,90" style prices, a single
+// separator followed by three digits groups thousands
+func parsePrice(value string) float64 {
+	value = strings.Map(func(r rune) rune {
+		if (r >= '0' && r <= '9') || r == '.' || r == ',' {
+			return r
+		}
+		return -1
+	}, value)
+
+	dot, comma := strings.LastIndex(value, "."), strings.LastIndex(value, ",")
+	switch {
+	case dot >= 0 && comma >= 0:
+		// whichever comes last is the decimal separator
+		if comma > dot {
+			value = strings.ReplaceAll(value, ".", "")
+			value = strings.Replace(value, ",", ".", 1)
+		} else {
+			value = strings.ReplaceAll(value, ",", "")
+		}
+	case comma >= 0:
+		if strings.Count(value, ",") > 1 || len(value)-comma-1 == 3 {
+			// "1,299" and "1,299,000" use comma for thousands
+			value = strings.ReplaceAll(value, ",", "")
+		} else {
+			value = strings.Replace(value, ",", ".", 1)
+		}
+	case dot >= 0:
+		if strings.Count(value, ".") > 1 || len(value)-dot-1 == 3 {
+			// "1.299" and "1.299.000" use dot for thousands
+			value = strings.ReplaceAll(value, ".", "")
+		}
+	}
+
+	price, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return price
+}
+
+// productFromOpenGraph reads the product:price:* OpenGraph tags
+func productFromOpenGraph(doc *goquery.Document) *Product {
+	meta := func(property string) string {
+		value, _ := doc.Find(fmt.Sprintf(`meta[property=%q]`, property)).First().Attr("content")
+		return strings.TrimSpace(value)
+	}
+
+	amount := meta("product:price:amount")
+	if amount == "" {
+		amount = meta("og:price:amount")
+	}
+	if amount == "" {
+		return nil
+	}
+
+	currency := meta("product:price:currency")
+	if currency == "" {
+		currency = meta("og:price:currency")
+	}
+
+	return &Product{
+		Price:        parsePrice(amount),
+		Currency:     currency,
+		Availability: meta("product:availability"),
+	}
+}
+
+// productFromMicrodata reads itemprops inside a schema.org/Product itemscope
+func productFromMicrodata(doc *goquery.Document) *Product {
+	scope := doc.Find(`[itemtype$="schema.org/Product"]`).First()
+	if scope.Size() == 0 {
+		return nil
+	}
+
+	prop := func(name string) string {
+		s := scope.Find(fmt.Sprintf(`[itemprop=%q]`, name)).First()
+		for _, attr := range []string{"content", "href"} {
+			if value, ok := s.Attr(attr); ok {
+				return strings.TrimSpace(value)
+			}
+		}
+		return strings.Join(strings.Fields(s.Text()), " ")
+	}
+
+	price := prop("price")
+	if price == "" {
+		price = prop("lowPrice")
+	}
+	if price == "" {
+		return nil
+	}
+
+	return &Product{
+		Name:         prop("name"),
+		Price:        parsePrice(price),
+		Currency:     prop("priceCurrency"),
+		Availability: prop("availability"),
+	}
+}
+
+// productFromJSONLD looks for a Product in the JSON-LD blocks of the page
+func productFromJSONLD(doc *goquery.Document) *Product {
+	var product *Product
+
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		var data any
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			log.Debugf("Invalid JSON-LD block: %v", err)
+			return true
+		}
+
+		if node := findJSONLDType(data, "Product"); node != nil {
+			product = productFromJSONLDNode(node)
+		}
+		return product == nil
+	})
+
+	return product
+}
+
+// findJSONLDType returns the first node of the given @type, looking into arrays and @graph
+func findJSONLDType(data any, typ string) map[string]any {
+	switch v := data.(type) {
+	case []any:
+		for _, item := range v {
+			if node := findJSONLDType(item, typ); node != nil {
+				return node
+			}
+		}
+	case map[string]any:
+		if hasJSONLDType(v, typ) {
+			return v
+		}
+		if graph, ok := v["@graph"]; ok {
+			return findJSONLDType(graph, typ)
+		}
+	}
+
+	return nil
+}
+
+// hasJSONLDType checks the @type of a node, which can be a string or a list
+func hasJSONLDType(node map[string]any, typ string) bool {
+	switch t := node["@type"].(type) {
+	case string:
+		return t == typ
+	case []any:
+		for _, item := range t {
+			if s, ok := item.(string); ok && s == typ {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// productFromJSONLDNode reads name and the first offer of a Product node
+func productFromJSONLDNode(node map[string]any) *Product {
+	p := &Product{Name: jsonLDString(node["name"])}
+
+	offer := node["offers"]
+	if offers, ok := offer.([]any); ok && len(offers) > 0 {
+		offer = offers[0]
+	}
+	o, ok := offer.(map[string]any)
+	if !ok {
+		return p
+	}
+
+	price := jsonLDString(o["price"])
+	if price == "" {
+		price = jsonLDString(o["lowPrice"])
+	}
+	if price == "" {
+		if spec, ok := o["priceSpecification"].(map[string]any); ok {
+			price = jsonLDString(spec["price"])
+			if p.Currency = jsonLDString(spec["priceCurrency"]); p.Currency == "" {
+				p.Currency = jsonLDString(o["priceCurrency"])
+			}
+		}
+	}
+	p.Price = parsePrice(price)
+	if p.Currency == "" {
+		p.Currency = jsonLDString(o["priceCurrency"])
+	}
+	p.Availability = jsonLDString(o["availability"])
+
+	return p
+}
+
+// jsonLDString returns strings and numbers as a string
+func jsonLDString(value any) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
@@ -0,0 +1,147 @@
+package lambda
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/lepinkainen/titleparser/locale"
+	"github.com/rivo/uniseg"
+)
+
+// loadFixture parses a saved HTML page from testdata
+func loadFixture(t *testing.T, name string) *goquery.Document {
+	t.Helper()
+
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestExtractProduct(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		fixture string
+		want    string
+	}{
+		{"JSON-LD", "verkkokauppa.html", "Nikko Vaporizr 2 -kauko-ohjattava auto, sininen – 129,90 € (in stock)"},
+		{"Microdata", "microdata.html", `Samsung 65" QN90D 4K Neo QLED älytelevisio – 1 299,00 € (out of stock)`},
+		{"OpenGraph", "opengraph-product.html", "Mechanical keyboard – $89.50 (in stock)"},
+		{"JSON-LD graph", "graph.html", "Kahvinkeitin – €49.90 (preorder)"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			doc := loadFixture(t, tt.fixture)
+			title, err := TitleFromDocument(doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			product := ExtractProduct(doc)
+			if product == nil {
+				t.Fatal("ExtractProduct() = nil")
+			}
+			if got := product.Format(locale.English, title, PageLanguage(doc)); got != tt.want {
+				t.Errorf("Format() = '%v', want '%v'", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProductFormatLongName(t *testing.T) {
+	t.Parallel()
+
+	p := &Product{Name: strings.Repeat("Very long product name ", 20), Price: 1299, Currency: "EUR", Availability: "https://schema.org/InStock"}
+	got := p.Format(locale.English, "", "fi")
+	if !strings.HasSuffix(got, "... – 1 299,00 € (in stock)") {
+		t.Errorf("Format() = %q, want the price and availability kept", got)
+	}
+	if n := uniseg.GraphemeClusterCount(got); n > TitleMax {
+		t.Errorf("Format() is %d long, want at most %d", n, TitleMax)
+	}
+}
+
+func TestExtractProductNone(t *testing.T) {
+	t.Parallel()
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><head><title>Just a page</title></head></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if product := ExtractProduct(doc); product != nil {
+		t.Errorf("ExtractProduct() = %v, want nil", product)
+	}
+}
+
+func TestFormatPrice(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		price    float64
+		currency string
+		lang     string
+		want     string
+	}{
+		{"Finnish euros", 129.9, "EUR", "fi", "129,90 €"},
+		{"Finnish thousands", 1299, "EUR", "fi-FI", "1 299,00 €"},
+		{"English euros", 129.9, "eur", "en", "€129.90"},
+		{"English thousands", 1234567.891, "USD", "en-US", "$1,234,567.89"},
+		{"Swedish crowns", 49, "SEK", "sv", "49,00 kr"},
+		{"English crowns", 49, "SEK", "en", "49.00 kr"},
+		{"Unknown currency", 10, "CHF", "", "10.00 CHF"},
+		{"No currency", 10, "", "fi", "10,00"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := FormatPrice(tt.price, tt.currency, tt.lang); got != tt.want {
+				t.Errorf("FormatPrice() = '%v', want '%v'", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePrice(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value string
+		want  float64
+	}{
+		{"129.90", 129.9},
+		{"129,90", 129.9},
+		{"1 299,90 €", 1299.9},
+		{"1.299,90", 1299.9},
+		{"1,299.90", 1299.9},
+		{"1,299", 1299},
+		{"1.299", 1299},
+		{"1.299,00", 1299},
+		{"1,299.00", 1299},
+		{"1.299.000", 1299000},
+		{"$5", 5},
+		{"free", 0},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.value, func(t *testing.T) {
+			t.Parallel()
+			if got := parsePrice(tt.value); got != tt.want {
+				t.Errorf("parsePrice() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
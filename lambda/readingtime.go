@@ -0,0 +1,137 @@
+package lambda
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// wordsPerMinute for languages that separate words with spaces
+	wordsPerMinute = 230
+	// cjkCharsPerMinute for Chinese and Japanese, which don't
+	cjkCharsPerMinute = 500
+	// minReadingWords is the least amount of text we consider an article
+	minReadingWords = 200
+)
+
+// articleTypes are the JSON-LD types of pages worth a reading time
+var articleTypes = []string{"Article", "NewsArticle", "BlogPosting"}
+
+// ReadingTime estimates the reading time of the main content of the page in
+// minutes. Returns 0 if the page doesn't say it's an article or doesn't have
+// enough text to be one, long product and forum pages don't get one.
+func ReadingTime(doc *goquery.Document) int {
+	if !isArticle(doc) {
+		return 0
+	}
+
+	words, cjk := CountWords(ArticleText(doc))
+	if words+cjk/2 < minReadingWords {
+		return 0
+	}
+
+	minutes := float64(words)/wordsPerMinute + float64(cjk)/cjkCharsPerMinute
+	return int(math.Max(1, math.Round(minutes)))
+}
+
+// isArticle checks the page for og:type article, an <article> element or an
+// article in JSON-LD
+func isArticle(doc *goquery.Document) bool {
+	if strings.EqualFold(doc.Find(`meta[property="og:type"]`).AttrOr("content", ""), "article") {
+		return true
+	}
+	if doc.Find("article").Size() > 0 {
+		return true
+	}
+
+	found := false
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		var data any
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			log.Debugf("Invalid JSON-LD block: %v", err)
+			return true
+		}
+		for _, typ := range articleTypes {
+			if findJSONLDType(data, typ) != nil {
+				found = true
+			}
+		}
+		return !found
+	})
+
+	return found
+}
+
+// ArticleText returns the text of the main content of the page: <article>,
+// <main> or the element with the most paragraph text in it
+func ArticleText(doc *goquery.Document) string {
+	body := doc.Find("body").Clone()
+	body.Find("script, style, noscript, nav, aside, header, footer, form, iframe").Remove()
+
+	for _, selector := range []string{"article", "main", `[role="main"]`} {
+		if s := body.Find(selector); s.Size() > 0 {
+			// Some pages have a list of article teasers, pick the longest one
+			return longestText(s)
+		}
+	}
+
+	// Readability style: whichever element has the most text in its own paragraphs
+	var best string
+	body.Find("p").Parent().Each(func(_ int, s *goquery.Selection) {
+		var text strings.Builder
+		s.ChildrenFiltered("p").Each(func(_ int, p *goquery.Selection) {
+			text.WriteString(p.Text())
+			text.WriteString(" ")
+		})
+		if text.Len() > len(best) {
+			best = text.String()
+		}
+	})
+
+	return best
+}
+
+// longestText returns the text of the selected element with the most text
+func longestText(s *goquery.Selection) string {
+	var longest string
+	s.Each(func(_ int, e *goquery.Selection) {
+		if text := e.Text(); len(text) > len(longest) {
+			longest = text
+		}
+	})
+	return longest
+}
+
+// CountWords counts space separated words and CJK characters separately,
+// since Chinese and Japanese text has no spaces between words
+func CountWords(text string) (words, cjk int) {
+	inWord := false
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			cjk++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if !inWord {
+				words++
+			}
+			inWord = true
+		case r == '\'' || r == '’' || r == '-':
+			// don't, well-known
+		default:
+			inWord = false
+		}
+	}
+
+	return words, cjk
+}
+
+// isCJK is true for Han, Hiragana and Katakana. Korean uses spaces between words.
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r)
+}
@@ -0,0 +1,128 @@
+package lambda
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestCountWords(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		text      string
+		wantWords int
+		wantCJK   int
+	}{
+		{"English", "Don't panic, it's a well-known fact.", 6, 0},
+		{"Finnish", "Jätteiden mukana palaa miljoonien edestä metalleja", 6, 0},
+		{"Japanese", "東京で新しい電車", 0, 8},
+		{"Mixed", "iPhone 15 の発売日", 2, 4},
+		{"Korean uses spaces", "한국어 문장 입니다", 3, 0},
+		{"Empty", "", 0, 0},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			words, cjk := CountWords(tt.text)
+			if words != tt.wantWords || cjk != tt.wantCJK {
+				t.Errorf("CountWords() = %d, %d, want %d, %d", words, cjk, tt.wantWords, tt.wantCJK)
+			}
+		})
+	}
+}
+
+func TestReadingTime(t *testing.T) {
+	t.Parallel()
+
+	long := strings.Repeat("<p>"+strings.Repeat("word ", 100)+"</p>", 10)
+	tests := []struct {
+		name string
+		html string
+		want int
+	}{
+		{"Long article", "", 8},
+		{"Short article", "", 0},
+		{"Main element", `<meta property="og:type" content="article"><main>` + long + "</main>", 4},
+		{"Paragraph density", `<script type="application/ld+json">{"@graph":[{"@type":["NewsArticle"]}]}</script>` +
+			"<div><p>Menu</p></div><div class='content'>" + long + "</div>", 4},
+		{"Not an article", `<meta property="og:type" content="product"><main>` + long + "</main>", 0},
+		{"Navigation ignored", "<nav>" + long + "</nav><p>Hello</p>", 0},
+		{"Japanese", "<article><p>" + strings.Repeat("日本語の文章", 400) + "</p></article>", 5},
+	}
+	fixtures := map[string]string{
+		"Long article":  "longread.html",
+		"Short article": "article.html",
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var doc *goquery.Document
+			if fixture, ok := fixtures[tt.name]; ok {
+				doc = loadFixture(t, fixture)
+			} else {
+				var err error
+				doc, err = goquery.NewDocumentFromReader(strings.NewReader("<html><body>" + tt.html + "</body></html>"))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := ReadingTime(doc); got != tt.want {
+				t.Errorf("ReadingTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleRequestReadingTime(t *testing.T) {
+	page, err := os.ReadFile("testdata/longread.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, string(page))
+	}))
+	defer srv.Close()
+
+	t.Setenv("RUNMODE", "local")
+	c := DefaultConfig()
+	c.Channels = map[string]ChannelConfig{
+		"#reading": {ReadingTime: true},
+		"#lukijat": {ReadingTime: true, Locale: "fi"},
+	}
+	SetConfig(c)
+	t.Cleanup(func() { SetConfig(DefaultConfig()) })
+
+	tests := []struct {
+		channel string
+		want    string
+	}{
+		{"#reading", "Council approves the new tram line [8 min read]"},
+		{"#lukijat", "Council approves the new tram line [lukuaika 8 min]"},
+		{"#other", "Council approves the new tram line"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.channel, func(t *testing.T) {
+			got, err := HandleRequest(context.Background(), TitleQuery{Channel: tt.channel, URL: srv.URL + "/article"})
+			if err != nil {
+				t.Fatalf("HandleRequest() error = %v", err)
+			}
+			if got.Title != tt.want {
+				t.Errorf("HandleRequest() title = '%v', want '%v'", got.Title, tt.want)
+			}
+			if got.ReadingTime != 8 {
+				t.Errorf("HandleRequest() reading time = %v, want 8", got.ReadingTime)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package lambda
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultHandlerName is the name of the handler used when no pattern matches
+const DefaultHandlerName = "default"
+
+// handlerFunc gets the title for the URL, the context has the preferred languages of the request
+type handlerFunc func(context.Context, string) (string, error)
+
+// registration is a handler in the registry
+type registration struct {
+	name    string
+	pattern string
+	regexp  *regexp.Regexp
+	handler handlerFunc
+	// override registrations are tried before all the others
+	override bool
+}
+
+var (
+	registryMu sync.RWMutex
+	// registry is in registration order, the first matching handler is used
+	registry []registration
+	// catalog has the details of the handlers by name
+	catalog = map[string]Handler{}
+)
+
+// Handler is a title handler with the details shown in the handler catalog
+type Handler struct {
+	// Name defaults to the function name, e.g. "handler.Youtube"
+	Name        string
+	Description string
+	// Patterns are the URLs the handler is used for
+	Patterns []string
+	// Examples are URLs the handler is meant for
+	Examples []string
+	// Env lists the environment variables the handler needs. Without them
+	// it's disabled and its URLs go to the next matching handler.
+	Env  []string
+	Func handlerFunc
+	// Override puts the handler before the ones without it, so it takes over
+	// the URLs of built-in handlers even with a broader pattern
+	Override bool
+}
+
+// Register adds the handler to the registry. Registering a pattern again
+// replaces the earlier handler for it, registering a name again replaces
+// its details.
+func Register(h Handler) {
+	if h.Name == "" {
+		h.Name = funcName(h.Func)
+	}
+
+	compiled := make([]*regexp.Regexp, 0, len(h.Patterns))
+	for _, pattern := range h.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Errorf("Not registering handler %s, invalid pattern %s: %v", h.Name, pattern, err)
+			return
+		}
+		compiled = append(compiled, re)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	catalog[h.Name] = h
+	for i, pattern := range h.Patterns {
+		add(registration{name: h.Name, pattern: pattern, regexp: compiled[i], handler: h.Func, override: h.Override})
+	}
+}
+
+// add puts the registration in place of the one with the same pattern, or
+// last. Overrides go after the earlier overrides, before everything else.
+func add(reg registration) {
+	if reg.override {
+		registry = slices.DeleteFunc(registry, func(r registration) bool { return r.pattern == reg.pattern })
+		at := 0
+		for at < len(registry) && registry[at].override {
+			at++
+		}
+		registry = slices.Insert(registry, at, reg)
+		return
+	}
+
+	for i := range registry {
+		if registry[i].pattern == reg.pattern {
+			registry[i] = reg
+			return
+		}
+	}
+	registry = append(registry, reg)
+}
+
+// RegisterHandler adds the given url parser and pattern to the map of handlers,
+// named after the handler function
+func RegisterHandler(pattern string, function handlerFunc) {
+	Register(Handler{Patterns: []string{pattern}, Func: function})
+}
+
+// RegisterNamedHandler adds a handler with the given name. Registering the
+// same pattern again replaces the earlier handler.
+func RegisterNamedHandler(name, pattern string, function handlerFunc) {
+	Register(Handler{Name: name, Patterns: []string{pattern}, Func: function})
+}
+
+// HandlerInfo is a handler in the catalog
+type HandlerInfo struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Patterns    []string `json:"patterns,omitempty"`
+	Examples    []string `json:"examples,omitempty"`
+	Env         []string `json:"env,omitempty"`
+	Enabled     bool     `json:"enabled"`
+	// Disabled tells why the handler isn't used
+	Disabled string `json:"disabled,omitempty"`
+}
+
+// Handlers lists the registered handlers in the order they are tried, the
+// default handler last
+func Handlers() []HandlerInfo {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	var infos []HandlerInfo
+	index := make(map[string]int)
+	for _, reg := range registry {
+		if i, ok := index[reg.name]; ok {
+			infos[i].Patterns = append(infos[i].Patterns, reg.pattern)
+			continue
+		}
+
+		h := catalog[reg.name]
+		disabled := missingEnv(h.Env)
+		index[reg.name] = len(infos)
+		infos = append(infos, HandlerInfo{
+			Name:        reg.name,
+			Description: h.Description,
+			Patterns:    []string{reg.pattern},
+			Examples:    h.Examples,
+			Env:         h.Env,
+			Enabled:     disabled == "",
+			Disabled:    disabled,
+		})
+	}
+
+	return append(infos, HandlerInfo{
+		Name:        DefaultHandlerName,
+		Description: "oEmbed for known providers, otherwise og:title or <title> with product, media and age details. Used when no other handler matches.",
+		Enabled:     true,
+	})
+}
+
+// HandlerMatch is a handler whose pattern matches a URL
+type HandlerMatch struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+	// Disabled tells why the handler isn't used
+	Disabled string `json:"disabled,omitempty"`
+}
+
+// Match lists the handlers whose pattern matches the URL, the first one
+// that isn't disabled is used. Shortened URLs are matched as they are, they
+// are expanded only when fetching.
+func Match(url string) []HandlerMatch {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	var matches []HandlerMatch
+	for _, reg := range registry {
+		if reg.regexp.MatchString(url) {
+			matches = append(matches, HandlerMatch{Name: reg.name, Pattern: reg.pattern, Disabled: missingEnv(catalog[reg.name].Env)})
+		}
+	}
+	return matches
+}
+
+// matchHandler returns the first enabled handler matching the URL
+func matchHandler(url string) (registration, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	for _, reg := range registry {
+		if reg.regexp.MatchString(url) && missingEnv(catalog[reg.name].Env) == "" {
+			return reg, true
+		}
+	}
+	return registration{}, false
+}
+
+// handlerNamed finds a handler by name, DefaultHandlerName included
+func handlerNamed(name string) (handlerFunc, bool) {
+	if name == DefaultHandlerName {
+		return DefaultHandler, true
+	}
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	for _, reg := range registry {
+		if reg.name == name {
+			return reg.handler, true
+		}
+	}
+	return nil, false
+}
+
+// handlerDisabled tells why the named handler can't be used, empty if it can
+func handlerDisabled(name string) string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return missingEnv(catalog[name].Env)
+}
+
+// missingEnv lists the unset environment variables, empty if all are set
+func missingEnv(env []string) string {
+	var missing []string
+	for _, name := range env {
+		if os.Getenv(name) == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return ""
+	}
+	return strings.Join(missing, ", ") + " not set"
+}
+
+// HasHandler checks if there's a handler with the name
+func HasHandler(name string) bool {
+	_, ok := handlerNamed(name)
+	return ok
+}
+
+// funcName is the handler function name without the package path,
+// e.g. "handler.Youtube"
+func funcName(function handlerFunc) string {
+	name := runtime.FuncForPC(reflect.ValueOf(function).Pointer()).Name()
+	name = name[strings.LastIndex(name, "/")+1:]
+	return strings.TrimSuffix(name, "-fm")
+}
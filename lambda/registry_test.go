@@ -0,0 +1,112 @@
+package lambda
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func registryTestHandler(ctx context.Context, url string) (string, error) {
+	return "first", nil
+}
+
+func TestRegistry(t *testing.T) {
+	RegisterHandler(`^https://registry\.test/`, registryTestHandler)
+	RegisterNamedHandler("test.Specific", `^https://registry\.test/specific`, func(ctx context.Context, url string) (string, error) {
+		return "specific", nil
+	})
+	RegisterNamedHandler("test.Invalid", `(`, registryTestHandler)
+
+	want := []HandlerMatch{
+		{Name: "lambda.registryTestHandler", Pattern: `^https://registry\.test/`},
+		{Name: "test.Specific", Pattern: `^https://registry\.test/specific`},
+	}
+	if got := Match("https://registry.test/specific/1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Match() = %+v, want %+v", got, want)
+	}
+	if HasHandler("test.Invalid") {
+		t.Error("handler with an invalid pattern registered")
+	}
+
+	// The first registered match is used, or the forced handler
+	ctx := context.Background()
+	if title, _ := dispatch(ctx, "https://registry.test/specific/1"); title != "first" {
+		t.Errorf("dispatch() = %q, want the first registered handler", title)
+	}
+	forced := WithOptions(ctx, Options{Handler: "test.Specific"})
+	if title, _ := dispatch(forced, "https://registry.test/specific/1"); title != "specific" {
+		t.Errorf("dispatch() = %q, want the forced handler", title)
+	}
+	if _, err := dispatch(WithOptions(ctx, Options{Handler: "nope"}), "https://registry.test/"); err == nil {
+		t.Error("dispatch() accepted an unknown handler")
+	}
+
+	// Registering a pattern again replaces the handler in place
+	RegisterNamedHandler("test.Replaced", `^https://registry\.test/`, registryTestHandler)
+	if got := Match("https://registry.test/specific/1"); got[0].Name != "test.Replaced" {
+		t.Errorf("Match() = %+v, want the replaced handler first", got)
+	}
+
+	// Overrides go first in the order they're registered, whatever the pattern
+	Register(Handler{Name: "test.Override", Patterns: []string{`registry\.test`}, Func: registryTestHandler, Override: true})
+	Register(Handler{Name: "test.Override2", Patterns: []string{`registry\.test/specific`}, Func: registryTestHandler, Override: true})
+	if got := Match("https://registry.test/specific/1"); got[0].Name != "test.Override" || got[1].Name != "test.Override2" {
+		t.Errorf("Match() = %+v, want the overrides first", got)
+	}
+}
+
+func catalogTestHandler(ctx context.Context, url string) (string, error) {
+	return "catalog", nil
+}
+
+func TestHandlerCatalog(t *testing.T) {
+	Register(Handler{
+		Description: "Catalog test pages",
+		Patterns:    []string{`^https://catalog\.test/a/`, `^https://catalog\.test/b/`},
+		Examples:    []string{"https://catalog.test/a/1"},
+		Env:         []string{"CATALOG_TEST_KEY", "CATALOG_TEST_SECRET"},
+		Func:        catalogTestHandler,
+	})
+	t.Setenv("CATALOG_TEST_SECRET", "secret")
+
+	find := func() HandlerInfo {
+		for _, h := range Handlers() {
+			if h.Name == "lambda.catalogTestHandler" {
+				return h
+			}
+		}
+		t.Fatalf("Handlers() = %+v, no catalog test handler", Handlers())
+		return HandlerInfo{}
+	}
+
+	want := HandlerInfo{
+		Name:        "lambda.catalogTestHandler",
+		Description: "Catalog test pages",
+		Patterns:    []string{`^https://catalog\.test/a/`, `^https://catalog\.test/b/`},
+		Examples:    []string{"https://catalog.test/a/1"},
+		Env:         []string{"CATALOG_TEST_KEY", "CATALOG_TEST_SECRET"},
+		Disabled:    "CATALOG_TEST_KEY not set",
+	}
+	if got := find(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Handlers() = %+v, want %+v", got, want)
+	}
+	if got := Handlers(); got[len(got)-1].Name != DefaultHandlerName {
+		t.Errorf("Handlers() = %+v, want the default handler last", got)
+	}
+
+	// Disabled handlers are skipped, the URL goes to the next handler
+	if _, ok := matchHandler("https://catalog.test/b/1"); ok {
+		t.Error("matchHandler() used a disabled handler")
+	}
+	if _, err := dispatch(WithOptions(context.Background(), Options{Handler: "lambda.catalogTestHandler"}), "https://catalog.test/b/1"); err == nil {
+		t.Error("dispatch() ran a disabled handler")
+	}
+
+	t.Setenv("CATALOG_TEST_KEY", "key")
+	if got := find(); !got.Enabled || got.Disabled != "" {
+		t.Errorf("Handlers() = %+v, want it enabled", got)
+	}
+	if _, ok := matchHandler("https://catalog.test/b/1"); !ok {
+		t.Error("matchHandler() skipped an enabled handler")
+	}
+}
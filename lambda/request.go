@@ -0,0 +1,32 @@
+package lambda
+
+import "context"
+
+// requestDetails collects metadata found while handling a single request,
+// for the parts of the response that aren't the title
+type requestDetails struct {
+	ReadingTime int
+	Sections    map[string]string
+	Canonical   string
+	// FinalURL is where the page was found after redirects
+	FinalURL string
+	// Redirects are the URLs redirected to, shortener expansion included
+	Redirects []string
+}
+
+type detailsKey struct{}
+
+// withDetails starts collecting details for a request
+func withDetails(ctx context.Context) (context.Context, *requestDetails) {
+	details := &requestDetails{}
+	return context.WithValue(ctx, detailsKey{}, details), details
+}
+
+// detailsFromContext returns the details being collected for the request,
+// or a throwaway value when called outside of HandleRequest
+func detailsFromContext(ctx context.Context) *requestDetails {
+	if details, ok := ctx.Value(detailsKey{}).(*requestDetails); ok {
+		return details
+	}
+	return &requestDetails{}
+}
@@ -0,0 +1,85 @@
+package lambda
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/lepinkainen/titleparser/common"
+)
+
+const (
+	// maxSections keeps the cache item size sane on huge reference pages
+	maxSections = 500
+	// maxSectionLength cuts overly long headings
+	maxSectionLength = 100
+)
+
+const headings = "h1, h2, h3, h4, h5, h6"
+
+// Sections maps the fragment targets (id and name attributes) in the page to
+// the heading text of the section they point to. The whole map is cached with
+// the page, so any fragment of the page can be titled from the cache.
+func Sections(doc *goquery.Document) map[string]string {
+	sections := make(map[string]string)
+
+	doc.Find("body [id], body a[name]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		anchor, ok := s.Attr("id")
+		if !ok || anchor == "" {
+			anchor = s.AttrOr("name", "")
+		}
+		if anchor == "" {
+			return true
+		}
+		if _, seen := sections[anchor]; seen {
+			return true
+		}
+		if heading := headingText(sectionHeading(s)); heading != "" {
+			sections[anchor] = heading
+		}
+		return len(sections) < maxSections
+	})
+
+	return sections
+}
+
+// sectionHeading finds the heading the fragment target refers to:
+// the element itself, the heading it's inside of, the heading that starts
+// the section element or the heading right after an empty anchor
+func sectionHeading(s *goquery.Selection) *goquery.Selection {
+	if s.Is(headings) {
+		return s
+	}
+	// Old Wikipedia style <h2><span class="mw-headline" id="...">
+	if h := s.Closest(headings); h.Size() > 0 {
+		return h
+	}
+	// <section id="..."><h2>...
+	if h := s.ChildrenFiltered(headings).First(); h.Size() > 0 && h.Prev().Size() == 0 {
+		return h
+	}
+	// <a name="..."></a><h2>...
+	if strings.TrimSpace(s.Text()) == "" {
+		if h := s.Next(); h.Is(headings) {
+			return h
+		}
+	}
+	return nil
+}
+
+// headingText cleans edit links and permalink markers from the heading
+func headingText(h *goquery.Selection) string {
+	if h == nil || h.Size() == 0 {
+		return ""
+	}
+
+	h = h.Clone()
+	h.Find(".mw-editsection, .headerlink, .anchor, .Documentation-idLink").Remove()
+	h.Find(`a[href^="#"]`).Each(func(_ int, a *goquery.Selection) {
+		switch strings.TrimSpace(a.Text()) {
+		case "", "¶", "#", "§", "🔗":
+			a.Remove()
+		}
+	})
+
+	return common.SanitizeTitle(h.Text(), maxSectionLength)
+}
@@ -0,0 +1,143 @@
+package lambda
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rivo/uniseg"
+)
+
+func TestSections(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		fixture  string
+		fragment string
+		want     string
+	}{
+		{"wikipedia.html", "History", "History"},
+		{"wikipedia.html", "Concurrency", "Concurrency"},
+		{"wikipedia.html", "Käyttö", "Käyttö"},
+		{"wikipedia.html", "Reception", "Reception"},
+		{"wikipedia.html", "legacy", "Legacy anchor"},
+		{"wikipedia.html", "toc", ""},
+		{"wikipedia.html", "missing", ""},
+		{"godoc.html", "pkg-overview", "Overview"},
+		{"godoc.html", "Client", "type Client"},
+		{"godoc.html", "Client.Do", "func (*Client) Do"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.fixture+"#"+tt.fragment, func(t *testing.T) {
+			t.Parallel()
+			if got := Sections(loadFixture(t, tt.fixture))[tt.fragment]; got != tt.want {
+				t.Errorf("Sections()[%q] = '%v', want '%v'", tt.fragment, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://en.wikipedia.org/wiki/Go_(programming_language)#Concurrency", "https://en.wikipedia.org/wiki/Go_(programming_language)"},
+		{"https://pkg.go.dev/net/http#Client", "https://pkg.go.dev/net/http"},
+		{"HTTPS://Example.COM:443", "https://example.com/"},
+		{"http://example.com:80/a?b=c#d", "http://example.com/a?b=c"},
+		{"http://example.com:8080/", "http://example.com:8080/"},
+		{"not a url", "not a url"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.url, func(t *testing.T) {
+			t.Parallel()
+			if got := CacheKey(tt.url); got != tt.want {
+				t.Errorf("CacheKey() = '%v', want '%v'", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleRequestSection(t *testing.T) {
+	page, err := os.ReadFile("testdata/wikipedia.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, string(page))
+	}))
+	defer srv.Close()
+
+	t.Setenv("RUNMODE", "local")
+
+	tests := []struct {
+		fragment    string
+		want        string
+		wantSection string
+	}{
+		{"#Concurrency", "Go (programming language) - Wikipedia § Concurrency", "Concurrency"},
+		{"#K%C3%A4ytt%C3%B6", "Go (programming language) - Wikipedia § Käyttö", "Käyttö"},
+		{"#missing", "Go (programming language) - Wikipedia", ""},
+		{"", "Go (programming language) - Wikipedia", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fragment, func(t *testing.T) {
+			got, err := HandleRequest(context.Background(), TitleQuery{URL: srv.URL + "/wiki/Go" + tt.fragment})
+			if err != nil {
+				t.Fatalf("HandleRequest() error = %v", err)
+			}
+			if got.Title != tt.want {
+				t.Errorf("HandleRequest() title = '%v', want '%v'", got.Title, tt.want)
+			}
+			if got.Section != tt.wantSection {
+				t.Errorf("HandleRequest() section = '%v', want '%v'", got.Section, tt.wantSection)
+			}
+		})
+	}
+}
+
+func TestDecorate(t *testing.T) {
+	c := DefaultConfig()
+	c.Channels = map[string]ChannelConfig{"#reading": {ReadingTime: true}}
+	SetConfig(c)
+	t.Cleanup(func() { SetConfig(DefaultConfig()) })
+
+	long := strings.Repeat("word ", TitleMax/5)
+	tests := []struct {
+		name       string
+		query      TitleQuery
+		wantSuffix string
+	}{
+		{"Reading time", TitleQuery{Channel: "#reading", URL: "https://example.com/a", Title: long, ReadingTime: 8}, " [8 min read]"},
+		{"Destination", TitleQuery{URL: "https://bit.ly/a", FinalURL: "https://example.com/a", Title: long}, " → example.com"},
+		{"Section", TitleQuery{URL: "https://example.com/a#s", Title: long, Sections: map[string]string{"s": "Section"}}, " § Section"},
+		{"Long section", TitleQuery{URL: "https://example.com/a#s", Title: long, Sections: map[string]string{"s": strings.Repeat("heading ", TitleMax/8)}}, "..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := decorate(context.Background(), tt.query)
+			if n := uniseg.GraphemeClusterCount(got.Title); n > TitleMax {
+				t.Errorf("decorate() title is %d long, want at most %d: %q", n, TitleMax, got.Title)
+			}
+			if !strings.HasSuffix(got.Title, tt.wantSuffix) {
+				t.Errorf("decorate() = %q, want suffix %q", got.Title, tt.wantSuffix)
+			}
+			if !strings.HasPrefix(got.Title, "word word") {
+				t.Errorf("decorate() = %q, the title is gone", got.Title)
+			}
+			if got.BaseTitle != tt.query.Title {
+				t.Errorf("decorate() base title = %q, want %q", got.BaseTitle, tt.query.Title)
+			}
+		})
+	}
+}
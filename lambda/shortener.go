@@ -0,0 +1,130 @@
+package lambda
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/lepinkainen/titleparser/common"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// isShortener checks if the URL is on one of the configured URL shortener domains
+func isShortener(rawurl string) bool {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return false
+	}
+	return matchesDomain(strings.ToLower(u.Hostname()), activeConfig.Shorteners)
+}
+
+// ExpandURL follows the redirects of URL shorteners until the URL leaves the
+// shortener domains, without fetching the destination itself. Returns the
+// expanded URL and the URLs redirected to on the way.
+func ExpandURL(ctx context.Context, rawurl string) (string, []string, error) {
+	origin, err := url.Parse(rawurl)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "Could not parse URL")
+	}
+
+	// Every hop is checked separately
+	client := &http.Client{
+		Timeout:   time.Second * 10,
+		Transport: common.Transport(origin.Hostname()),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	current := origin
+	var redirects []string
+	for isShortener(current.String()) {
+		next, err := nextHop(ctx, client, current)
+		if err != nil {
+			return "", redirects, err
+		}
+		if next == nil {
+			break
+		}
+		if len(redirects) >= maxRedirects {
+			return "", redirects, ErrTooManyRedirects
+		}
+		if err := checkRedirect(ctx, origin, next); err != nil {
+			return "", redirects, err
+		}
+
+		log.Infof("Expanded %s to %s", current, next)
+		redirects = append(redirects, next.String())
+		current = next
+	}
+
+	return current.String(), redirects, nil
+}
+
+// nextHop returns where the URL redirects to with a HTTP, meta refresh or
+// JavaScript redirect, nil if it doesn't redirect
+func nextHop(ctx context.Context, client *http.Client, current *url.URL) (*url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", current.String(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not create request")
+	}
+
+	req.Header.Set("User-Agent", common.UserAgent)
+	req.Header.Set("Accept-Language", common.AcceptLanguageFor(ctx))
+	req.Header.Set("Accept", common.Accept)
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not expand URL")
+	}
+	defer func() {
+		if cerr := res.Body.Close(); cerr != nil {
+			log.Warnf("Failed to close response body: %v", cerr)
+		}
+	}()
+
+	if res.StatusCode >= 300 && res.StatusCode < 400 {
+		location := res.Header.Get("Location")
+		if location == "" {
+			return nil, errors.Errorf("%d redirect without a location", res.StatusCode)
+		}
+		return current.Parse(location)
+	}
+
+	// t.co and friends serve browsers a HTML page that does the redirect
+	if res.StatusCode != 200 || !strings.HasPrefix(res.Header.Get("content-type"), "text/html") {
+		return nil, nil
+	}
+	doc, err := goquery.NewDocumentFromReader(res.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not load HTML")
+	}
+	if target, ok := htmlRedirect(doc); ok {
+		return current.Parse(target)
+	}
+
+	return nil, nil
+}
+
+// destinationMarker returns the " → example.com" added to titles of URLs
+// that redirect to another site, empty if the site stays the same
+func destinationMarker(original, final string) string {
+	from, err := url.Parse(original)
+	if err != nil || final == "" {
+		return ""
+	}
+	to, err := url.Parse(final)
+	if err != nil || to.Hostname() == "" {
+		return ""
+	}
+
+	if siteName(from.Hostname()) == siteName(to.Hostname()) {
+		return ""
+	}
+
+	return " → " + common.CleanText(strings.TrimPrefix(strings.ToLower(to.Hostname()), "www."))
+}
@@ -0,0 +1,97 @@
+package lambda
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestDestinationMarker(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		original string
+		final    string
+		want     string
+	}{
+		{"Shortener", "https://bit.ly/3abc", "https://www.example.com/article", " → example.com"},
+		{"Same site", "http://example.com/a", "https://www.example.com/a", ""},
+		{"Mobile site", "https://m.example.com/a", "https://example.com/a", ""},
+		{"No redirect", "https://example.com/a", "", ""},
+		{"Subdomain", "https://t.co/x", "https://news.example.com/", " → news.example.com"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := destinationMarker(tt.original, tt.final); got != tt.want {
+				t.Errorf("destinationMarker() = '%v', want '%v'", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleRequestShortener(t *testing.T) {
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/moved" {
+			http.Redirect(w, r, "/article", http.StatusMovedPermanently)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<html><head><title>Destination article</title></head></html>`)
+	}))
+	defer destination.Close()
+	// same server, different host name so it counts as another site
+	target := strings.Replace(destination.URL, "127.0.0.1", "localhost", 1)
+
+	shortener := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/abc":
+			http.Redirect(w, r, "/interstitial", http.StatusMovedPermanently)
+		case "/interstitial":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprintf(w, `<html><head><meta http-equiv="refresh" content="0;URL=%s/moved"></head></html>`, target)
+		}
+	}))
+	defer shortener.Close()
+
+	t.Setenv("RUNMODE", "local")
+	c := DefaultConfig()
+	c.Shorteners = []string{"127.0.0.1"}
+	c.Channels = map[string]ChannelConfig{"#nolocal": {Policy: Policy{Denied: []string{"localhost"}}}}
+	SetConfig(c)
+	t.Cleanup(func() { SetConfig(DefaultConfig()) })
+
+	got, err := HandleRequest(context.Background(), TitleQuery{URL: shortener.URL + "/abc"})
+	if err != nil {
+		t.Fatalf("HandleRequest() error = %v", err)
+	}
+	if want := "Destination article → localhost"; got.Title != want {
+		t.Errorf("HandleRequest() title = '%v', want '%v'", got.Title, want)
+	}
+	if want := target + "/article"; got.FinalURL != want {
+		t.Errorf("HandleRequest() final URL = '%v', want '%v'", got.FinalURL, want)
+	}
+	wantChain := []string{shortener.URL + "/interstitial", target + "/moved", target + "/article"}
+	if !reflect.DeepEqual(got.RedirectChain, wantChain) {
+		t.Errorf("HandleRequest() redirect chain = %v, want %v", got.RedirectChain, wantChain)
+	}
+
+	// The destination is checked against the channel policy too
+	if _, err := HandleRequest(context.Background(), TitleQuery{Channel: "#nolocal", URL: shortener.URL + "/abc"}); !errors.Is(err, ErrDenied) {
+		t.Errorf("HandleRequest() error = %v, want %v", err, ErrDenied)
+	}
+
+	c.Shorteners = []string{"127.0.0.1", "bit.ly"}
+	SetConfig(c)
+	if !isShortener("https://bit.ly/3abc") || isShortener("https://example.com/") {
+		t.Errorf("isShortener() doesn't follow configuration")
+	}
+}
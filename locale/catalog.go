@@ -0,0 +1,136 @@
+package locale
+
+// Message IDs are grouped by what they describe, counts get the number as %s
+
+var english = Catalog{
+	// counts
+	"views":       {One: "%s view", Other: "%s views"},
+	"subscribers": {One: "%s subscriber", Other: "%s subscribers"},
+	"videos":      {One: "%s video", Other: "%s videos"},
+	"points":      {One: "%s point", Other: "%s points"},
+	"pts":         {One: "%s pt", Other: "%s pts"},
+	"comments":    {One: "%s comment", Other: "%s comments"},
+	"boosts":      {One: "%s boost", Other: "%s boosts"},
+	"favs":        {One: "%s fav", Other: "%s favs"},
+	"replies":     {One: "%s reply", Other: "%s replies"},
+	"images":      {One: "%s image", Other: "%s images"},
+	"readingtime": {Other: "%s min read"},
+
+	// Mastodon attachments, a single one is shown without the count
+	"media":       {Other: "Media: %s"},
+	"media.image": {One: "image", Other: "%s images"},
+	"media.video": {One: "video", Other: "%s videos"},
+	"media.gifv":  {One: "gifv", Other: "%s gifvs"},
+	"media.audio": {One: "audio", Other: "%s audios"},
+	// types we don't have a name for, Mastodon has "unknown"
+	"media.other": {One: "attachment", Other: "%s attachments"},
+
+	// labels, "by" gets the title and the author
+	"by":            {Other: "%s by %s"},
+	"agerestricted": {Other: "age restricted"},
+	"channel":       {Other: "Channel"},
+	"created":       {Other: "created %s"},
+	"tags":          {Other: "tags: %s"},
+	"duration":      {Other: "Duration: %s"},
+	"released":      {Other: "Released: %s"},
+	"series":        {Other: "Series: %s"},
+	"episode":       {Other: "Episode %s"},
+	"published":     {Other: "published %s"},
+
+	// product availability
+	"stock.in":        {Other: "in stock"},
+	"stock.storeonly": {Other: "in store only"},
+	"stock.limited":   {Other: "limited stock"},
+	"stock.out":       {Other: "out of stock"},
+	"stock.soldout":   {Other: "sold out"},
+	"stock.preorder":  {Other: "preorder"},
+	"stock.backorder": {Other: "backorder"},
+	"stock.discont":   {Other: "discontinued"},
+
+	// durations
+	"duration.hour":   {Other: "%sh"},
+	"duration.minute": {Other: "%smin"},
+	"duration.second": {Other: "%ss"},
+
+	// relative times
+	"reltime.now":    {Other: "now"},
+	"ago.second":     {One: "%s second ago", Other: "%s seconds ago"},
+	"ago.minute":     {One: "%s minute ago", Other: "%s minutes ago"},
+	"ago.hour":       {One: "%s hour ago", Other: "%s hours ago"},
+	"ago.day":        {One: "%s day ago", Other: "%s days ago"},
+	"ago.week":       {One: "%s week ago", Other: "%s weeks ago"},
+	"ago.month":      {One: "%s month ago", Other: "%s months ago"},
+	"ago.year":       {One: "%s year ago", Other: "%s years ago"},
+	"ago.long":       {Other: "a long while ago"},
+	"fromnow.second": {One: "%s second from now", Other: "%s seconds from now"},
+	"fromnow.minute": {One: "%s minute from now", Other: "%s minutes from now"},
+	"fromnow.hour":   {One: "%s hour from now", Other: "%s hours from now"},
+	"fromnow.day":    {One: "%s day from now", Other: "%s days from now"},
+	"fromnow.week":   {One: "%s week from now", Other: "%s weeks from now"},
+	"fromnow.month":  {One: "%s month from now", Other: "%s months from now"},
+	"fromnow.year":   {One: "%s year from now", Other: "%s years from now"},
+	"fromnow.long":   {Other: "a long while from now"},
+}
+
+var finnish = Catalog{
+	"views":       {One: "%s katselu", Other: "%s katselua"},
+	"subscribers": {One: "%s tilaaja", Other: "%s tilaajaa"},
+	"videos":      {One: "%s video", Other: "%s videota"},
+	"points":      {One: "%s piste", Other: "%s pistettä"},
+	"pts":         {Other: "%s p."},
+	"comments":    {One: "%s kommentti", Other: "%s kommenttia"},
+	"boosts":      {One: "%s tehostus", Other: "%s tehostusta"},
+	"favs":        {One: "%s tykkäys", Other: "%s tykkäystä"},
+	"replies":     {One: "%s vastaus", Other: "%s vastausta"},
+	"images":      {One: "%s kuva", Other: "%s kuvaa"},
+	"readingtime": {Other: "lukuaika %s min"},
+
+	"media":       {Other: "Media: %s"},
+	"media.image": {One: "kuva", Other: "%s kuvaa"},
+	"media.video": {One: "video", Other: "%s videota"},
+	"media.gifv":  {One: "GIF", Other: "%s GIFiä"},
+	"media.audio": {One: "ääni", Other: "%s ääntä"},
+	"media.other": {One: "liite", Other: "%s liitettä"},
+
+	"by":            {Other: "%s – %s"},
+	"agerestricted": {Other: "ikäraja"},
+	"channel":       {Other: "Kanava"},
+	"created":       {Other: "luotu %s"},
+	"tags":          {Other: "tagit: %s"},
+	"duration":      {Other: "Kesto: %s"},
+	"released":      {Other: "Julkaistu: %s"},
+	"series":        {Other: "Sarja: %s"},
+	"episode":       {Other: "Jakso %s"},
+	"published":     {Other: "julkaistu %s"},
+
+	"stock.in":        {Other: "varastossa"},
+	"stock.storeonly": {Other: "vain myymälässä"},
+	"stock.limited":   {Other: "rajoitetusti saatavilla"},
+	"stock.out":       {Other: "loppu varastosta"},
+	"stock.soldout":   {Other: "loppuunmyyty"},
+	"stock.preorder":  {Other: "ennakkotilattavissa"},
+	"stock.backorder": {Other: "jälkitoimituksessa"},
+	"stock.discont":   {Other: "poistunut valikoimasta"},
+
+	"duration.hour":   {Other: "%s t"},
+	"duration.minute": {Other: "%s min"},
+	"duration.second": {Other: "%s s"},
+
+	"reltime.now":    {Other: "nyt"},
+	"ago.second":     {One: "%s sekunti sitten", Other: "%s sekuntia sitten"},
+	"ago.minute":     {One: "%s minuutti sitten", Other: "%s minuuttia sitten"},
+	"ago.hour":       {One: "%s tunti sitten", Other: "%s tuntia sitten"},
+	"ago.day":        {One: "%s päivä sitten", Other: "%s päivää sitten"},
+	"ago.week":       {One: "%s viikko sitten", Other: "%s viikkoa sitten"},
+	"ago.month":      {One: "%s kuukausi sitten", Other: "%s kuukautta sitten"},
+	"ago.year":       {One: "%s vuosi sitten", Other: "%s vuotta sitten"},
+	"ago.long":       {Other: "kauan sitten"},
+	"fromnow.second": {Other: "%s sekunnin päästä"},
+	"fromnow.minute": {Other: "%s minuutin päästä"},
+	"fromnow.hour":   {Other: "%s tunnin päästä"},
+	"fromnow.day":    {Other: "%s päivän päästä"},
+	"fromnow.week":   {Other: "%s viikon päästä"},
+	"fromnow.month":  {Other: "%s kuukauden päästä"},
+	"fromnow.year":   {Other: "%s vuoden päästä"},
+	"fromnow.long":   {Other: "kaukana tulevaisuudessa"},
+}
@@ -0,0 +1,223 @@
+// Package locale renders the human readable parts of titles, like view
+// counts and relative times, in the language picked for the request
+package locale
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message is a translated string with the plural forms it needs.
+// English and Finnish only have "one" and "other", One falls back to Other.
+type Message struct {
+	One   string
+	Other string
+}
+
+// Catalog maps message IDs to translations
+type Catalog map[string]Message
+
+// Locale renders messages from a catalog
+type Locale struct {
+	// Tag is the language tag of the catalog, e.g. "fi"
+	Tag string
+	// Decimal is the decimal separator for compact numbers
+	Decimal string
+	// Suffixes for thousands, millions, billions and trillions
+	Suffixes []string
+
+	catalog Catalog
+}
+
+var (
+	// English is the default locale, and the fallback for missing translations
+	English = &Locale{Tag: "en", Decimal: ".", Suffixes: []string{"", "k", "M", "B", "T"}, catalog: english}
+	// Finnish locale
+	Finnish = &Locale{Tag: "fi", Decimal: ",", Suffixes: []string{"", " t.", " milj.", " mrd.", " bilj."}, catalog: finnish}
+
+	locales = map[string]*Locale{
+		"en": English,
+		"fi": Finnish,
+	}
+)
+
+// Get returns the locale for a language tag, "fi-FI" and "FI" both get Finnish
+func Get(tag string) (*Locale, bool) {
+	base, _, _ := strings.Cut(strings.ReplaceAll(tag, "_", "-"), "-")
+	l, ok := locales[strings.ToLower(strings.TrimSpace(base))]
+	return l, ok
+}
+
+// Select returns the first locale from the candidates that has a catalog,
+// English if none of them do
+func Select(candidates ...string) *Locale {
+	for _, tag := range candidates {
+		if l, ok := Get(tag); ok {
+			return l
+		}
+	}
+	return English
+}
+
+// Supported returns the tags of all locales with a catalog
+func Supported() []string {
+	return []string{English.Tag, Finnish.Tag}
+}
+
+type localeKey struct{}
+
+// With stores the locale of the request in the context
+func With(ctx context.Context, l *Locale) context.Context {
+	return context.WithValue(ctx, localeKey{}, l)
+}
+
+// From returns the locale of the request, English if none was set
+func From(ctx context.Context) *Locale {
+	if l, ok := ctx.Value(localeKey{}).(*Locale); ok && l != nil {
+		return l
+	}
+	return English
+}
+
+// lookup finds the message, falling back to English and finally the ID itself
+func (l *Locale) lookup(id string) Message {
+	if m, ok := l.catalog[id]; ok {
+		return m
+	}
+	if m, ok := english[id]; ok {
+		return m
+	}
+	return Message{Other: id}
+}
+
+// Has reports whether there's a message for the ID in any catalog
+func (l *Locale) Has(id string) bool {
+	_, ok := l.catalog[id]
+	_, fallback := english[id]
+	return ok || fallback
+}
+
+// Message formats a message that has no count
+func (l *Locale) Message(id string, args ...interface{}) string {
+	return fmt.Sprintf(l.lookup(id).Other, args...)
+}
+
+// plural picks the plural form for n
+func (l *Locale) plural(id string, n int) string {
+	m := l.lookup(id)
+	if n == 1 && m.One != "" {
+		return m.One
+	}
+	return m.Other
+}
+
+// Count formats a message with an exact count: "15 comments", "15 kommenttia"
+func (l *Locale) Count(id string, n int) string {
+	return fmt.Sprintf(l.plural(id, n), strconv.Itoa(n))
+}
+
+// CompactCount formats a message with a rounded count: "1.2M views", "1,2 milj. katselua"
+func (l *Locale) CompactCount(id string, n int) string {
+	return fmt.Sprintf(l.plural(id, n), l.Number(n))
+}
+
+// Number shortens large numbers: 1234567 is "1.2M" in English and "1,2 milj." in Finnish
+func (l *Locale) Number(n int) string {
+	thousands := math.Min(float64(len(l.Suffixes)-1), math.Floor(math.Log10(math.Abs(float64(n)))/3.0))
+	idx := int(math.Max(0, thousands))
+	value := float64(n) / math.Pow10(3*idx)
+
+	// one decimal for small values, "1.2M" says more than "1M"
+	s := strconv.FormatFloat(value, 'f', 0, 64)
+	if idx > 0 && math.Abs(value) < 10 {
+		s = strings.TrimSuffix(strconv.FormatFloat(value, 'f', 1, 64), ".0")
+		s = strings.Replace(s, ".", l.Decimal, 1)
+	}
+
+	return s + l.Suffixes[idx]
+}
+
+const (
+	day   = 24 * time.Hour
+	week  = 7 * day
+	month = 30 * day
+	year  = 12 * month
+	// longTime is when we stop counting years
+	longTime = 37 * year
+)
+
+// relUnits are the steps for relative times, the same as go-humanize uses
+var relUnits = []struct {
+	below time.Duration
+	unit  string
+	size  time.Duration
+}{
+	{time.Minute, "second", time.Second},
+	{time.Hour, "minute", time.Minute},
+	{day, "hour", time.Hour},
+	{week, "day", day},
+	{month, "week", week},
+	{year, "month", month},
+	{longTime, "year", year},
+}
+
+// Duration formats a length of a video or such with the two largest units:
+// "2h 12min", "5min 54s", "2 t 12 min"
+func (l *Locale) Duration(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d < time.Second {
+		return l.Count("duration.second", 0)
+	}
+
+	units := []struct {
+		id string
+		n  int
+	}{
+		{"duration.hour", int(d / time.Hour)},
+		{"duration.minute", int(d % time.Hour / time.Minute)},
+		{"duration.second", int(d % time.Minute / time.Second)},
+	}
+	for len(units) > 0 && units[0].n == 0 {
+		units = units[1:]
+	}
+
+	var parts []string
+	for _, u := range units[:min(2, len(units))] {
+		if u.n > 0 {
+			parts = append(parts, l.Count(u.id, u.n))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// RelTime formats the time relative to now: "3 days ago", "3 päivää sitten"
+func (l *Locale) RelTime(then, now time.Time) string {
+	direction := "ago"
+	diff := now.Sub(then)
+	if diff < 0 {
+		direction = "fromnow"
+		diff = -diff
+	}
+
+	if diff < time.Second {
+		return l.Message("reltime.now")
+	}
+
+	for _, u := range relUnits {
+		if diff >= u.below {
+			continue
+		}
+		n := int(diff / u.size)
+		// 18 months is closer to two years than one
+		if u.unit == "year" && diff >= 18*month && n < 2 {
+			n = 2
+		}
+		return l.Count(direction+"."+u.unit, n)
+	}
+
+	return l.Message(direction + ".long")
+}
@@ -0,0 +1,191 @@
+package locale
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNumber(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		locale *Locale
+		n      int
+		want   string
+	}{
+		{"Small", English, 999, "999"},
+		{"Zero", English, 0, "0"},
+		{"Thousands", English, 1000, "1k"},
+		{"Decimal", English, 1234567, "1.2M"},
+		{"No decimal over ten", English, 12345678, "12M"},
+		{"Billions", English, 1000000000, "1B"},
+		{"Finnish thousands", Finnish, 4500, "4,5 t."},
+		{"Finnish millions", Finnish, 1234567, "1,2 milj."},
+		{"Finnish billions", Finnish, 300000000000, "300 mrd."},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.locale.Number(tt.n); got != tt.want {
+				t.Errorf("Number() = '%v', want '%v'", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCount(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		locale  *Locale
+		compact bool
+		id      string
+		n       int
+		want    string
+	}{
+		{"English plural", English, false, "comments", 15, "15 comments"},
+		{"English singular", English, false, "comments", 1, "1 comment"},
+		{"Finnish plural", Finnish, false, "comments", 15, "15 kommenttia"},
+		{"Finnish singular", Finnish, false, "comments", 1, "1 kommentti"},
+		{"English compact", English, true, "views", 1234567, "1.2M views"},
+		{"Finnish compact", Finnish, true, "views", 1234567, "1,2 milj. katselua"},
+		{"Missing plural form", Finnish, false, "pts", 1, "1 p."},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := tt.locale.Count(tt.id, tt.n)
+			if tt.compact {
+				got = tt.locale.CompactCount(tt.id, tt.n)
+			}
+			if got != tt.want {
+				t.Errorf("Count() = '%v', want '%v'", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRelTime(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		locale *Locale
+		then   time.Time
+		want   string
+	}{
+		{"Now", English, now, "now"},
+		{"Seconds", English, now.Add(-30 * time.Second), "30 seconds ago"},
+		{"One hour", English, now.Add(-90 * time.Minute), "1 hour ago"},
+		{"Days", English, now.Add(-3 * day), "3 days ago"},
+		{"Eighteen months", English, now.Add(-19 * month), "2 years ago"},
+		{"Future", English, now.Add(2 * week), "2 weeks from now"},
+		{"Long time", English, now.Add(-40 * year), "a long while ago"},
+		{"Finnish now", Finnish, now, "nyt"},
+		{"Finnish days", Finnish, now.Add(-3 * day), "3 päivää sitten"},
+		{"Finnish one day", Finnish, now.Add(-30 * time.Hour), "1 päivä sitten"},
+		{"Finnish years", Finnish, now.Add(-4 * year), "4 vuotta sitten"},
+		{"Finnish future", Finnish, now.Add(3 * day), "3 päivän päästä"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.locale.RelTime(tt.then, now); got != tt.want {
+				t.Errorf("RelTime() = '%v', want '%v'", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		candidates []string
+		want       *Locale
+	}{
+		{"Nothing", nil, English},
+		{"Empty", []string{"", ""}, English},
+		{"Finnish", []string{"fi"}, Finnish},
+		{"Region", []string{"fi-FI"}, Finnish},
+		{"First supported", []string{"sv", "FI_fi", "en"}, Finnish},
+		{"Unsupported", []string{"sv"}, English},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := Select(tt.candidates...); got != tt.want {
+				t.Errorf("Select() = %v, want %v", got.Tag, tt.want.Tag)
+			}
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	t.Parallel()
+
+	if got := From(context.Background()); got != English {
+		t.Errorf("From() = %v, want en", got.Tag)
+	}
+	if got := From(With(context.Background(), Finnish)); got != Finnish {
+		t.Errorf("From() = %v, want fi", got.Tag)
+	}
+}
+
+// Every message needs a translation, a missing one would show up in English
+func TestCatalogsComplete(t *testing.T) {
+	t.Parallel()
+
+	for _, tag := range Supported() {
+		l, _ := Get(tag)
+		for id := range english {
+			if _, ok := l.catalog[id]; !ok {
+				t.Errorf("%s catalog is missing %q", tag, id)
+			}
+		}
+		for id, m := range l.catalog {
+			if _, ok := english[id]; !ok {
+				t.Errorf("%s catalog has %q, which is not in the English catalog", tag, id)
+			}
+			if m.Other == "" {
+				t.Errorf("%s catalog has no text for %q", tag, id)
+			}
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		locale *Locale
+		d      time.Duration
+		want   string
+	}{
+		{"Hours and minutes", English, 2*time.Hour + 12*time.Minute + 5*time.Second, "2h 12min"},
+		{"Minutes and seconds", English, 5*time.Minute + 54*time.Second, "5min 54s"},
+		{"Whole hours", English, time.Hour, "1h"},
+		{"Seconds", English, 42 * time.Second, "42s"},
+		{"Zero", English, 0, "0s"},
+		{"Finnish", Finnish, 2*time.Hour + 12*time.Minute, "2 t 12 min"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.locale.Duration(tt.d); got != tt.want {
+				t.Errorf("Duration() = '%v', want '%v'", got, tt.want)
+			}
+		})
+	}
+}
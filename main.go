@@ -3,114 +3,67 @@ package main
 import (
 	// fake import for handlers to run their init() functions
 	"context"
-	"encoding/json"
-	"fmt"
-	"html"
-	"net/http"
 	"os"
-	"time"
+	"os/signal"
+	"syscall"
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/lepinkainen/titleparser/cli"
 	_ "github.com/lepinkainen/titleparser/handler"
 	"github.com/lepinkainen/titleparser/lambda"
+	"github.com/lepinkainen/titleparser/rules"
+	"github.com/lepinkainen/titleparser/server"
 
 	awslambda "github.com/aws/aws-lambda-go/lambda"
 )
 
 func main() {
-
-	// TODO: API-key for security in local mode
-	// TODO: Make port configurable
-
 	var runmode = os.Getenv("RUNMODE")
-	if runmode != "local" && runmode != "stdin" {
-		awslambda.Start(lambda.HandleRequest)
-		os.Exit(0)
+	inLambda := os.Getenv("AWS_LAMBDA_RUNTIME_API") != "" || runmode == "lambda"
+	if !inLambda {
+		// stdout is only for command output
+		log.SetOutput(os.Stderr)
 	}
 
-	if runmode == "stdin" {
-		fmt.Println("Running in stdin mode")
-
-		decoder := json.NewDecoder(os.Stdin)
-		var query lambda.TitleQuery
-		err := decoder.Decode(&query)
-		if err != nil {
-			log.Errorf("Error decoding JSON from stdin: %v", err)
-			os.Exit(1)
+	if configFile := os.Getenv("CONFIG_FILE"); configFile != "" {
+		if err := lambda.LoadConfig(configFile); err != nil {
+			log.Errorf("Error loading config from %s: %v", configFile, err)
 		}
+	}
 
-		res, err := lambda.HandleRequest(context.Background(), query)
-		if err != nil {
-			log.Errorf("Error handling request: %v", err)
-			os.Exit(1)
+	// Site rules outside the bundled ones, can be changed without a new release
+	if rulesFile := os.Getenv("RULES_FILE"); rulesFile != "" {
+		if err := rules.LoadFile(rulesFile); err != nil {
+			log.Errorf("Error loading rules from %s: %v", rulesFile, err)
 		}
+	}
 
-		output, err := json.Marshal(res)
+	if inLambda {
+		// Same API key and limits as the local server for HTTP events
+		config, err := server.ConfigFromEnv()
 		if err != nil {
-			log.Errorf("Error marshaling response JSON: %v", err)
-			os.Exit(1)
+			log.Fatalf("Invalid server configuration: %v", err)
 		}
-
-		fmt.Println(string(output))
+		awslambda.Start(server.New(config, lambda.HandleRequest, lambda.HandleBatch).LambdaHandler())
 		os.Exit(0)
 	}
 
-	fmt.Println("Running in local mode")
-
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		if _, err := fmt.Fprintf(w, "Hello, %q", html.EscapeString(r.URL.Path)); err != nil {
-			log.Warnf("Failed to write response: %v", err)
+	// RUNMODE from before the subcommands
+	args := os.Args[1:]
+	if len(args) == 0 {
+		switch runmode {
+		case "local":
+			args = []string{"serve"}
+		case "stdin":
+			args = []string{"stream"}
+		case "irc":
+			args = []string{"irc"}
 		}
-	})
-
-	http.HandleFunc("/hi", func(w http.ResponseWriter, r *http.Request) {
-		if _, err := fmt.Fprintf(w, "Hi"); err != nil {
-			log.Warnf("Failed to write response: %v", err)
-		}
-	})
-
-	/*
-		TODO: Register custom handlers
-		TODO: Fall back to default if no custom handler matches url
-		TODO: JSON Protocol for communication, body payload w/ apikey
-	*/
-
-	// Local mode calls like this with httpie:
-	// http http://localhost:8081/title url="http://mantta.fi"
-
-	http.HandleFunc("/title", func(w http.ResponseWriter, r *http.Request) {
-
-		decoder := json.NewDecoder(r.Body)
-		var query lambda.TitleQuery
-		err := decoder.Decode(&query)
-		if err != nil {
-			log.Errorln("No URL given")
-			return
-		}
-
-		res, err := lambda.HandleRequest(context.Background(), query)
-		if err != nil {
-			_ = fmt.Errorf("error handling request: %w", err)
-		}
-
-		q, err := json.Marshal(res)
-		if err != nil {
-			_ = fmt.Errorf("error marshaling response JSON: %w", err)
-		}
-
-		if _, err := fmt.Fprint(w, string(q)); err != nil {
-			log.Warnf("Failed to write response: %v", err)
-		}
-	})
-
-	srv := &http.Server{
-		Addr:              "127.0.0.1:8081",
-		ReadHeaderTimeout: 10 * time.Second,
-		ReadTimeout:       30 * time.Second,
-		WriteTimeout:      30 * time.Second,
-		IdleTimeout:       60 * time.Second,
 	}
-	log.Fatal(srv.ListenAndServe())
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := cli.Run(ctx, args, os.Stdin, os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
 }
@@ -0,0 +1,284 @@
+package oembed
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/lepinkainen/titleparser/common"
+	"github.com/lepinkainen/titleparser/locale"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/publicsuffix"
+)
+
+// oEmbed support, see https://oembed.com
+//
+// Endpoints are found either from the bundled provider list or from a
+// <link rel="alternate" type="application/json+oembed"> in the page itself.
+// The bundled list uses the format of https://oembed.com/providers.json and
+// is refreshed with `task update-oembed-providers`, nothing is fetched at runtime.
+
+//go:embed providers.json
+var bundledProviders []byte
+
+// ErrNoTitle is returned when the oEmbed response doesn't have a title
+var ErrNoTitle = errors.New("No title in oEmbed response")
+
+// Provider is a single entry in the providers list
+type Provider struct {
+	Name      string     `json:"provider_name"`
+	URL       string     `json:"provider_url"`
+	Endpoints []Endpoint `json:"endpoints"`
+}
+
+// Endpoint is an oEmbed API endpoint and the URL schemes it handles
+type Endpoint struct {
+	Schemes   []string `json:"schemes"`
+	URL       string   `json:"url"`
+	Discovery bool     `json:"discovery"`
+}
+
+// Response is the part of the oEmbed response we care about
+type Response struct {
+	Type         string `json:"type"`
+	Version      string `json:"version"`
+	Title        string `json:"title"`
+	AuthorName   string `json:"author_name"`
+	AuthorURL    string `json:"author_url"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+}
+
+// Registry matches URLs to oEmbed endpoints
+type Registry struct {
+	matchers []matcher
+	// hosts are the hosts of the provider endpoints
+	hosts map[string]bool
+}
+
+type matcher struct {
+	scheme   *regexp.Regexp
+	endpoint string
+}
+
+var defaultRegistry *Registry
+
+// NewRegistry compiles the URL schemes of the given providers
+func NewRegistry(providers []Provider) (*Registry, error) {
+	r := &Registry{hosts: map[string]bool{}}
+
+	for _, provider := range providers {
+		for _, endpoint := range provider.Endpoints {
+			// Some providers only support discovery
+			if endpoint.URL == "" {
+				continue
+			}
+			if u, err := url.Parse(endpoint.URL); err == nil {
+				r.hosts[strings.ToLower(u.Hostname())] = true
+			}
+			for _, scheme := range endpoint.Schemes {
+				re, err := schemeRegexp(scheme)
+				if err != nil {
+					return nil, errors.Wrapf(err, "provider %s: invalid scheme %s", provider.Name, scheme)
+				}
+				r.matchers = append(r.matchers, matcher{
+					scheme:   re,
+					endpoint: strings.ReplaceAll(endpoint.URL, "{format}", "json"),
+				})
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// ParseRegistry reads a providers list in the oembed.com providers.json format
+func ParseRegistry(data []byte) (*Registry, error) {
+	var providers []Provider
+	if err := json.Unmarshal(data, &providers); err != nil {
+		return nil, errors.Wrap(err, "Could not parse oEmbed providers")
+	}
+
+	return NewRegistry(providers)
+}
+
+// schemeRegexp turns an oEmbed URL scheme with * wildcards into a regexp
+func schemeRegexp(scheme string) (*regexp.Regexp, error) {
+	parts := strings.Split(scheme, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+
+	return regexp.Compile("^" + strings.Join(parts, ".*") + "$")
+}
+
+// Lookup returns the oEmbed request URL for pageURL if a provider handles it
+func (r *Registry) Lookup(pageURL string) (string, bool) {
+	for _, m := range r.matchers {
+		if !m.scheme.MatchString(pageURL) {
+			continue
+		}
+
+		endpoint, err := url.Parse(m.endpoint)
+		if err != nil {
+			log.Warnf("Invalid oEmbed endpoint %s: %v", m.endpoint, err)
+			return "", false
+		}
+		q := endpoint.Query()
+		q.Set("url", pageURL)
+		q.Set("format", "json")
+		endpoint.RawQuery = q.Encode()
+
+		return endpoint.String(), true
+	}
+
+	return "", false
+}
+
+// Lookup finds the oEmbed request URL for pageURL from the bundled providers
+func Lookup(pageURL string) (string, bool) {
+	if defaultRegistry == nil {
+		return "", false
+	}
+	return defaultRegistry.Lookup(pageURL)
+}
+
+// Known is true if the URL is on the endpoint host of a provider in the registry
+func (r *Registry) Known(endpointURL string) bool {
+	u, err := url.Parse(endpointURL)
+	if err != nil {
+		return false
+	}
+	return r.hosts[strings.ToLower(u.Hostname())]
+}
+
+// Discover returns the JSON oEmbed URL the page links to, if any. Only
+// endpoints on the page's own site or of a bundled provider are returned,
+// the page could point anywhere.
+func Discover(doc *goquery.Document, pageURL string) (string, bool) {
+	href, ok := doc.Find(`link[rel="alternate"][type="application/json+oembed"]`).First().Attr("href")
+	if !ok || href == "" {
+		return "", false
+	}
+
+	// Resolve relative links against the page
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return "", false
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return "", false
+	}
+
+	endpoint := base.ResolveReference(ref)
+	if !sameSite(base, endpoint) && (defaultRegistry == nil || !defaultRegistry.Known(endpoint.String())) {
+		log.Debugf("Ignoring oEmbed endpoint %s of %s, not on the same site or a known provider", endpoint.Host, pageURL)
+		return "", false
+	}
+
+	return endpoint.String(), true
+}
+
+// sameSite is true if the URLs have the same host or registrable domain,
+// e.g. www.example.com and api.example.com
+func sameSite(a, b *url.URL) bool {
+	hostA, hostB := strings.ToLower(a.Hostname()), strings.ToLower(b.Hostname())
+	if hostA == hostB {
+		return true
+	}
+	// Addresses have no registrable domain
+	if net.ParseIP(hostA) != nil || net.ParseIP(hostB) != nil {
+		return false
+	}
+
+	siteA, err := publicsuffix.EffectiveTLDPlusOne(hostA)
+	if err != nil {
+		return false
+	}
+	siteB, err := publicsuffix.EffectiveTLDPlusOne(hostB)
+	if err != nil {
+		return false
+	}
+	return siteA == siteB
+}
+
+// Fetch requests an oEmbed URL as returned by Lookup or Discover. The client
+// decides where redirects may go, nil uses one that only connects to public
+// addresses unless the endpoint itself is internal.
+func Fetch(ctx context.Context, client *http.Client, oembedURL string) (*Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", oembedURL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating request")
+	}
+
+	req.Header.Set("User-Agent", common.UserAgent)
+	req.Header.Set("Accept-Language", common.AcceptLanguageFor(ctx))
+	req.Header.Set("Accept", "application/json")
+
+	if client == nil {
+		client = &http.Client{Timeout: time.Second * 10, Transport: common.Transport(req.URL.Hostname())}
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "error sending request")
+	}
+	defer func() {
+		if cerr := res.Body.Close(); cerr != nil {
+			log.Warnf("Failed to close response body: %v", cerr)
+		}
+	}()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("oEmbed endpoint returned non-OK status: %d", res.StatusCode)
+	}
+
+	// oEmbed responses are small, anything bigger is not what we're looking for
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading response body")
+	}
+
+	var response Response
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, errors.Wrap(err, "error parsing JSON")
+	}
+
+	return &response, nil
+}
+
+// Format formats the response as "Title by Author [Provider]" in the locale, leaving out missing parts
+func (r *Response) Format(loc *locale.Locale) (string, error) {
+	title := strings.TrimSpace(r.Title)
+	if title == "" {
+		return "", ErrNoTitle
+	}
+
+	if r.AuthorName != "" && r.AuthorName != title {
+		title = loc.Message("by", title, strings.TrimSpace(r.AuthorName))
+	}
+	if r.ProviderName != "" {
+		title = fmt.Sprintf("%s [%s]", title, strings.TrimSpace(r.ProviderName))
+	}
+
+	return title, nil
+}
+
+func init() {
+	var err error
+	defaultRegistry, err = ParseRegistry(bundledProviders)
+	if err != nil {
+		log.Errorf("Could not load bundled oEmbed providers: %v", err)
+	}
+}
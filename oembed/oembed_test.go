@@ -0,0 +1,192 @@
+package oembed
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/lepinkainen/titleparser/locale"
+)
+
+// fixtureServer serves the recorded oEmbed responses in testdata by path
+func fixtureServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := os.ReadFile("testdata" + r.URL.Path)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestLookup(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		url          string
+		wantEndpoint string
+		wantOK       bool
+	}{
+		{"Vimeo", "https://vimeo.com/76979871", "https://vimeo.com/api/oembed.json", true},
+		{"Flickr", "https://www.flickr.com/photos/bees/2341623661/", "https://www.flickr.com/services/oembed/", true},
+		{"SoundCloud", "https://soundcloud.com/forss/flickermood", "https://soundcloud.com/oembed", true},
+		{"Spotify", "https://open.spotify.com/track/7tFiyTwD0nx5a1eklYtX2J", "https://open.spotify.com/oembed/", true},
+		{"TikTok", "https://www.tiktok.com/@scout2015/video/6718335390845095173", "https://www.tiktok.com/oembed", true},
+		{"Tumblr", "https://staff.tumblr.com/post/123/hello", "https://www.tumblr.com/oembed/1.0", true},
+		{"Dailymotion", "https://www.dailymotion.com/video/x8abcd", "https://www.dailymotion.com/services/oembed", true},
+		{"Giphy", "https://giphy.com/gifs/cat-funny-abc123", "https://giphy.com/services/oembed", true},
+		{"Unknown site", "https://example.com/video/1", "", false},
+		{"Lookalike domain", "https://notvimeo.com/76979871", "", false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, ok := Lookup(tt.url)
+			if ok != tt.wantOK {
+				t.Fatalf("Lookup() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			u, err := url.Parse(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if endpoint := strings.TrimSuffix(got, "?"+u.RawQuery); endpoint != tt.wantEndpoint {
+				t.Errorf("Lookup() endpoint = %v, want %v", endpoint, tt.wantEndpoint)
+			}
+			if u.Query().Get("url") != tt.url || u.Query().Get("format") != "json" {
+				t.Errorf("Lookup() query = %v, want url and format=json", u.RawQuery)
+			}
+		})
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	t.Parallel()
+
+	f, err := os.Open("testdata/discovery.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok := Discover(doc, "https://example.com/video/1")
+	want := "https://example.com/oembed?format=json&url=https%3A%2F%2Fexample.com%2Fvideo%2F1"
+	if !ok || got != want {
+		t.Errorf("Discover() = %v, %v, want %v", got, ok, want)
+	}
+
+	tests := []struct {
+		name string
+		page string
+		href string
+		want bool
+	}{
+		{"Same host", "https://example.com/video/1", "/oembed", true},
+		{"Same site", "https://www.example.com/video/1", "https://api.example.com/oembed", true},
+		{"Known provider", "https://example.com/video/1", "https://vimeo.com/api/oembed.json", true},
+		{"Other site", "https://example.com/video/1", "https://example.org/oembed", false},
+		{"Internal address", "https://example.com/video/1", "http://169.254.169.254/latest/meta-data/", false},
+		{"Other address", "http://127.0.0.1:8080/video/1", "http://10.0.0.1/oembed", false},
+		{"Shared suffix", "https://someone.github.io/video/1", "https://other.github.io/oembed", false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(
+				`<html><head><link rel="alternate" type="application/json+oembed" href="` + tt.href + `"></head></html>`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := Discover(doc, tt.page); ok != tt.want {
+				t.Errorf("Discover() = %v, want %v", ok, tt.want)
+			}
+		})
+	}
+
+	empty, err := goquery.NewDocumentFromReader(strings.NewReader("<html><head><title>x</title></head></html>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := Discover(empty, "https://example.com/"); ok {
+		t.Errorf("Discover() = %v, want nothing", got)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	t.Parallel()
+
+	srv := fixtureServer(t)
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{"Vimeo video", "/vimeo.json", "The New Vimeo Player (You Know, For Videos) by Vimeo Staff [Vimeo]", false},
+		{"Spotify track, no author", "/spotify.json", "Bohemian Rhapsody - Remastered 2011 [Spotify]", false},
+		{"Not found", "/missing.json", "", true},
+		{"Not JSON", "/discovery.html", "", true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			res, err := Fetch(context.Background(), nil, srv.URL+tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Fetch() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			got, err := res.Format(locale.English)
+			if err != nil {
+				t.Fatalf("Format() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Format() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatLocale(t *testing.T) {
+	t.Parallel()
+
+	r := &Response{Title: "Video", AuthorName: "someone", ProviderName: "Example"}
+	if got, _ := r.Format(locale.Finnish); got != "Video – someone [Example]" {
+		t.Errorf("Format() = %v, want the Finnish author separator", got)
+	}
+}
+
+func TestFormatNoTitle(t *testing.T) {
+	t.Parallel()
+
+	r := &Response{AuthorName: "someone", ProviderName: "Example"}
+	if _, err := r.Format(locale.English); err != ErrNoTitle {
+		t.Errorf("Format() error = %v, want ErrNoTitle", err)
+	}
+}
@@ -0,0 +1,379 @@
+package rules
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/lepinkainen/titleparser/hydration"
+	"github.com/lepinkainen/titleparser/lambda"
+	"github.com/lepinkainen/titleparser/locale"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// Declarative site support: each rule is a URL pattern, a set of fields read
+// from the page with CSS selectors or meta properties and an output template.
+// Rules are compiled into normal handlers, so they're dispatched exactly like
+// the Go handlers in the handler package.
+
+//go:embed default.yaml
+var defaultRules []byte
+
+// File is the on-disk format of a rules file
+type File struct {
+	Rules []Rule `yaml:"rules" json:"rules"`
+}
+
+// Rule describes how to build a title for URLs matching Pattern
+type Rule struct {
+	Name string `yaml:"name" json:"name"`
+	// Description is shown in the handler catalog
+	Description string           `yaml:"description" json:"description"`
+	Pattern     string           `yaml:"pattern" json:"pattern"`
+	Fields      map[string]Field `yaml:"fields" json:"fields"`
+	// Template is a text/template rendered with the field values, defaults to {{.title}}
+	Template string    `yaml:"template" json:"template"`
+	Fixtures []Fixture `yaml:"fixtures" json:"fixtures"`
+}
+
+// Field tells where a single value is found in the page
+type Field struct {
+	// Selector is a CSS selector, the text of the first match is used unless Attr is set
+	Selector string `yaml:"selector" json:"selector"`
+	Attr     string `yaml:"attr" json:"attr"`
+	// Meta is a meta tag property or name, its content attribute is used
+	Meta string `yaml:"meta" json:"meta"`
+	// State is an embedded SPA state source like __NEXT_DATA__ or __APOLLO_STATE__,
+	// the value is read from it with the JSONPath-style Path
+	State string `yaml:"state" json:"state"`
+	Path  string `yaml:"path" json:"path"`
+	// Type is one of text (default), duration (seconds) or date
+	Type string `yaml:"type" json:"type"`
+}
+
+// Fixture is a saved HTML page and the title the rule should produce from it
+type Fixture struct {
+	URL  string `yaml:"url" json:"url"`
+	File string `yaml:"file" json:"file"`
+	Want string `yaml:"want" json:"want"`
+}
+
+// Compiled is a rule ready to be used as a handler
+type Compiled struct {
+	Rule     Rule
+	pattern  *regexp.Regexp
+	template *template.Template
+	// paths are the compiled state paths by field name
+	paths map[string]*hydration.Path
+	// states are the state blobs the paths read from
+	states []string
+}
+
+// Parse reads rules from YAML or JSON data
+func Parse(data []byte, format string) ([]Rule, error) {
+	var file File
+	var err error
+
+	switch format {
+	case "json":
+		err = json.Unmarshal(data, &file)
+	case "yaml", "yml":
+		err = yaml.Unmarshal(data, &file)
+	default:
+		return nil, fmt.Errorf("unknown rules format %q", format)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not parse rules")
+	}
+
+	return file.Rules, nil
+}
+
+// Load reads rules from a file, format is picked by the file extension
+func Load(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path comes from local configuration
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not read rules file")
+	}
+
+	return Parse(data, strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// LoadFile reads rules from a file and registers them as handlers. They're
+// tried before the built-in handlers and bundled rules, so a file rule
+// takes over the URLs it matches.
+func LoadFile(path string) error {
+	rules, err := Load(path)
+	if err != nil {
+		return err
+	}
+
+	return register(rules, true)
+}
+
+// Register compiles the given rules and adds them to the handler registry
+// Nothing is registered if any of the rules is invalid
+func Register(rules []Rule) error {
+	return register(rules, false)
+}
+
+// register adds the rules to the registry, overrides before the built-in handlers
+func register(rules []Rule, override bool) error {
+	compiled := make([]*Compiled, 0, len(rules))
+	for _, rule := range rules {
+		c, err := rule.Compile()
+		if err != nil {
+			return err
+		}
+		compiled = append(compiled, c)
+	}
+
+	for _, c := range compiled {
+		log.Debugf("Registering rule %s for %s", c.Rule.Name, c.Rule.Pattern)
+		lambda.Register(lambda.Handler{
+			Name:        "rules." + c.Rule.Name,
+			Description: c.Rule.Description,
+			Patterns:    []string{c.Rule.Pattern},
+			Examples:    c.Rule.examples(),
+			Func:        c.Handle,
+			Override:    override,
+		})
+	}
+
+	return nil
+}
+
+// examples are the fixture URLs of the rule
+func (r Rule) examples() []string {
+	var urls []string
+	for _, fixture := range r.Fixtures {
+		if fixture.URL != "" {
+			urls = append(urls, fixture.URL)
+		}
+	}
+	return urls
+}
+
+// Compile checks the rule for errors and prepares it for use
+func (r Rule) Compile() (*Compiled, error) {
+	if r.Name == "" {
+		return nil, fmt.Errorf("rule for pattern %q has no name", r.Pattern)
+	}
+
+	pattern, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return nil, errors.Wrapf(err, "rule %s: invalid pattern", r.Name)
+	}
+
+	if _, ok := r.Fields["title"]; !ok {
+		return nil, fmt.Errorf("rule %s: no title field", r.Name)
+	}
+
+	paths := make(map[string]*hydration.Path)
+	var states []string
+	for name, field := range r.Fields {
+		sources := 0
+		for _, source := range []string{field.Selector, field.Meta, field.State} {
+			if source != "" {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return nil, fmt.Errorf("rule %s: field %s needs exactly one of selector, meta or state", r.Name, name)
+		}
+		if field.State != "" {
+			path, err := hydration.Compile(field.Path)
+			if err != nil || field.Path == "" {
+				return nil, fmt.Errorf("rule %s: field %s needs a valid state path: %v", r.Name, name, err)
+			}
+			paths[name] = path
+			if !slices.Contains(states, field.State) {
+				states = append(states, field.State)
+			}
+		}
+		switch field.Type {
+		case "", "text", "duration", "date":
+		default:
+			return nil, fmt.Errorf("rule %s: field %s has unknown type %q", r.Name, name, field.Type)
+		}
+	}
+
+	text := r.Template
+	if text == "" {
+		text = "{{.title}}"
+	}
+	tmpl, err := template.New(r.Name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, errors.Wrapf(err, "rule %s: invalid template", r.Name)
+	}
+
+	return &Compiled{Rule: r, pattern: pattern, template: tmpl, paths: paths, states: states}, nil
+}
+
+// Handle is the handler function registered for the rule
+func (c *Compiled) Handle(ctx context.Context, url string) (string, error) {
+	doc, err := lambda.FetchDocument(ctx, url)
+	if err != nil {
+		return "", err
+	}
+
+	return c.Render(ctx, doc)
+}
+
+// Render builds the title from an already parsed document, dates are
+// formatted in the locale of the request
+func (c *Compiled) Render(ctx context.Context, doc *goquery.Document) (string, error) {
+	// Only the state blobs the fields read are decoded
+	var state hydration.State
+	if len(c.states) > 0 {
+		state = hydration.Extract(doc, c.states...)
+	}
+
+	loc := locale.From(ctx)
+	values := make(map[string]string, len(c.Rule.Fields))
+	for name, field := range c.Rule.Fields {
+		values[name] = field.extract(doc, state, c.paths[name], loc)
+	}
+
+	if values["title"] == "" {
+		return "", lambda.ErrTitleNotFound
+	}
+
+	var buf bytes.Buffer
+	if err := c.template.Execute(&buf, values); err != nil {
+		return "", errors.Wrapf(err, "rule %s: could not render template", c.Rule.Name)
+	}
+
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// extract reads and formats the field value from the document
+func (f Field) extract(doc *goquery.Document, state hydration.State, path *hydration.Path, loc *locale.Locale) string {
+	var value string
+
+	if f.State != "" {
+		if data, ok := state.Lookup(f.State, path); ok {
+			value = hydration.Text(data)
+		}
+	} else if f.Meta != "" {
+		s := doc.Find(fmt.Sprintf(`meta[property=%q], meta[name=%q]`, f.Meta, f.Meta)).First()
+		value, _ = s.Attr("content")
+	} else {
+		s := doc.Find(f.Selector).First()
+		if f.Attr != "" {
+			value, _ = s.Attr(f.Attr)
+		} else {
+			value = s.Text()
+		}
+	}
+
+	// collapse whitespace, CMSes love to indent their markup
+	value = strings.Join(strings.Fields(value), " ")
+	if value == "" {
+		return ""
+	}
+
+	switch f.Type {
+	case "duration":
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			log.Warnf("Could not parse duration %q: %v", value, err)
+			return ""
+		}
+		return loc.Duration(time.Duration(seconds * float64(time.Second)))
+	case "date":
+		t, err := lambda.ParseDate(value)
+		if err != nil {
+			log.Warnf("Could not parse date %q: %v", value, err)
+			return ""
+		}
+		return loc.RelTime(t, time.Now())
+	}
+
+	return value
+}
+
+// Validate compiles the rules and checks each one against its fixtures
+// Fixture files are resolved relative to baseDir
+// Returns one error for every problem found
+func Validate(rules []Rule, baseDir string) []error {
+	var problems []error
+
+	for _, rule := range rules {
+		c, err := rule.Compile()
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+
+		for _, fixture := range rule.Fixtures {
+			if err := c.check(fixture, baseDir); err != nil {
+				problems = append(problems, errors.Wrapf(err, "rule %s, fixture %s", rule.Name, fixture.File))
+			}
+		}
+	}
+
+	return problems
+}
+
+// check renders the fixture file and compares the result to the expected title
+func (c *Compiled) check(fixture Fixture, baseDir string) error {
+	if fixture.URL != "" && !c.pattern.MatchString(fixture.URL) {
+		return fmt.Errorf("pattern doesn't match %s", fixture.URL)
+	}
+
+	f, err := os.Open(filepath.Join(baseDir, fixture.File))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); cerr != nil {
+			log.Warnf("Failed to close fixture: %v", cerr)
+		}
+	}()
+
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		return errors.Wrap(err, "Could not load HTML")
+	}
+
+	got, err := c.Render(context.Background(), doc)
+	if err != nil {
+		return err
+	}
+	if got != fixture.Want {
+		return fmt.Errorf("got %q, want %q", got, fixture.Want)
+	}
+
+	return nil
+}
+
+// DefaultRules returns the rules bundled with the binary
+func DefaultRules() ([]Rule, error) {
+	return Parse(defaultRules, "yaml")
+}
+
+// Register the bundled rules like any other handler
+func init() {
+	rules, err := DefaultRules()
+	if err != nil {
+		log.Errorf("Could not parse bundled rules: %v", err)
+		return
+	}
+	if err := Register(rules); err != nil {
+		log.Errorf("Could not register bundled rules: %v", err)
+	}
+}
@@ -0,0 +1,265 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/lepinkainen/titleparser/lambda"
+	"github.com/lepinkainen/titleparser/locale"
+)
+
+func TestDefaultRules(t *testing.T) {
+	t.Parallel()
+
+	rules, err := DefaultRules()
+	if err != nil {
+		t.Fatalf("DefaultRules() error = %v", err)
+	}
+	if len(rules) == 0 {
+		t.Fatal("DefaultRules() returned no rules")
+	}
+
+	for _, problem := range Validate(rules, ".") {
+		t.Errorf("Validate() %v", problem)
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		data    string
+		format  string
+		want    int
+		wantErr bool
+	}{
+		{"YAML", "rules:\n  - name: a\n    pattern: a\n    fields:\n      title:\n        meta: og:title\n", "yaml", 1, false},
+		{"JSON", `{"rules": [{"name": "a", "pattern": "a", "fields": {"title": {"meta": "og:title"}}}]}`, "json", 1, false},
+		{"Broken JSON", `{"rules": [`, "json", 0, true},
+		{"Unknown format", "", "toml", 0, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := Parse([]byte(tt.data), tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if len(got) != tt.want {
+				t.Errorf("Parse() = %d rules, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	t.Parallel()
+
+	title := map[string]Field{"title": {Meta: "og:title"}}
+
+	tests := []struct {
+		name    string
+		rule    Rule
+		wantErr bool
+	}{
+		{"Valid", Rule{Name: "ok", Pattern: `example\.com`, Fields: title}, false},
+		{"No name", Rule{Pattern: `example\.com`, Fields: title}, true},
+		{"Bad pattern", Rule{Name: "bad", Pattern: `(`, Fields: title}, true},
+		{"No title", Rule{Name: "bad", Pattern: `x`, Fields: map[string]Field{"price": {Meta: "x"}}}, true},
+		{"Selector and meta", Rule{Name: "bad", Pattern: `x`, Fields: map[string]Field{"title": {Meta: "x", Selector: "h1"}}}, true},
+		{"State path", Rule{Name: "ok", Pattern: `x`, Fields: map[string]Field{"title": {State: "__NEXT_DATA__", Path: "$.props.title"}}}, false},
+		{"State without path", Rule{Name: "bad", Pattern: `x`, Fields: map[string]Field{"title": {State: "__NEXT_DATA__"}}}, true},
+		{"Bad state path", Rule{Name: "bad", Pattern: `x`, Fields: map[string]Field{"title": {State: "__NEXT_DATA__", Path: "$.a["}}}, true},
+		{"Meta and state", Rule{Name: "bad", Pattern: `x`, Fields: map[string]Field{"title": {Meta: "x", State: "jsonld", Path: "$[0].name"}}}, true},
+		{"Unknown type", Rule{Name: "bad", Pattern: `x`, Fields: map[string]Field{"title": {Meta: "x", Type: "money"}}}, true},
+		{"Bad template", Rule{Name: "bad", Pattern: `x`, Fields: title, Template: "{{.title"}, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := tt.rule.Compile()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Compile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	t.Parallel()
+
+	page := `<html><head>
+<meta property="og:title" content="Muiden elämä">
+<meta property="og:video:duration" content="7920">
+<meta name="price" content="12,90 €">
+<script>window.__INITIAL_STATE__ = {"episode": {"number": 4, "published": "2021-02-10T06:00:00Z"}};</script>
+</head><body><h1>  Heading
+  text </h1><a class="author" href="/u/someone">someone</a></body></html>`
+
+	tests := []struct {
+		name    string
+		rule    Rule
+		want    string
+		wantErr bool
+	}{
+		{"Meta title", Rule{Name: "t", Pattern: "x", Fields: map[string]Field{"title": {Meta: "og:title"}}}, "Muiden elämä", false},
+		{"Selector text", Rule{Name: "t", Pattern: "x", Fields: map[string]Field{"title": {Selector: "h1"}}}, "Heading text", false},
+		{"Selector attr", Rule{Name: "t", Pattern: "x", Fields: map[string]Field{"title": {Selector: "a.author", Attr: "href"}}}, "/u/someone", false},
+		{"Duration", Rule{Name: "t", Pattern: "x",
+			Fields:   map[string]Field{"title": {Meta: "og:title"}, "duration": {Meta: "og:video:duration", Type: "duration"}},
+			Template: "{{.title}} [{{.duration}}]"}, "Muiden elämä [2h 12min]", false},
+		{"Optional field missing", Rule{Name: "t", Pattern: "x",
+			Fields:   map[string]Field{"title": {Meta: "og:title"}, "date": {Meta: "og:video:release_date", Type: "date"}},
+			Template: "{{.title}}{{if .date}} [{{.date}}]{{end}}"}, "Muiden elämä", false},
+		{"Meta name", Rule{Name: "t", Pattern: "x",
+			Fields:   map[string]Field{"title": {Meta: "og:title"}, "price": {Meta: "price"}},
+			Template: "{{.title}} – {{.price}}"}, "Muiden elämä – 12,90 €", false},
+		{"State", Rule{Name: "t", Pattern: "x",
+			Fields:   map[string]Field{"title": {Meta: "og:title"}, "episode": {State: "__INITIAL_STATE__", Path: "$.episode.number"}},
+			Template: "{{.title}} – Episode {{.episode}}"}, "Muiden elämä – Episode 4", false},
+		{"State missing", Rule{Name: "t", Pattern: "x", Fields: map[string]Field{"title": {State: "__NEXT_DATA__", Path: "$.props"}}}, "", true},
+		{"No title", Rule{Name: "t", Pattern: "x", Fields: map[string]Field{"title": {Selector: "h2"}}}, "", true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := tt.rule.Compile()
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			got, err := c.Render(context.Background(), doc)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Render() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderLocale(t *testing.T) {
+	t.Parallel()
+
+	published := time.Now().Add(-75 * time.Hour).UTC().Format(time.RFC3339)
+	page := fmt.Sprintf(`<html><head><meta property="og:title" content="Uutinen">
+<meta property="article:published_time" content="%s"></head></html>`, published)
+
+	rule := Rule{Name: "t", Pattern: "x",
+		Fields:   map[string]Field{"title": {Meta: "og:title"}, "date": {Meta: "article:published_time", Type: "date"}},
+		Template: "{{.title}} ({{.date}})"}
+	c, err := rule.Compile()
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		locale *locale.Locale
+		want   string
+	}{
+		{"English", locale.English, "Uutinen (3 days ago)"},
+		{"Finnish", locale.Finnish, "Uutinen (3 päivää sitten)"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := c.Render(locale.With(context.Background(), tt.locale), doc)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateFixtureMismatch(t *testing.T) {
+	t.Parallel()
+
+	rules := []Rule{{
+		Name:    "wrong",
+		Pattern: `example\.com/p/`,
+		Fields:  map[string]Field{"title": {State: "__NEXT_DATA__", Path: "$.props.pageProps.post.caption"}},
+		Fixtures: []Fixture{
+			{URL: "https://example.org/p/C3xyz", File: "testdata/next.html", Want: "Sunset over the archipelago"},
+			{File: "testdata/next.html", Want: "Something else"},
+			{File: "testdata/missing.html", Want: "Anything"},
+		},
+	}}
+
+	if problems := Validate(rules, "."); len(problems) != 3 {
+		t.Errorf("Validate() = %v, want 3 problems", problems)
+	}
+}
+
+func TestValidateState(t *testing.T) {
+	t.Parallel()
+
+	rules := []Rule{{
+		Name:    "next",
+		Pattern: `example\.com/p/`,
+		Fields: map[string]Field{
+			"title":  {State: "__NEXT_DATA__", Path: "$.props.pageProps.post.caption"},
+			"author": {State: "__NEXT_DATA__", Path: "$.props.pageProps.post.user.username"},
+			"likes":  {State: "__NEXT_DATA__", Path: "$..like_count"},
+		},
+		Template: "{{.title}} by @{{.author}} [{{.likes}} likes]",
+		Fixtures: []Fixture{
+			{URL: "https://example.com/p/C3xyz", File: "testdata/next.html", Want: "Sunset over the archipelago by @saaristolainen [1284 likes]"},
+		},
+	}}
+
+	for _, problem := range Validate(rules, ".") {
+		t.Errorf("Validate() %v", problem)
+	}
+}
+
+func TestLoadFileOverrides(t *testing.T) {
+	lambda.RegisterNamedHandler("test.Builtin", `^https://override\.test/video/`, func(ctx context.Context, url string) (string, error) {
+		return "built-in", nil
+	})
+
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	data := `
+rules:
+  - name: override-test
+    pattern: 'override\.test'
+    fields:
+      title:
+        meta: og:title
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadFile(path); err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+
+	matches := lambda.Match("https://override.test/video/1")
+	if len(matches) != 2 || matches[0].Name != "rules.override-test" || matches[1].Name != "test.Builtin" {
+		t.Errorf("Match() = %+v, want the file rule before the built-in handler", matches)
+	}
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lepinkainen/titleparser/lambda"
+	"github.com/pkg/errors"
+)
+
+// eventProbe has just enough of an event to tell HTTP events, batches,
+// messages and title queries apart
+type eventProbe struct {
+	URLs           []string `json:"urls"`
+	Message        *string  `json:"message"`
+	RequestContext struct {
+		HTTP struct {
+			Method string `json:"method"`
+		} `json:"http"`
+	} `json:"requestContext"`
+}
+
+// LambdaHandler returns the Lambda entry point. Direct invocations with a
+// TitleQuery event work like before, BatchQuery and MessageQuery events get
+// a BatchResult.
+// API Gateway HTTP API (payload format 2.0) and Lambda Function URL events
+// are served like HTTP requests to the local server, auth and status codes
+// included.
+func (s *Server) LambdaHandler() func(context.Context, json.RawMessage) (interface{}, error) {
+	return func(ctx context.Context, event json.RawMessage) (interface{}, error) {
+		var probe eventProbe
+		if err := json.Unmarshal(event, &probe); err != nil {
+			return nil, errors.Wrap(err, "invalid event")
+		}
+
+		if probe.RequestContext.HTTP.Method != "" {
+			// Function URL events have the same format as HTTP API events
+			var req events.APIGatewayV2HTTPRequest
+			if err := json.Unmarshal(event, &req); err != nil {
+				return nil, errors.Wrap(err, "invalid HTTP event")
+			}
+			return s.ServeEvent(ctx, req)
+		}
+
+		// Direct invocation through the AWS SDK, IAM has already done the auth
+		if probe.URLs != nil {
+			var batch lambda.BatchQuery
+			if err := json.Unmarshal(event, &batch); err != nil {
+				return nil, errors.Wrap(err, "invalid batch query")
+			}
+			if status, message := s.checkBatch(batch); status != http.StatusOK {
+				return nil, errors.New(message)
+			}
+			return s.batch(ctx, batch), nil
+		}
+
+		if probe.Message != nil {
+			var message lambda.MessageQuery
+			if err := json.Unmarshal(event, &message); err != nil {
+				return nil, errors.Wrap(err, "invalid message query")
+			}
+			res, status, errMessage := s.message(ctx, message)
+			if status != http.StatusOK {
+				return nil, errors.New(errMessage)
+			}
+			return res, nil
+		}
+
+		var query lambda.TitleQuery
+		if err := json.Unmarshal(event, &query); err != nil {
+			return nil, errors.Wrap(err, "invalid title query")
+		}
+		return s.lookup(ctx, query)
+	}
+}
+
+// ServeEvent runs an HTTP event through the server routes
+func (s *Server) ServeEvent(ctx context.Context, event events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	body := []byte(event.Body)
+	if event.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(event.Body)
+		if err != nil {
+			return eventError(http.StatusBadRequest, "invalid base64 body"), nil
+		}
+		body = decoded
+	}
+
+	target := eventPath(event)
+	if event.RawQueryString != "" {
+		target += "?" + event.RawQueryString
+	}
+
+	req, err := http.NewRequestWithContext(ctx, event.RequestContext.HTTP.Method, target, bytes.NewReader(body))
+	if err != nil {
+		return eventError(http.StatusBadRequest, "invalid request: "+err.Error()), nil
+	}
+	for name, value := range event.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Host = event.RequestContext.DomainName
+	req.RemoteAddr = event.RequestContext.HTTP.SourceIP
+
+	w := newBufferedResponse()
+	s.Handler().ServeHTTP(w, req)
+
+	return w.event(), nil
+}
+
+// eventPath strips the stage from the path, the function URL or API root is the title endpoint
+func eventPath(event events.APIGatewayV2HTTPRequest) string {
+	path := event.RawPath
+	if stage := event.RequestContext.Stage; stage != "" && stage != "$default" {
+		path = strings.TrimPrefix(path, "/"+stage)
+	}
+	if path == "" || path == "/" {
+		return "/title"
+	}
+	return path
+}
+
+// eventError is an error response for events that can't be turned into requests
+func eventError(status int, message string) events.APIGatewayV2HTTPResponse {
+	w := newBufferedResponse()
+	writeError(w, status, message)
+	return w.event()
+}
+
+// bufferedResponse collects the response so it can be returned as an event
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{header: make(http.Header)}
+}
+
+func (w *bufferedResponse) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponse) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+func (w *bufferedResponse) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *bufferedResponse) event() events.APIGatewayV2HTTPResponse {
+	headers := make(map[string]string, len(w.header))
+	for name, values := range w.header {
+		headers[name] = strings.Join(values, ", ")
+	}
+
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode: status,
+		Headers:    headers,
+		Body:       w.body.String(),
+	}
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lepinkainen/titleparser/lambda"
+)
+
+func TestLambdaHandler(t *testing.T) {
+	t.Parallel()
+
+	handler := New(Config{APIKeys: []string{"secret"}}, fakeLookup, fakeBatch).LambdaHandler()
+
+	tests := []struct {
+		name            string
+		fixture         string
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{"API Gateway GET", "apigw-v2-get.json", 200, "text/plain; charset=utf-8", "Title of https://example.com/\n"},
+		{"API Gateway POST with stage", "apigw-v2-post-stage.json", 200, "application/json", `"channel":"#example"`},
+		{"Function URL base64 body", "function-url-post-base64.json", 403, "application/json", "URL denied by policy"},
+		{"Function URL without key", "function-url-get-noauth.json", 401, "application/json", "missing or invalid API key"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			event, err := os.ReadFile("testdata/" + tt.fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			out, err := handler(context.Background(), event)
+			if err != nil {
+				t.Fatalf("handler error = %v", err)
+			}
+			res, ok := out.(events.APIGatewayV2HTTPResponse)
+			if !ok {
+				t.Fatalf("handler returned %T, want an HTTP response", out)
+			}
+
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			if ct := res.Headers["Content-Type"]; ct != tt.wantContentType {
+				t.Errorf("Content-Type = %q, want %q", ct, tt.wantContentType)
+			}
+			if !strings.Contains(res.Body, tt.wantBody) {
+				t.Errorf("body = %q, want it to contain %q", res.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestLambdaHandlerTitleQuery(t *testing.T) {
+	t.Parallel()
+
+	// Direct invocations don't need an API key
+	handler := New(Config{APIKeys: []string{"secret"}}, fakeLookup, fakeBatch).LambdaHandler()
+
+	event, err := os.ReadFile("testdata/title-query.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := handler(context.Background(), event)
+	if err != nil {
+		t.Fatalf("handler error = %v", err)
+	}
+
+	res, ok := out.(lambda.TitleQuery)
+	if !ok {
+		t.Fatalf("handler returned %T, want a TitleQuery", out)
+	}
+	if res.Title != "Title of https://example.com/" || res.Channel != "#example" {
+		t.Errorf("handler = %+v", res)
+	}
+
+	// The response is serialized exactly like before
+	b, err := json.Marshal(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"title":"Title of https://example.com/"`) {
+		t.Errorf("response JSON = %s", b)
+	}
+}
+
+func TestLambdaHandlerBatch(t *testing.T) {
+	t.Parallel()
+
+	handler := New(Config{MaxBatchURLs: 2}, fakeLookup, fakeBatch).LambdaHandler()
+
+	out, err := handler(context.Background(), json.RawMessage(`{"channel":"#test","urls":["https://example.com/","https://denied.example.com/"]}`))
+	if err != nil {
+		t.Fatalf("handler error = %v", err)
+	}
+	res, ok := out.(lambda.BatchResult)
+	if !ok {
+		t.Fatalf("handler returned %T, want a BatchResult", out)
+	}
+	if len(res.Results) != 2 || res.Results[0].Title != "Title of https://example.com/" || res.Results[1].Error == "" {
+		t.Errorf("handler = %+v", res)
+	}
+
+	if _, err := handler(context.Background(), json.RawMessage(`{"urls":["a","b","c"]}`)); err == nil {
+		t.Error("handler accepted a batch over the limit")
+	}
+}
+
+func TestLambdaHandlerMessage(t *testing.T) {
+	t.Parallel()
+
+	handler := New(Config{MaxBatchURLs: 2}, fakeLookup, fakeBatch).LambdaHandler()
+
+	out, err := handler(context.Background(), json.RawMessage(`{"channel":"#test","message":"see <https://example.com/>."}`))
+	if err != nil {
+		t.Fatalf("handler error = %v", err)
+	}
+	res, ok := out.(lambda.BatchResult)
+	if !ok {
+		t.Fatalf("handler returned %T, want a BatchResult", out)
+	}
+	if len(res.Results) != 1 || res.Results[0].URL != "https://example.com/" || res.Results[0].Channel != "#test" {
+		t.Errorf("handler = %+v", res)
+	}
+}
+
+func TestEventPath(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		path  string
+		stage string
+		want  string
+	}{
+		{"Root", "/", "$default", "/title"},
+		{"Empty", "", "", "/title"},
+		{"Title", "/title", "$default", "/title"},
+		{"Stage", "/prod/title", "prod", "/title"},
+		{"Stage root", "/prod", "prod", "/title"},
+		{"Health", "/health", "", "/health"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			event := events.APIGatewayV2HTTPRequest{RawPath: tt.path}
+			event.RequestContext.Stage = tt.stage
+			if got := eventPath(event); got != tt.want {
+				t.Errorf("eventPath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,460 @@
+// Package server runs titleparser as a standalone HTTP service, a self-hosted
+// alternative to the Lambda deployment
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lepinkainen/titleparser/lambda"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultBindAddress only accepts local connections
+	DefaultBindAddress = "127.0.0.1"
+	// DefaultPort is the port of the local mode server
+	DefaultPort = 8081
+	// DefaultMaxBodyBytes is plenty for a title query or a batch of a few hundred URLs
+	DefaultMaxBodyBytes = 64 << 10
+	// DefaultMaxBatchURLs keeps a batch well within the request timeouts
+	DefaultMaxBatchURLs = 100
+
+	// shutdownTimeout is how long running requests get to finish on shutdown
+	shutdownTimeout = 30 * time.Second
+)
+
+// Config for the HTTP server
+type Config struct {
+	// BindAddress is the interface to listen on, 0.0.0.0 for all of them
+	BindAddress string
+	// Port to listen on
+	Port int
+	// APIKeys accepted in the X-API-Key header or as a bearer token, no auth if empty
+	APIKeys []string
+	// MaxBodyBytes limits the size of request bodies
+	MaxBodyBytes int64
+	// MaxBatchURLs limits the number of URLs in a batch request
+	MaxBatchURLs int
+}
+
+// ConfigFromEnv reads the server configuration from BIND_ADDRESS, PORT,
+// API_KEY (comma separated for multiple keys), MAX_BODY_BYTES and MAX_BATCH_URLS
+func ConfigFromEnv() (Config, error) {
+	c := Config{
+		BindAddress:  DefaultBindAddress,
+		Port:         DefaultPort,
+		MaxBodyBytes: DefaultMaxBodyBytes,
+		MaxBatchURLs: DefaultMaxBatchURLs,
+	}
+
+	if addr := os.Getenv("BIND_ADDRESS"); addr != "" {
+		c.BindAddress = addr
+	}
+
+	if port := os.Getenv("PORT"); port != "" {
+		p, err := strconv.Atoi(port)
+		if err != nil || p < 1 || p > 65535 {
+			return c, errors.Errorf("invalid PORT %q", port)
+		}
+		c.Port = p
+	}
+
+	for _, key := range strings.Split(os.Getenv("API_KEY"), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			c.APIKeys = append(c.APIKeys, key)
+		}
+	}
+
+	if size := os.Getenv("MAX_BODY_BYTES"); size != "" {
+		n, err := strconv.ParseInt(size, 10, 64)
+		if err != nil || n < 1 {
+			return c, errors.Errorf("invalid MAX_BODY_BYTES %q", size)
+		}
+		c.MaxBodyBytes = n
+	}
+
+	if size := os.Getenv("MAX_BATCH_URLS"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil || n < 1 {
+			return c, errors.Errorf("invalid MAX_BATCH_URLS %q", size)
+		}
+		c.MaxBatchURLs = n
+	}
+
+	return c, nil
+}
+
+// Addr returns the host:port to listen on
+func (c Config) Addr() string {
+	return net.JoinHostPort(c.BindAddress, strconv.Itoa(c.Port))
+}
+
+// Authorized checks the API key from X-API-Key or an "Authorization: Bearer" header.
+// Everything is authorized when there are no keys configured.
+func (c Config) Authorized(h http.Header) bool {
+	if len(c.APIKeys) == 0 {
+		return true
+	}
+
+	given := h.Get("X-API-Key")
+	if auth := h.Get("Authorization"); given == "" && auth != "" {
+		scheme, token, found := strings.Cut(auth, " ")
+		if found && strings.EqualFold(scheme, "Bearer") {
+			given = strings.TrimSpace(token)
+		}
+	}
+	if given == "" {
+		return false
+	}
+
+	for _, key := range c.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(given), []byte(key)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// LookupFunc resolves the title for a query, lambda.HandleRequest in production
+type LookupFunc func(context.Context, lambda.TitleQuery) (lambda.TitleQuery, error)
+
+// BatchFunc resolves the titles of a batch, lambda.HandleBatch in production
+type BatchFunc func(context.Context, lambda.BatchQuery) lambda.BatchResult
+
+// Server serves title queries over HTTP
+type Server struct {
+	config Config
+	lookup LookupFunc
+	batch  BatchFunc
+}
+
+// New creates a server that resolves titles with the given functions
+func New(config Config, lookup LookupFunc, batch BatchFunc) *Server {
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	if config.MaxBatchURLs <= 0 {
+		config.MaxBatchURLs = DefaultMaxBatchURLs
+	}
+	return &Server{config: config, lookup: lookup, batch: batch}
+}
+
+// Handler returns the routes of the server
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/title", s.requireAuth(s.handleTitle))
+	mux.HandleFunc("/batch", s.requireAuth(s.handleBatch))
+	mux.HandleFunc("/message", s.requireAuth(s.handleMessage))
+	mux.HandleFunc("/handlers", s.requireAuth(s.handleHandlers))
+	mux.HandleFunc("/health", s.handleHealth)
+	return mux
+}
+
+// Run listens on the configured address and serves until the context is cancelled
+func (s *Server) Run(ctx context.Context) error {
+	if len(s.config.APIKeys) == 0 && !isLoopback(s.config.BindAddress) {
+		log.Warnf("Listening on %s without an API key, anyone who can reach it can use it", s.config.Addr())
+	}
+
+	ln, err := net.Listen("tcp", s.config.Addr())
+	if err != nil {
+		return errors.Wrap(err, "could not listen")
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve serves on the listener until the context is cancelled, then waits
+// for running requests to finish
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	srv := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		log.Infof("Listening on %s", ln.Addr())
+		errc <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errc:
+		return errors.Wrap(err, "server failed")
+	case <-ctx.Done():
+	}
+
+	log.Info("Shutting down, waiting for running requests")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return errors.Wrap(err, "shutdown failed")
+	}
+	return nil
+}
+
+// requireAuth rejects requests without a valid API key
+func (s *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.config.Authorized(r.Header) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="titleparser"`)
+			writeError(w, http.StatusUnauthorized, "missing or invalid API key")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleTitle resolves a title query, call it like this with httpie:
+// http http://localhost:8081/title url="http://mantta.fi"
+// or with curl:
+// curl 'http://localhost:8081/title?url=http://mantta.fi'
+func (s *Server) handleTitle(w http.ResponseWriter, r *http.Request) {
+	var query lambda.TitleQuery
+	format := formatJSON
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		params := r.URL.Query()
+		query = queryFromParams(params)
+		// simple bots just want the title line, explanations need JSON
+		format = formatText
+		if query.Explain {
+			format = formatJSON
+		}
+		if f := params.Get("format"); f != "" {
+			format = f
+		}
+		if format != formatText && format != formatJSON {
+			writeError(w, http.StatusBadRequest, "format must be text or json")
+			return
+		}
+	case http.MethodPost:
+		if !s.decodeBody(w, r, &query) {
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		writeError(w, http.StatusMethodNotAllowed, "use GET with a url parameter or POST with a JSON body")
+		return
+	}
+
+	if strings.TrimSpace(query.URL) == "" {
+		writeFormattedError(w, format, http.StatusBadRequest, "no URL given")
+		return
+	}
+
+	res, err := s.lookup(r.Context(), query)
+	if err != nil {
+		log.Warnf("Error handling request for %s: %v", query.URL, err)
+		if format == formatJSON && res.Explanation != nil {
+			// The explanation is most useful when the lookup failed
+			status := statusFor(err)
+			writeJSON(w, status, errorResponse{Error: err.Error(), Status: status, Explanation: res.Explanation})
+			return
+		}
+		writeFormattedError(w, format, statusFor(err), err.Error())
+		return
+	}
+
+	if format == formatText {
+		writeText(w, http.StatusOK, res.Title)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// queryFromParams builds the title query from GET parameters:
+// url, channel, user, languages (comma separated), locale and explain
+func queryFromParams(params url.Values) lambda.TitleQuery {
+	explain, _ := strconv.ParseBool(params.Get("explain"))
+	query := lambda.TitleQuery{
+		URL:     params.Get("url"),
+		Channel: params.Get("channel"),
+		User:    params.Get("user"),
+		Locale:  params.Get("locale"),
+		Explain: explain,
+	}
+	if languages := params.Get("languages"); languages != "" {
+		query.Languages = strings.Split(languages, ",")
+	}
+	return query
+}
+
+// handleBatch resolves a list of URLs, the results are in the same order
+// with an error for each URL that failed
+func (s *Server) handleBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "use POST with a JSON body")
+		return
+	}
+
+	var batch lambda.BatchQuery
+	if !s.decodeBody(w, r, &batch) {
+		return
+	}
+	if status, message := s.checkBatch(batch); status != http.StatusOK {
+		writeError(w, status, message)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.batch(r.Context(), batch))
+}
+
+func (s *Server) handleMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "use POST with a JSON body")
+		return
+	}
+
+	var message lambda.MessageQuery
+	if !s.decodeBody(w, r, &message) {
+		return
+	}
+
+	res, status, errMessage := s.message(r.Context(), message)
+	if status != http.StatusOK {
+		writeError(w, status, errMessage)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// message resolves the URLs in a chat message as a batch, a message
+// without URLs is not an error
+func (s *Server) message(ctx context.Context, message lambda.MessageQuery) (lambda.BatchResult, int, string) {
+	batch := message.Batch()
+	if len(batch.URLs) == 0 {
+		return lambda.BatchResult{Results: []lambda.BatchItem{}}, http.StatusOK, ""
+	}
+	if status, errMessage := s.checkBatch(batch); status != http.StatusOK {
+		return lambda.BatchResult{}, status, errMessage
+	}
+	return lambda.MarkRedundant(message.Message, s.batch(ctx, batch)), http.StatusOK, ""
+}
+
+// checkBatch validates the size of a batch
+func (s *Server) checkBatch(batch lambda.BatchQuery) (int, string) {
+	if len(batch.URLs) == 0 {
+		return http.StatusBadRequest, "no URLs given"
+	}
+	if len(batch.URLs) > s.config.MaxBatchURLs {
+		return http.StatusRequestEntityTooLarge, fmt.Sprintf("too many URLs, at most %d per batch", s.config.MaxBatchURLs)
+	}
+	return http.StatusOK, ""
+}
+
+// decodeBody reads a size limited JSON body, writing the error response if it fails
+func (s *Server) decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	body := http.MaxBytesReader(w, r.Body, s.config.MaxBodyBytes)
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "request body too large")
+			return false
+		}
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// handleHandlers lists the handlers in the order they are tried, with why
+// the disabled ones are disabled
+func (s *Server) handleHandlers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+	writeJSON(w, http.StatusOK, handlersResponse{Handlers: lambda.Handlers()})
+}
+
+// handlersResponse is the body of GET /handlers
+type handlersResponse struct {
+	Handlers []lambda.HandlerInfo `json:"handlers"`
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// statusFor maps lookup errors to HTTP status codes
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, lambda.ErrDenied):
+		return http.StatusForbidden
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		// the site we tried to get the title from failed
+		return http.StatusBadGateway
+	}
+}
+
+const (
+	formatJSON = "json"
+	formatText = "text"
+)
+
+// errorResponse is the body of all JSON error responses
+type errorResponse struct {
+	Error  string `json:"error"`
+	Status int    `json:"status"`
+	// Explanation is set for failed lookups that asked for one
+	Explanation *lambda.Explanation `json:"explanation,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message, Status: status})
+}
+
+// writeFormattedError writes the error as plain text for text format requests
+func writeFormattedError(w http.ResponseWriter, format string, status int, message string) {
+	if format == formatText {
+		writeText(w, status, "error: "+message)
+		return
+	}
+	writeError(w, status, message)
+}
+
+// writeText writes a single line of text
+func writeText(w http.ResponseWriter, status int, line string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	if _, err := fmt.Fprintln(w, line); err != nil {
+		log.Warnf("Failed to write response: %v", err)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warnf("Failed to write response: %v", err)
+	}
+}
+
+// isLoopback checks if the bind address only accepts local connections
+func isLoopback(addr string) bool {
+	if addr == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(addr)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,462 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lepinkainen/titleparser/lambda"
+	"github.com/pkg/errors"
+)
+
+// fakeLookup returns a title based on the URL, or the error the URL asks for
+func fakeLookup(_ context.Context, query lambda.TitleQuery) (lambda.TitleQuery, error) {
+	if query.Explain {
+		query.Explanation = &lambda.Explanation{Handler: "fake", Cache: lambda.CacheDisabled}
+	}
+	switch query.URL {
+	case "https://denied.example.com/":
+		return query, errors.Wrap(lambda.ErrDenied, "denied.example.com")
+	case "https://broken.example.com/":
+		return query, errors.New("404 Not Found")
+	}
+	query.Title = "Title of " + query.URL
+	return query, nil
+}
+
+// fakeBatch runs fakeLookup for every URL of the batch
+func fakeBatch(ctx context.Context, batch lambda.BatchQuery) lambda.BatchResult {
+	var res lambda.BatchResult
+	for _, url := range batch.URLs {
+		query, err := fakeLookup(ctx, lambda.TitleQuery{URL: url, Channel: batch.Channel})
+		item := lambda.BatchItem{TitleQuery: query}
+		if err != nil {
+			item.Error = err.Error()
+		}
+		res.Results = append(res.Results, item)
+	}
+	return res
+}
+
+func TestHandleTitle(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(New(Config{APIKeys: []string{"secret", "other"}, MaxBodyBytes: 1024}, fakeLookup, fakeBatch).Handler())
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		headers    map[string]string
+		wantStatus int
+		wantTitle  string
+		wantError  string
+	}{
+		{"API key header", "POST", `{"url":"https://example.com/"}`, map[string]string{"X-API-Key": "secret"}, 200, "Title of https://example.com/", ""},
+		{"Bearer token", "POST", `{"url":"https://example.com/"}`, map[string]string{"Authorization": "Bearer other"}, 200, "Title of https://example.com/", ""},
+		{"No key", "POST", `{"url":"https://example.com/"}`, nil, 401, "", "missing or invalid API key"},
+		{"Wrong key", "POST", `{"url":"https://example.com/"}`, map[string]string{"X-API-Key": "wrong"}, 401, "", "missing or invalid API key"},
+		{"Basic auth", "POST", `{"url":"https://example.com/"}`, map[string]string{"Authorization": "Basic c2VjcmV0"}, 401, "", "missing or invalid API key"},
+		{"Wrong method", "PUT", `{"url":"https://example.com/"}`, map[string]string{"X-API-Key": "secret"}, 405, "", "use GET with a url parameter or POST with a JSON body"},
+		{"Invalid JSON", "POST", `{"url":`, map[string]string{"X-API-Key": "secret"}, 400, "", "invalid JSON"},
+		{"No URL", "POST", `{"channel":"#test"}`, map[string]string{"X-API-Key": "secret"}, 400, "", "no URL given"},
+		{"Too large", "POST", `{"url":"https://example.com/","title":"` + strings.Repeat("a", 2000) + `"}`, map[string]string{"X-API-Key": "secret"}, 413, "", "request body too large"},
+		{"Denied", "POST", `{"url":"https://denied.example.com/"}`, map[string]string{"X-API-Key": "secret"}, 403, "", "URL denied by policy"},
+		{"Upstream error", "POST", `{"url":"https://broken.example.com/"}`, map[string]string{"X-API-Key": "secret"}, 502, "", "404 Not Found"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req, err := http.NewRequest(tt.method, srv.URL+"/title", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			if ct := res.Header.Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", ct)
+			}
+
+			if tt.wantError != "" {
+				var body errorResponse
+				if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+					t.Fatal(err)
+				}
+				if !strings.Contains(body.Error, tt.wantError) || body.Status != tt.wantStatus {
+					t.Errorf("error body = %+v, want %q with status %d", body, tt.wantError, tt.wantStatus)
+				}
+				return
+			}
+
+			var body lambda.TitleQuery
+			if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Title != tt.wantTitle {
+				t.Errorf("title = %q, want %q", body.Title, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func TestGetTitle(t *testing.T) {
+	t.Parallel()
+
+	var got lambda.TitleQuery
+	var mu sync.Mutex
+	lookup := func(ctx context.Context, query lambda.TitleQuery) (lambda.TitleQuery, error) {
+		mu.Lock()
+		got = query
+		mu.Unlock()
+		return fakeLookup(ctx, query)
+	}
+	srv := httptest.NewServer(New(Config{APIKeys: []string{"secret"}}, lookup, fakeBatch).Handler())
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name            string
+		query           string
+		key             string
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{"Text by default", "url=https://example.com/", "secret", 200, "text/plain; charset=utf-8", "Title of https://example.com/\n"},
+		{"Text", "url=https://example.com/&format=text", "secret", 200, "text/plain; charset=utf-8", "Title of https://example.com/\n"},
+		{"JSON", "url=https://example.com/&format=json", "secret", 200, "application/json", `"title":"Title of https://example.com/"`},
+		{"Text error", "url=https://broken.example.com/", "secret", 502, "text/plain; charset=utf-8", "error: 404 Not Found\n"},
+		{"JSON error", "url=https://denied.example.com/&format=json", "secret", 403, "application/json", `"status":403`},
+		{"Explain", "url=https://example.com/&explain=1", "secret", 200, "application/json", `"explanation":{"handler":"fake"`},
+		{"Explain error", "url=https://broken.example.com/&explain=true", "secret", 502, "application/json", `"error":"404 Not Found","status":502,"explanation":{"handler":"fake"`},
+		{"No URL", "format=text", "secret", 400, "text/plain; charset=utf-8", "error: no URL given\n"},
+		{"Unknown format", "url=https://example.com/&format=xml", "secret", 400, "application/json", "format must be text or json"},
+		{"Auth", "url=https://example.com/", "", 401, "application/json", "missing or invalid API key"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req, err := http.NewRequest("GET", srv.URL+"/title?"+tt.query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.key != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.key)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			if ct := res.Header.Get("Content-Type"); ct != tt.wantContentType {
+				t.Errorf("Content-Type = %q, want %q", ct, tt.wantContentType)
+			}
+			if !strings.Contains(string(body), tt.wantBody) {
+				t.Errorf("body = %q, want it to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("Parameters", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", srv.URL+"/title?url=https://example.com/&channel=%23chan&user=nick&languages=fi,en&locale=fi", nil)
+		req.Header.Set("X-API-Key", "secret")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+
+		mu.Lock()
+		defer mu.Unlock()
+		want := lambda.TitleQuery{URL: "https://example.com/", Channel: "#chan", User: "nick", Languages: []string{"fi", "en"}, Locale: "fi"}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("query = %+v, want %+v", got, want)
+		}
+	})
+}
+
+func TestBatch(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(New(Config{APIKeys: []string{"secret"}, MaxBatchURLs: 3}, fakeLookup, fakeBatch).Handler())
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"Results in order", "POST", `{"channel":"#test","urls":["https://example.com/","https://broken.example.com/"]}`, 200,
+			`{"results":[{"timestamp":0,"user":"","channel":"#test","url":"https://example.com/","title":"Title of https://example.com/","ttl":0},` +
+				`{"timestamp":0,"user":"","channel":"#test","url":"https://broken.example.com/","title":"","ttl":0,"error":"404 Not Found"}]}`},
+		{"No URLs", "POST", `{"urls":[]}`, 400, "no URLs given"},
+		{"Too many URLs", "POST", `{"urls":["a","b","c","d"]}`, 413, "too many URLs, at most 3 per batch"},
+		{"Wrong method", "GET", "", 405, "use POST with a JSON body"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req, err := http.NewRequest(tt.method, srv.URL+"/batch", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-API-Key", "secret")
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			if !strings.Contains(string(body), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestMessage(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(New(Config{MaxBatchURLs: 2}, fakeLookup, fakeBatch).Handler())
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"URLs in order", "POST", `{"channel":"#test","message":"<https://example.com/> and (https://broken.example.com/), https://example.com/"}`, 200,
+			`{"results":[{"timestamp":0,"user":"","channel":"#test","url":"https://example.com/","title":"Title of https://example.com/","ttl":0},` +
+				`{"timestamp":0,"user":"","channel":"#test","url":"https://broken.example.com/","title":"","ttl":0,"error":"404 Not Found"}]}`},
+		{"No URLs", "POST", `{"message":"just chatting"}`, 200, `{"results":[]}`},
+		{"Too many URLs", "POST", `{"message":"https://a.example.com/ https://b.example.com/ https://c.example.com/"}`, 413, "too many URLs, at most 2 per batch"},
+		{"Wrong method", "GET", "", 405, "use POST with a JSON body"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req, err := http.NewRequest(tt.method, srv.URL+"/message", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			if !strings.Contains(string(body), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	t.Parallel()
+
+	lambda.Register(lambda.Handler{
+		Name:     "test.ServerDisabled",
+		Patterns: []string{`^https://server-disabled\.test/`},
+		Env:      []string{"SERVER_TEST_MISSING_KEY"},
+		Func:     func(ctx context.Context, url string) (string, error) { return "", nil },
+	})
+	srv := httptest.NewServer(New(Config{APIKeys: []string{"secret"}}, fakeLookup, fakeBatch).Handler())
+	t.Cleanup(srv.Close)
+
+	req, _ := http.NewRequest("GET", srv.URL+"/handlers", nil)
+	req.Header.Set("X-API-Key", "secret")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var body handlersResponse
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil || res.StatusCode != http.StatusOK {
+		t.Fatalf("GET /handlers = %d: %v", res.StatusCode, err)
+	}
+	var found bool
+	for _, h := range body.Handlers {
+		if h.Name == "test.ServerDisabled" {
+			found = true
+			if h.Enabled || h.Disabled != "SERVER_TEST_MISSING_KEY not set" {
+				t.Errorf("GET /handlers = %+v, want it disabled for the missing key", h)
+			}
+		}
+	}
+	if last := body.Handlers[len(body.Handlers)-1]; !found || last.Name != lambda.DefaultHandlerName {
+		t.Errorf("GET /handlers = %+v", body.Handlers)
+	}
+
+	res, err = http.Get(srv.URL + "/handlers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("GET /handlers without a key = %d, want 401", res.StatusCode)
+	}
+}
+
+func TestNoAuthConfigured(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(New(Config{}, fakeLookup, fakeBatch).Handler())
+	t.Cleanup(srv.Close)
+
+	res, err := http.Post(srv.URL+"/title", "application/json", strings.NewReader(`{"url":"https://example.com/"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", res.StatusCode)
+	}
+
+	for _, path := range []string{"/", "/hi"} {
+		res, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("GET %s status = %d, want 404", path, res.StatusCode)
+		}
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    Config
+		wantErr bool
+	}{
+		{"Defaults", nil, Config{BindAddress: "127.0.0.1", Port: 8081, MaxBodyBytes: DefaultMaxBodyBytes, MaxBatchURLs: DefaultMaxBatchURLs}, false},
+		{"Everything", map[string]string{"BIND_ADDRESS": "0.0.0.0", "PORT": "9000", "API_KEY": "a, b", "MAX_BODY_BYTES": "100", "MAX_BATCH_URLS": "5"},
+			Config{BindAddress: "0.0.0.0", Port: 9000, APIKeys: []string{"a", "b"}, MaxBodyBytes: 100, MaxBatchURLs: 5}, false},
+		{"Invalid port", map[string]string{"PORT": "http"}, Config{}, true},
+		{"Port out of range", map[string]string{"PORT": "70000"}, Config{}, true},
+		{"Invalid size", map[string]string{"MAX_BODY_BYTES": "-1"}, Config{}, true},
+		{"Invalid batch size", map[string]string{"MAX_BATCH_URLS": "0"}, Config{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"BIND_ADDRESS", "PORT", "API_KEY", "MAX_BODY_BYTES", "MAX_BATCH_URLS"} {
+				t.Setenv(key, tt.env[key])
+			}
+			got, err := ConfigFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ConfigFromEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("ConfigFromEnv() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{})
+	slow := func(ctx context.Context, query lambda.TitleQuery) (lambda.TitleQuery, error) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		query.Title = "Slow title"
+		return query, nil
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- New(Config{}, slow, fakeBatch).Serve(ctx, ln)
+	}()
+
+	type result struct {
+		status int
+		err    error
+	}
+	resc := make(chan result, 1)
+	go func() {
+		res, err := http.Post("http://"+ln.Addr().String()+"/title", "application/json", strings.NewReader(`{"url":"https://example.com/"}`))
+		if err != nil {
+			resc <- result{err: err}
+			return
+		}
+		res.Body.Close()
+		resc <- result{status: res.StatusCode}
+	}()
+
+	// shut down while the request is still running
+	<-started
+	cancel()
+
+	if err := <-done; err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+	res := <-resc
+	if res.err != nil || res.status != http.StatusOK {
+		t.Errorf("running request got %d, %v, want it to finish with 200", res.status, res.err)
+	}
+
+	if _, err := net.DialTimeout("tcp", ln.Addr().String(), time.Second); err == nil {
+		t.Error("server still accepting connections after shutdown")
+	}
+}
@@ -0,0 +1,280 @@
+// Package stream runs title lookups for newline delimited JSON queries read
+// from a pipe, one result line per URL, for Unix pipelines and batch jobs
+package stream
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lepinkainen/titleparser/lambda"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultConcurrency is how many input lines are handled at the same time
+	DefaultConcurrency = lambda.DefaultBatchConcurrency
+
+	// maxLineBytes is the longest accepted input line
+	maxLineBytes = 1 << 20
+	// windowPerWorker limits how far reading can get ahead of writing,
+	// a slow lookup at the start doesn't buffer the whole input
+	windowPerWorker = 4
+)
+
+// Config for a stream
+type Config struct {
+	// Concurrency is the number of lines handled at the same time
+	Concurrency int
+	// Ordered writes results in input order, otherwise they are written
+	// as soon as they are done and tagged with the input line number
+	Ordered bool
+}
+
+// ConfigFromEnv reads STREAM_CONCURRENCY and STREAM_ORDER (input or completion)
+func ConfigFromEnv() (Config, error) {
+	c := Config{Concurrency: DefaultConcurrency, Ordered: true}
+
+	if n := os.Getenv("STREAM_CONCURRENCY"); n != "" {
+		v, err := strconv.Atoi(n)
+		if err != nil || v < 1 {
+			return c, errors.Errorf("invalid STREAM_CONCURRENCY %q", n)
+		}
+		c.Concurrency = v
+	}
+
+	switch order := os.Getenv("STREAM_ORDER"); order {
+	case "", "input":
+		c.Ordered = true
+	case "completion":
+		c.Ordered = false
+	default:
+		return c, errors.Errorf("invalid STREAM_ORDER %q, use input or completion", order)
+	}
+
+	return c, nil
+}
+
+// LookupFunc resolves a single title query
+type LookupFunc func(context.Context, lambda.TitleQuery) (lambda.TitleQuery, error)
+
+// BatchFunc resolves a batch of URLs
+type BatchFunc func(context.Context, lambda.BatchQuery) lambda.BatchResult
+
+// Result is one output line. Line is the input line number, only set when
+// results are written in completion order.
+type Result struct {
+	Line int `json:"line,omitempty"`
+	lambda.BatchItem
+}
+
+// Summary counts what a stream did
+type Summary struct {
+	// Lines is the number of non-empty input lines
+	Lines int
+	// Results is the number of result lines written, batches and messages can have several
+	Results int
+	// Failed is the number of results with an error
+	Failed int
+}
+
+// job is one input line, seq is its position among the non-empty lines
+type job struct {
+	seq  int
+	line int
+	text string
+}
+
+type done struct {
+	seq     int
+	results []Result
+}
+
+// Run reads queries from in until EOF and writes the results to out as
+// NDJSON. A line is a title query, a batch query, a message query or just
+// a URL. Failed lookups are results with an error, the returned error is
+// only for reading and writing.
+func Run(ctx context.Context, config Config, in io.Reader, out io.Writer, lookup LookupFunc, batch BatchFunc) (Summary, error) {
+	concurrency := config.Concurrency
+	if concurrency < 1 {
+		concurrency = DefaultConcurrency
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan job)
+	results := make(chan done)
+	window := make(chan struct{}, concurrency*windowPerWorker)
+
+	// Reader
+	var readErr error
+	go func() {
+		defer close(jobs)
+
+		scanner := bufio.NewScanner(in)
+		scanner.Buffer(make([]byte, 64<<10), maxLineBytes)
+		seq, line := 0, 0
+		for scanner.Scan() {
+			line++
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" {
+				continue
+			}
+
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- job{seq: seq, line: line, text: text}:
+			case <-ctx.Done():
+				return
+			}
+			seq++
+		}
+		readErr = scanner.Err()
+	}()
+
+	// Workers
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				res := handleLine(ctx, j.text, lookup, batch)
+				if !config.Ordered {
+					for i := range res {
+						res[i].Line = j.line
+					}
+				}
+				results <- done{seq: j.seq, results: res}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Writer
+	var summary Summary
+	var writeErr error
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+
+	write := func(d done) {
+		summary.Lines++
+		for _, res := range d.results {
+			summary.Results++
+			if res.Error != "" {
+				summary.Failed++
+			}
+			if writeErr != nil {
+				continue
+			}
+			if err := encoder.Encode(res); err != nil {
+				// Nobody is reading anymore, stop taking new lines
+				writeErr = errors.Wrap(err, "writing results")
+				cancel()
+			}
+		}
+		<-window
+	}
+
+	pending := make(map[int]done)
+	next := 0
+	for d := range results {
+		if !config.Ordered {
+			write(d)
+			continue
+		}
+		pending[d.seq] = d
+		for {
+			d, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			write(d)
+			next++
+		}
+	}
+
+	if writeErr != nil {
+		return summary, writeErr
+	}
+	if readErr != nil {
+		return summary, errors.Wrap(readErr, "reading queries")
+	}
+	return summary, nil
+}
+
+// handleLine runs the query on one input line
+func handleLine(ctx context.Context, text string, lookup LookupFunc, batch BatchFunc) []Result {
+	if !strings.HasPrefix(text, "{") {
+		return single(lookup(ctx, lambda.TitleQuery{URL: text}))
+	}
+
+	var probe struct {
+		URLs    []string `json:"urls"`
+		Message *string  `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(text), &probe); err != nil {
+		return failed("invalid JSON: " + err.Error())
+	}
+
+	switch {
+	case probe.URLs != nil:
+		var query lambda.BatchQuery
+		if err := json.Unmarshal([]byte(text), &query); err != nil {
+			return failed("invalid batch query: " + err.Error())
+		}
+		return fromBatch(batch(ctx, query))
+
+	case probe.Message != nil:
+		var query lambda.MessageQuery
+		if err := json.Unmarshal([]byte(text), &query); err != nil {
+			return failed("invalid message query: " + err.Error())
+		}
+		urls := query.Batch()
+		if len(urls.URLs) == 0 {
+			return nil
+		}
+		return fromBatch(lambda.MarkRedundant(query.Message, batch(ctx, urls)))
+
+	default:
+		var query lambda.TitleQuery
+		if err := json.Unmarshal([]byte(text), &query); err != nil {
+			return failed("invalid title query: " + err.Error())
+		}
+		return single(lookup(ctx, query))
+	}
+}
+
+func single(query lambda.TitleQuery, err error) []Result {
+	res := Result{BatchItem: lambda.BatchItem{TitleQuery: query}}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	return []Result{res}
+}
+
+func failed(message string) []Result {
+	return []Result{{BatchItem: lambda.BatchItem{Error: message}}}
+}
+
+func fromBatch(batch lambda.BatchResult) []Result {
+	results := make([]Result, 0, len(batch.Results))
+	for _, item := range batch.Results {
+		results = append(results, Result{BatchItem: item})
+	}
+	return results
+}
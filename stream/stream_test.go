@@ -0,0 +1,221 @@
+package stream
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lepinkainen/titleparser/lambda"
+)
+
+// fakeLookup is slow for "slow" URLs, so they finish last in completion order
+func fakeLookup(ctx context.Context, query lambda.TitleQuery) (lambda.TitleQuery, error) {
+	if strings.Contains(query.URL, "slow") {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if strings.Contains(query.URL, "broken") {
+		return query, errors.New("404 Not Found")
+	}
+	query.Title = "Title of " + query.URL
+	return query, nil
+}
+
+func fakeBatch(ctx context.Context, batch lambda.BatchQuery) lambda.BatchResult {
+	var res lambda.BatchResult
+	for _, url := range batch.URLs {
+		query, err := fakeLookup(ctx, lambda.TitleQuery{URL: url, Channel: batch.Channel})
+		item := lambda.BatchItem{TitleQuery: query}
+		if err != nil {
+			item.Error = err.Error()
+		}
+		res.Results = append(res.Results, item)
+	}
+	return res
+}
+
+// readResults decodes the NDJSON output
+func readResults(t *testing.T, out string) []Result {
+	t.Helper()
+	var results []Result
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		var res Result
+		if err := json.Unmarshal(scanner.Bytes(), &res); err != nil {
+			t.Fatalf("invalid output line %q: %v", scanner.Text(), err)
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	input := strings.Join([]string{
+		`{"url":"https://slow.example.com/","channel":"#test"}`,
+		``,
+		`https://example.com/plain`,
+		`{"url":"https://broken.example.com/"}`,
+		`{"channel":"#batch","urls":["https://example.com/1","https://example.com/2"]}`,
+		`{"message":"see <https://example.com/msg>. no more"}`,
+		`{"message":"no links here"}`,
+		`{not json`,
+	}, "\n")
+
+	tests := []struct {
+		name        string
+		ordered     bool
+		wantURLs    []string
+		wantLines   []int
+		wantSummary Summary
+	}{
+		{"Input order", true,
+			[]string{"https://slow.example.com/", "https://example.com/plain", "https://broken.example.com/", "https://example.com/1", "https://example.com/2", "https://example.com/msg", ""},
+			[]int{0, 0, 0, 0, 0, 0, 0},
+			Summary{Lines: 7, Results: 7, Failed: 2}},
+		{"Completion order", false,
+			[]string{"https://example.com/plain", "https://broken.example.com/", "https://example.com/1", "https://example.com/2", "https://example.com/msg", "", "https://slow.example.com/"},
+			[]int{3, 4, 5, 5, 6, 8, 1},
+			Summary{Lines: 7, Results: 7, Failed: 2}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var out strings.Builder
+			summary, err := Run(context.Background(), Config{Concurrency: 8, Ordered: tt.ordered}, strings.NewReader(input), &out, fakeLookup, fakeBatch)
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if summary != tt.wantSummary {
+				t.Errorf("Run() = %+v, want %+v", summary, tt.wantSummary)
+			}
+
+			results := readResults(t, out.String())
+			var urls []string
+			for _, res := range results {
+				urls = append(urls, res.URL)
+			}
+
+			got := urls
+			want := tt.wantURLs
+			if !tt.ordered {
+				// Only the slow one is sure to be last, the rest can finish in any order
+				got = append(sorted(urls[:len(urls)-1]), urls[len(urls)-1])
+				want = append(sorted(want[:len(want)-1]), want[len(want)-1])
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("result URLs = %q, want %q", urls, tt.wantURLs)
+			}
+
+			// the invalid JSON line has no URL and matches ""
+			for i, res := range results {
+				wantLine := tt.wantLines[slices.Index(tt.wantURLs, res.URL)]
+				if res.Line != wantLine {
+					t.Errorf("result %d (%s) line = %d, want %d", i, res.URL, res.Line, wantLine)
+				}
+			}
+
+			if !strings.Contains(out.String(), `"error":"404 Not Found"`) || !strings.Contains(out.String(), `"error":"invalid JSON`) {
+				t.Errorf("errors missing from output:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestRunConcurrency(t *testing.T) {
+	t.Parallel()
+
+	var running, maxRunning int32
+	lookup := func(ctx context.Context, query lambda.TitleQuery) (lambda.TitleQuery, error) {
+		now := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			old := atomic.LoadInt32(&maxRunning)
+			if now <= old || atomic.CompareAndSwapInt32(&maxRunning, old, now) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		query.Title = "ok"
+		return query, nil
+	}
+
+	input := strings.Repeat("https://example.com/\n", 40)
+	summary, err := Run(context.Background(), Config{Concurrency: 3, Ordered: true}, strings.NewReader(input), io.Discard, lookup, fakeBatch)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if summary.Results != 40 || summary.Failed != 0 {
+		t.Errorf("Run() = %+v", summary)
+	}
+	if maxRunning > 3 {
+		t.Errorf("%d lookups at the same time, want at most 3", maxRunning)
+	}
+}
+
+// failingWriter fails after the first write, like a closed pipe
+type failingWriter struct{ writes int }
+
+func (w *failingWriter) Write(b []byte) (int, error) {
+	w.writes++
+	if w.writes > 1 {
+		return 0, errors.New("broken pipe")
+	}
+	return len(b), nil
+}
+
+func TestRunWriteError(t *testing.T) {
+	t.Parallel()
+
+	input := strings.Repeat("https://example.com/\n", 100)
+	w := &failingWriter{}
+	if _, err := Run(context.Background(), Config{Concurrency: 2, Ordered: true}, strings.NewReader(input), w, fakeLookup, fakeBatch); err == nil {
+		t.Error("Run() succeeded with a broken output")
+	}
+	if w.writes != 2 {
+		t.Errorf("%d writes, want writing to stop after the error", w.writes)
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    Config
+		wantErr bool
+	}{
+		{"Defaults", nil, Config{Concurrency: DefaultConcurrency, Ordered: true}, false},
+		{"Completion order", map[string]string{"STREAM_CONCURRENCY": "2", "STREAM_ORDER": "completion"}, Config{Concurrency: 2}, false},
+		{"Invalid concurrency", map[string]string{"STREAM_CONCURRENCY": "0"}, Config{}, true},
+		{"Invalid order", map[string]string{"STREAM_ORDER": "random"}, Config{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"STREAM_CONCURRENCY", "STREAM_ORDER"} {
+				t.Setenv(key, tt.env[key])
+			}
+			got, err := ConfigFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ConfigFromEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ConfigFromEnv() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func sorted(s []string) []string {
+	out := slices.Clone(s)
+	slices.Sort(out)
+	return out
+}